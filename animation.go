@@ -1,64 +1,847 @@
 package sprites
 
 import (
+	"fmt"
 	"image"
-
-	ccsl_graphics "github.com/HaileyStorm/CCSL_go/graphics"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
 )
 
+// animation holds the playback state of an Instance. All of its exported methods are safe for concurrent use: mu guards
+// the current Mode and all playback state, so e.g. a render goroutine may call Frame while an update goroutine calls
+// StartAnimation or changes the Instance's Mode. The unexported methods expect mu to already be held.
+//
+// The playback controls are:
+//   - StartAnimation starts the animation from its current frame (restarting it if it has Finished).
+//   - PauseAnimation freezes the animation in place, and ResumeAnimation continues it exactly where it was paused.
+//   - StopAnimation stops the animation on its current frame, discarding any progress toward the next frame.
+//   - ResetAnimation stops the animation and moves it to its first frame.
+//   - RestartAnimation moves the animation to its first frame and starts it.
 type animation struct {
 	*Mode
 
+	mu           sync.Mutex
 	running      bool
 	currentFrame int
+	// paused indicates the animation was stopped by PauseAnimation (rather than StopAnimation, etc.). See State.
+	paused bool
+
+	// speedScale is the number of frames the animation advances per call to Advance (or Frame). See SetSpeedScale.
+	speedScale float64
+	// progress accumulates fractional frames of advancement when speedScale is not a whole number.
+	progress float64
+
+	// loopCount is the number of cycles the animation plays before finishing, or <= 0 to loop forever. See
+	// SetLoopCount.
+	loopCount int
+	// loopsCompleted is the number of cycles completed (in the current Mode) since the animation was restarted.
+	loopsCompleted int
+	// finished indicates the animation stopped itself after completing loopCount cycles.
+	finished bool
+
+	// random indicates random playback, in which frames are chosen using rng (or the math/rand default source if it is
+	// nil). randomSteps counts the frames advanced toward completing a cycle. See SetRandomPlayback.
+	random      bool
+	rng         *rand.Rand
+	randomSteps float64
+
+	// onFrame holds the callbacks registered with OnFrame, by frame index.
+	onFrame map[int]func()
+	// onModeChange is the callback set with SetOnModeChange, or nil.
+	onModeChange func(oldMode, newMode string)
+	// fired holds the OnFrame and OnModeChange callbacks triggered while mu is held, to be called by unlock once it is
+	// released.
+	fired []func()
+
+	// queue holds the Modes still to be played, in order, after the current Mode completes a cycle. See
+	// Instance.QueueModes.
+	queue []*Mode
 }
 
+// newAnimation creates a stopped animation of mode, at its first frame, with a speed scale of 1.
+func newAnimation(mode *Mode) *animation {
+	return &animation{
+		Mode:         mode,
+		running:      false,
+		currentFrame: 0,
+		speedScale:   1,
+	}
+}
+
+// clear resets all of the animation's state (except mu) to that of newAnimation, including removing its callbacks, and
+// removes its Mode. See InstancePool.
+func (a *animation) clear() {
+	a.Mode = nil
+	a.running = false
+	a.paused = false
+	a.currentFrame = 0
+	a.speedScale = 1
+	a.progress = 0
+	a.loopCount = 0
+	a.loopsCompleted = 0
+	a.finished = false
+	a.random = false
+	a.rng = nil
+	a.randomSteps = 0
+	a.onFrame = nil
+	a.onModeChange = nil
+	a.fired = nil
+	a.queue = nil
+}
+
+// Running returns whether the animation is running, that is whether Frame and Advance advance it.
 func (a *animation) Running() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	return a.running
 }
 
+// StartAnimation starts (or resumes) the animation from its current frame. If the animation has Finished, it is
+// instead restarted from its first frame (see RestartAnimation).
 func (a *animation) StartAnimation() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.finished {
+		a.restart()
+		return
+	}
 	a.running = true
+	a.paused = false
+}
+
+// PauseAnimation stops the animation, leaving it exactly as it is - on the same frame, and with the same progress
+// toward the next frame (see SetSpeedScale) - so that ResumeAnimation continues it precisely where it left off.
+func (a *animation) PauseAnimation() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.running {
+		a.paused = true
+	}
+	a.running = false
 }
 
+// ResumeAnimation continues the animation exactly where it was paused (see PauseAnimation) or stopped. Unlike
+// StartAnimation, it does not restart an animation which has Finished; such an animation remains finished.
+func (a *animation) ResumeAnimation() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.finished {
+		a.running = true
+		a.paused = false
+	}
+}
+
+// RestartAnimation moves the animation to its first frame, and starts it.
 func (a *animation) RestartAnimation() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.restart()
+}
+
+// restart moves the animation to the first frame, clears the completed loop count, and starts it.
+func (a *animation) restart() {
 	a.currentFrame = 0
+	a.progress = 0
+	a.loopsCompleted = 0
+	a.randomSteps = 0
+	a.finished = false
 	a.running = true
+	a.paused = false
 }
 
+// ResetAnimation stops the animation and moves it to its first frame.
 func (a *animation) ResetAnimation() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	a.currentFrame = 0
+	a.progress = 0
+	a.loopsCompleted = 0
+	a.randomSteps = 0
+	a.finished = false
 	a.running = false
+	a.paused = false
 }
 
+// StopAnimation stops the animation on its current frame. Unlike PauseAnimation, any progress toward the next frame
+// (see SetSpeedScale) is discarded, so when the animation is started again it spends a full frame on the current frame.
 func (a *animation) StopAnimation() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	a.running = false
+	a.paused = false
+	a.progress = 0
 }
 
+// Frame returns the current frame and advances the animation, combining CurrentFrame and Advance. The returned Sprite
+// is typically a view sharing its pixels with the Sheet's image and the Mode (and so every Instance using it), and must
+// not be modified; see FrameCopy. Should the Mode have no frames (which the Mode's frame editors do not allow), Frame
+// returns a fully transparent placeholder of the Mode's SpriteSize, and the animation does not advance.
 func (a *animation) Frame() Sprite {
-	var frame Sprite
-	var err error
+	a.mu.Lock()
+	defer a.unlock()
+	return a.frame()
+}
 
-	a.currentFrame %= a.FrameCount()
-	frame, err = a.GetFrame(a.currentFrame)
-	if err != nil {
-		panic(err)
+// CurrentFrame returns the current frame, that is the frame the next call to Frame will return, without advancing the
+// animation. Calling CurrentFrame to draw and Advance once per update keeps drawing and advancing separate, so drawing
+// more than once per update does not speed the animation up. As with Frame, the returned Sprite must not be modified.
+func (a *animation) CurrentFrame() Sprite {
+	frame, _ := a.currentFrameAndMode()
+	return frame
+}
+
+// frame returns the current frame and advances the animation.
+func (a *animation) frame() Sprite {
+	if count := a.FrameCount(); count > 0 && a.currentFrame >= count {
+		logf("sprites: current frame %d is out of range of Mode %s (%d frames); wrapping to %d", a.currentFrame,
+			a.Mode.name, count, wrapIndex(a.currentFrame, count))
 	}
-	a.Advance()
+	a.currentFrame = wrapIndex(a.currentFrame, a.FrameCount())
+	frame := a.frameAt(a.currentFrame)
+	a.advance()
 
 	return frame
 }
 
+// frameAndMode returns the current frame along with the Mode it belongs to, and advances the animation. It is used
+// where the caller needs information about the Mode (e.g. fullyOpaque) consistent with the returned frame.
+func (a *animation) frameAndMode() (Sprite, *Mode) {
+	a.mu.Lock()
+	defer a.unlock()
+	mode := a.Mode
+	return a.frame(), mode
+}
+
+// currentFrameIndexAndMode returns the index of the current frame (the frame the next call to Frame will return) along
+// with the Mode it belongs to, without advancing the animation.
+func (a *animation) currentFrameIndexAndMode() (int, *Mode) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return wrapIndex(a.currentFrame, a.FrameCount()), a.Mode
+}
+
+// currentFrameAndMode returns the current frame (the frame the next call to Frame will return) along with the Mode it
+// belongs to, without advancing the animation.
+func (a *animation) currentFrameAndMode() (Sprite, *Mode) {
+	index, mode := a.currentFrameIndexAndMode()
+	return mode.frameAt(index), mode
+}
+
+// frameIndexAndMode returns the index of the current frame along with the Mode it belongs to, and advances the
+// animation.
+func (a *animation) frameIndexAndMode() (int, *Mode) {
+	a.mu.Lock()
+	defer a.unlock()
+	mode := a.Mode
+	index := wrapIndex(a.currentFrame, a.FrameCount())
+	a.frame()
+	return index, mode
+}
+
+// FrameResized is like Frame, but returns the frame resized to w x h, using the Mode's resize filter (from
+// SheetDimensions.ResizeFilter). Resized frames are cached by the Mode (for all
+// Instances using it), so repeated requests for the same frame at the same size are cheap. If the frame is already
+// w x h, it is returned as is, without allocating. The returned Sprite is shared and must not be modified. See
+// Mode.ClearResizeCache.
 func (a *animation) FrameResized(w, h uint) Sprite {
-	frame := a.Frame()
-	return ccsl_graphics.ResizeMaintain(frame.(*image.RGBA), w, h)
+	index, mode := a.frameIndexAndMode()
+	return mode.resizedFrame(index, w, h, mode.resizeFilter)
+}
+
+// FrameCopy returns a newly allocated copy of the current frame, with Bounds().Min at (0,0), which the caller may
+// modify or hand to other code freely. Unlike Frame, it does not advance the animation.
+func (a *animation) FrameCopy() *image.RGBA {
+	frame, _ := a.currentFrameAndMode()
+	return copyFrame(frame)
+}
+
+// FrameResizedWith is like FrameResized, but resizes the frame using filter rather than the Mode's resize filter, e.g.
+// ResizeLanczos for a smooth thumbnail or ResizeNearestNeighbor for crisp in-game scaling. Frames resized with each
+// filter are cached separately.
+func (a *animation) FrameResizedWith(w, h uint, filter ResizeFilter) Sprite {
+	index, mode := a.frameIndexAndMode()
+	return mode.resizedFrame(index, w, h, filter)
+}
+
+// CurrentFrameIndex returns the index of the current frame, that is the frame the next call to Frame will return.
+func (a *animation) CurrentFrameIndex() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return wrapIndex(a.currentFrame, a.FrameCount())
+}
+
+// SetCurrentFrame jumps the animation to the frame at index, which must be >= 0 and < FrameCount(); it will be the
+// frame returned by the next call to Frame. Any fractional progress toward the next frame (see SetSpeedScale) is
+// discarded. It does not change whether the animation is running.
+func (a *animation) SetCurrentFrame(index int) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if index < 0 || index >= a.FrameCount() {
+		return fmt.Errorf("frame index (%d) must be >= 0 and < the current frame count (%d)", index, a.FrameCount())
+	}
+	a.currentFrame = index
+	a.progress = 0
+	return nil
+}
+
+// SetCurrentFrameByName is like SetCurrentFrame, but jumps to the current Mode's frame with the given name. See
+// Mode.SetFrameName.
+func (a *animation) SetCurrentFrameByName(name string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	index, err := a.FrameIndexByName(name)
+	if err != nil {
+		return err
+	}
+	a.currentFrame = index
+	a.progress = 0
+	return nil
+}
+
+// FrameBlended returns a new image blending the current frame (the frame the next call to Frame will return) with the
+// frame after it (in the direction of playback; see SetSpeedScale): t = 0 gives the current frame, t = 1 the next,
+// and values between a linear crossfade, which can smooth slow animations. t is clamped to [0,1]. Blending is done in
+// premultiplied alpha space, so there are no dark halos around partially transparent pixels. A natural choice of t is
+// FrameProgress(). FrameBlended does not advance the animation.
+func (a *animation) FrameBlended(t float64) *image.RGBA {
+	a.mu.Lock()
+	count := a.FrameCount()
+	current := wrapIndex(a.currentFrame, count)
+	next := current + 1
+	if a.speedScale < 0 {
+		next = current - 1
+	}
+	from, to := a.frameAt(current), a.frameAt(wrapIndex(next, count))
+	a.mu.Unlock()
+	return blendFrames(toRGBA(from), toRGBA(to), t)
+}
+
+// FrameProgress returns the fraction, from 0 up to (but not including) 1, of the way the animation has progressed
+// from the current frame toward the next. It is only ever non-zero when the speed scale is not a whole number (see
+// SetSpeedScale).
+func (a *animation) FrameProgress() float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return math.Abs(a.progress)
+}
+
+// blendFrames returns a new image, with Bounds().Min at (0,0), in which each (premultiplied) pixel is the linear
+// interpolation by t (clamped to [0,1]) from the corresponding pixel of from to that of to. from and to must be the
+// same size.
+func blendFrames(from, to *image.RGBA, t float64) *image.RGBA {
+	if !(t > 0) {
+		t = 0
+	} else if t > 1 {
+		t = 1
+	}
+	// Fixed-point weight, so both ends are exact.
+	w := uint32(math.Round(t * 256))
+	dst := image.NewRGBA(image.Rectangle{Max: from.Rect.Size()})
+	for y := 0; y < dst.Rect.Dy(); y++ {
+		fromRow := rgbaRow(from, from.Rect.Min.Y+y)
+		toRow := rgbaRow(to, to.Rect.Min.Y+y)
+		dstRow := rgbaRow(dst, y)
+		for x := range dstRow {
+			dstRow[x] = uint8((uint32(fromRow[x])*(256-w) + uint32(toRow[x])*w + 128) >> 8)
+		}
+	}
+	return dst
+}
+
+// OnLastFrame returns whether the current frame (the frame the next call to Frame will return) is the last of a cycle
+// in the direction of playback: the Mode's last frame, or when playing in reverse (see SetSpeedScale), its first. This
+// includes when the animation has Finished, as it then holds on that frame (see SetLoopCount). With random playback
+// (see SetRandomPlayback) no frame is last, and it returns false.
+func (a *animation) OnLastFrame() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.random || a.FrameCount() == 0 {
+		return false
+	}
+	index := wrapIndex(a.currentFrame, a.FrameCount())
+	if a.speedScale < 0 {
+		return index == 0
+	}
+	return index == a.FrameCount()-1
+}
+
+// IsInActiveWindow returns whether the current frame (the frame the next call to Frame will return) is within the
+// current Mode's active window. See Mode.SetActiveWindow.
+func (a *animation) IsInActiveWindow() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.FrameInActiveWindow(wrapIndex(a.currentFrame, a.FrameCount()))
 }
 
 func (a *animation) Advance() {
+	a.mu.Lock()
+	defer a.unlock()
+	a.advance()
+}
+
+// Step moves the animation exactly n frames (backward if n is negative), regardless of the speed scale (and any
+// progress toward the next frame; see SetSpeedScale), for callers driving the animation frame by frame. Unlike Advance,
+// it moves the animation even if it is not running, and does not start it. Otherwise it behaves as Advance does when
+// moving whole frames: it wraps around (completing cycles; see SetLoopCount and Instance.QueueModes), follows random
+// playback, and triggers OnFrame callbacks.
+func (a *animation) Step(n int) {
+	a.mu.Lock()
+	defer a.unlock()
+	a.step(float64(n))
+}
+
+func (a *animation) advance() {
 	if a.running {
-		a.currentFrame++
+		a.progress += a.speedScale
+		// Advance by the whole frames accumulated (toward zero, so reverse playback works the same way), keeping the
+		// fractional remainder.
+		whole := math.Trunc(a.progress)
+		a.progress -= whole
+		a.step(whole)
+	}
+}
+
+// step moves the animation whole frames (a whole number), forward or (if negative) backward.
+func (a *animation) step(whole float64) {
+	if a.FrameCount() == 0 {
+		return
+	}
+	if a.random {
+		if whole != 0 {
+			a.advanceRandom(math.Abs(whole))
+		}
+	} else {
+		next := wrapIndex(a.currentFrame, a.FrameCount()) + int(whole)
 		// We do this after as well so that any changes to the Mode frame count before the next call to Frame will
 		// result in the appropriate next frame
-		a.currentFrame %= a.FrameCount()
+		a.currentFrame = wrapIndex(next, a.FrameCount())
+		a.cyclesCompleted(cyclesCrossed(next, a.FrameCount()))
+	}
+	if whole != 0 && !a.finished {
+		if fn, ok := a.onFrame[a.currentFrame]; ok {
+			a.fired = append(a.fired, fn)
+		}
+	}
+}
+
+// advanceRandom advances the animation by steps frames in random playback, moving to a random frame. A cycle is
+// completed for each FrameCount() frames advanced.
+func (a *animation) advanceRandom(steps float64) {
+	if a.rng != nil {
+		a.currentFrame = a.rng.Intn(a.FrameCount())
+	} else {
+		a.currentFrame = rand.Intn(a.FrameCount())
+	}
+	a.randomSteps += steps
+	cycles := math.Floor(a.randomSteps / float64(a.FrameCount()))
+	a.randomSteps -= cycles * float64(a.FrameCount())
+	a.cyclesCompleted(int(cycles))
+}
+
+// SetRandomPlayback sets whether the animation plays its frames in a random order: when enabled, each time the
+// animation advances (by at least a whole frame; see SetSpeedScale) it moves to a frame chosen at random, which may be
+// the same frame again - useful for flames, sparkles and the like, so that many Instances don't visibly play in sync.
+// A cycle (see SetLoopCount and Instance.QueueModes) is counted for every FrameCount() frames advanced.
+// Random frames are chosen using rng, or if it is nil, the math/rand package's default source. As the animation uses
+// rng while holding its lock, an rng shared between Instances must not be used concurrently by them. A seeded rng gives
+// a reproducible sequence.
+func (a *animation) SetRandomPlayback(enabled bool, rng *rand.Rand) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.random = enabled
+	a.rng = rng
+	a.randomSteps = 0
+}
+
+// unlock releases mu, and then calls any OnFrame and OnModeChange callbacks triggered while it was held. It is used in
+// place of mu.Unlock by methods which advance the animation or change its Mode, so that callbacks may safely call
+// methods of the animation.
+func (a *animation) unlock() {
+	fired := a.fired
+	a.fired = nil
+	a.mu.Unlock()
+	for _, fn := range fired {
+		fn()
+	}
+}
+
+// OnFrame registers fn to be called each time the animation advances onto the frame at index (of whichever Mode is
+// current), replacing any callback previously registered for index; a nil fn removes it. fn is called once per entry
+// onto the frame - not again while the animation remains on it (e.g. while stopped, or advancing by less than a whole
+// frame per call; see SetSpeedScale) - including when it is entered by wrapping around or switching to a queued Mode.
+// Frames skipped over (at speed scales above 1) and jumps made by SetCurrentFrame, RestartAnimation, etc. do not
+// trigger callbacks. fn is called from within Frame, Advance, PlaceOn, etc., after the frame has been advanced.
+func (a *animation) OnFrame(index int, fn func()) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if fn == nil {
+		delete(a.onFrame, index)
+		return
+	}
+	if a.onFrame == nil {
+		a.onFrame = make(map[int]func())
+	}
+	a.onFrame[index] = fn
+}
+
+// SetOnModeChange sets fn to be called whenever the animation's Mode changes, with the names of the previous and new
+// Modes; a nil fn (the default) removes it. It is called for changes made by Instance.SetModeByIndex and
+// Instance.SetModeByName, by ModeStateMachine transitions, and when a Mode queued by Instance.QueueModes starts, but not
+// when the Mode is "changed" to the current Mode. fn is called after the change is made, once the Instance is unlocked,
+// so it may safely call the Instance's methods.
+func (a *animation) SetOnModeChange(fn func(oldMode, newMode string)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.onModeChange = fn
+}
+
+// switchMode makes mode the current Mode. If that is a change, any OnModeChange callback is queued to be called by
+// unlock.
+func (a *animation) switchMode(mode *Mode) {
+	if mode == a.Mode {
+		return
+	}
+	old := a.Mode
+	a.Mode = mode
+	if fn := a.onModeChange; fn != nil {
+		oldName, newName := old.name, mode.name
+		a.fired = append(a.fired, func() { fn(oldName, newName) })
 	}
 }
+
+// cyclesCompleted calls cycleCompleted n times, or until the animation finishes or a queued Mode starts, as the rest of
+// the cycles then no longer apply.
+func (a *animation) cyclesCompleted(n int) {
+	queued := len(a.queue)
+	for ; n > 0 && !a.finished && len(a.queue) == queued; n-- {
+		a.cycleCompleted()
+	}
+}
+
+// cyclesCrossed returns the number of times moving to frame index next (which may be out of the range [0, count))
+// from a frame in that range wraps around the Mode's count frames, in either direction.
+func cyclesCrossed(next, count int) int {
+	if next >= 0 {
+		return next / count
+	}
+	return (-next-1)/count + 1
+}
+
+// cycleCompleted is called when the animation wraps around past the end (or, in reverse, the start) of the current
+// Mode's frames.
+func (a *animation) cycleCompleted() {
+	a.loopsCompleted++
+	if len(a.queue) > 0 {
+		a.switchMode(a.queue[0])
+		a.queue = a.queue[1:]
+		a.currentFrame = 0
+		a.loopsCompleted = 0
+	} else if a.loopCount > 0 && a.loopsCompleted >= a.loopCount {
+		// Hold on the final frame of the cycle.
+		if a.speedScale < 0 {
+			a.currentFrame = 0
+		} else {
+			a.currentFrame = a.FrameCount() - 1
+		}
+		a.progress = 0
+		a.running = false
+		a.finished = true
+	}
+}
+
+// SetLoopCount sets the number of times the animation plays through the current Mode before stopping (holding on
+// the last frame, or the first if playing in reverse) and being Finished. n <= 0 (the default) loops forever; n = 1
+// plays once. A cycle is counted each time the animation wraps around to the start of the Mode. The count of completed
+// cycles is reset by this, by RestartAnimation and ResetAnimation, and when the Mode changes.
+func (a *animation) SetLoopCount(n int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.loopCount = n
+	a.loopsCompleted = 0
+	a.finished = false
+}
+
+// LoopCount returns the number of times the animation plays before finishing, or <= 0 if it loops forever. See
+// SetLoopCount.
+func (a *animation) LoopCount() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.loopCount
+}
+
+// Finished returns whether the animation stopped itself after completing the number of cycles set by SetLoopCount.
+// It is reset when the animation is started, restarted or reset.
+func (a *animation) Finished() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.finished
+}
+
+// PlaybackState is the playback state of an animation, as returned by State.
+type PlaybackState int
+
+const (
+	// Stopped means the animation is not running and not Paused or Finished: it has not been started, or it was
+	// stopped by StopAnimation or ResetAnimation.
+	Stopped PlaybackState = iota
+	// Playing means the animation is running, so Frame and Advance advance it.
+	Playing
+	// Paused means the animation was running and was then paused by PauseAnimation, so ResumeAnimation will continue
+	// it exactly where it left off.
+	Paused
+	// Finished means the animation stopped itself after completing the number of cycles set by SetLoopCount.
+	Finished
+)
+
+// String returns the name of the state.
+func (p PlaybackState) String() string {
+	switch p {
+	case Stopped:
+		return "stopped"
+	case Playing:
+		return "playing"
+	case Paused:
+		return "paused"
+	case Finished:
+		return "finished"
+	default:
+		return fmt.Sprintf("PlaybackState(%d)", int(p))
+	}
+}
+
+// State returns the animation's playback state:
+//   - Finished if it has Finished (see SetLoopCount), until it is started, restarted or reset, or its loop count is
+//     set again.
+//   - Otherwise, Playing if it is Running: after StartAnimation, ResumeAnimation or RestartAnimation.
+//   - Otherwise, Paused if it was running when PauseAnimation was last called, and has not since been started, resumed,
+//     stopped or reset.
+//   - Otherwise, Stopped: when it is new, and after StopAnimation or ResetAnimation.
+//
+// Changing the Mode, speed scale or current frame does not change the state.
+func (a *animation) State() PlaybackState {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switch {
+	case a.finished:
+		return Finished
+	case a.running:
+		return Playing
+	case a.paused:
+		return Paused
+	default:
+		return Stopped
+	}
+}
+
+// transition switches to mode, starting from its first frame, and discards any queued Modes. If afterCycle is set and
+// the animation is running, mode instead replaces any queued Modes, so that it starts once the current Mode completes
+// its cycle; an animation which is not running would never complete it. If the animation has Finished, it is
+// restarted in mode.
+func (a *animation) transition(mode *Mode, afterCycle bool) {
+	a.mu.Lock()
+	defer a.unlock()
+	if afterCycle && a.running {
+		a.queue = []*Mode{mode}
+		return
+	}
+	a.switchMode(mode)
+	if a.finished {
+		a.restart()
+	} else {
+		a.currentFrame = 0
+		a.progress = 0
+		a.loopsCompleted = 0
+	}
+	a.queue = nil
+}
+
+// ClearQueue discards any Modes queued by Instance.QueueModes which have not yet started. The current Mode continues
+// to play (looping).
+func (a *animation) ClearQueue() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.queue = nil
+}
+
+// SpeedScale returns the animation's speed scale. See SetSpeedScale.
+func (a *animation) SpeedScale() float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.speedScale
+}
+
+// SetSpeedScale sets the number of frames the animation advances each time it is advanced (by Advance or Frame).
+// The default, 1, advances one frame per call. Fractional scales accumulate progress across calls, advancing a frame
+// whenever it reaches a whole frame: 0.5 advances every other call (slow motion), 1.5 advances 1 then 2 frames
+// alternately (haste). A negative scale plays the animation in reverse, and 0 holds the current frame (while the
+// animation is still considered running). The speed scale belongs to the animation - that is, to the Instance, not
+// its Mode - so Instances of the same Entity may play at different speeds, and it persists when changing Modes. NaN and
+// infinite scales are ignored.
+func (a *animation) SetSpeedScale(scale float64) {
+	if math.IsNaN(scale) || math.IsInf(scale, 0) {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.speedScale = scale
+}
+
+// CycleTicks returns the number of times the animation must be advanced (by Advance or Frame) to play the current
+// Mode through once, that is FrameCount() divided by the absolute speed scale, rounded up. It is 0 if the speed scale
+// is 0, as the animation then never advances.
+func (a *animation) CycleTicks() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.cycleTicks()
+}
+
+func (a *animation) cycleTicks() int {
+	if a.speedScale == 0 {
+		return 0
+	}
+	// The small tolerance avoids rounding up due to floating point error, e.g. 4 / (1.0/3) = 12.000000000000002.
+	return int(math.Ceil(float64(a.FrameCount())/math.Abs(a.speedScale) - 1e-9))
+}
+
+// CycleDuration returns how long the current Mode takes to play through once, when the animation is advanced once
+// every tick. See CycleTicks.
+func (a *animation) CycleDuration(tick time.Duration) time.Duration {
+	return time.Duration(a.CycleTicks()) * tick
+}
+
+// TicksUntilAdvance returns the number of times the animation can be advanced (by Advance or Frame) before the one
+// which moves it to another frame: 0 means the next advance does so. At a speed scale of 1 it is always 0; at 0.25 it
+// counts down 3, 2, 1, 0 (see SetSpeedScale). It is -1 if the animation is not running or the speed scale is 0, as
+// the animation then never advances.
+func (a *animation) TicksUntilAdvance() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.running || a.speedScale == 0 {
+		return -1
+	}
+	// progress must reach a whole frame in the direction of play; any progress in the opposite direction (left from
+	// before the speed scale was negated) must be undone first. The small tolerance avoids rounding up due to floating
+	// point error, as in cycleTicks.
+	done := a.progress
+	if a.speedScale < 0 {
+		done = -done
+	}
+	remaining := 1 - done
+	return int(math.Ceil(remaining/math.Abs(a.speedScale)-1e-9)) - 1
+}
+
+// TimeUntilAdvance returns how long until the animation moves to another frame, when it is advanced once every tick
+// (with the next advance taking place now), that is TicksUntilAdvance() ticks. It is negative if the animation never
+// advances. See also CycleDuration.
+func (a *animation) TimeUntilAdvance(tick time.Duration) time.Duration {
+	ticks := a.TicksUntilAdvance()
+	if ticks < 0 {
+		return -1
+	}
+	return time.Duration(ticks) * tick
+}
+
+// NextFrameDiff returns whether the next call to Advance (or Frame) will change the frame Frame returns: that is,
+// whether the animation is running and will accumulate a whole frame of progress (see SetSpeedScale), and moving by
+// it will land on a different frame, or switch to a queued Mode (see Instance.QueueModes). It is false if the animation
+// is paused, stopped or Finished, and when a one-shot (see SetLoopCount) completing its last cycle would hold on the
+// frame it is already on. With random playback (see SetRandomPlayback) it is true whenever the animation will move,
+// though the randomly chosen frame may be the same one. Unlike TicksUntilAdvance, it mirrors the arithmetic of
+// Advance exactly, so it is true on exactly the advance which changes the frame, e.g. to synchronize sound with it.
+func (a *animation) NextFrameDiff() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	count := a.FrameCount()
+	if !a.running || count == 0 {
+		return false
+	}
+	whole := math.Trunc(a.progress + a.speedScale)
+	if whole == 0 {
+		return false
+	}
+	if a.random {
+		return true
+	}
+	current := wrapIndex(a.currentFrame, count)
+	next := current + int(whole)
+	if cycles := cyclesCrossed(next, count); cycles > 0 {
+		if len(a.queue) > 0 {
+			return true
+		}
+		if a.loopCount > 0 && a.loopsCompleted+cycles >= a.loopCount {
+			held := count - 1
+			if a.speedScale < 0 {
+				held = 0
+			}
+			return held != current
+		}
+	}
+	return wrapIndex(next, count) != current
+}
+
+// FrameAtTicks returns the index of the frame the animation would be on (that is, the frame Frame would return) after
+// being advanced ticks times from its start - its first frame, with no progress toward the next - given the current
+// Mode's frame count and the animation's speed scale and loop count (see SetSpeedScale and SetLoopCount). It is
+// computed directly rather than by stepping, and does not change the animation, so it suits e.g. rendering an
+// arbitrary moment for a scrub bar. Queued Modes (see Instance.QueueModes) are not taken into account. ticks < 0 is
+// treated as 0. With random playback (see SetRandomPlayback) the frame cannot be predicted, and -1 is returned.
+// For speed scales which are not exact binary fractions (e.g. 0.1), the progress accumulated by actual playback is
+// subject to floating point error, so may reach a whole frame one advance later than the exact result computed here.
+func (a *animation) FrameAtTicks(ticks int) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.random {
+		return -1
+	}
+	count := a.FrameCount()
+	if ticks <= 0 || a.speedScale == 0 || count == 0 {
+		return 0
+	}
+	// The whole frames advanced. As in cycleTicks, the small tolerance avoids truncating due to floating point error.
+	whole := int(math.Floor(float64(ticks)*math.Abs(a.speedScale) + 1e-9))
+	if a.speedScale > 0 {
+		// A cycle is completed on wrapping from the last frame to the first.
+		if a.loopCount > 0 && whole/count >= a.loopCount {
+			return count - 1
+		}
+		return whole % count
+	}
+	// In reverse, a cycle is completed on wrapping from the first frame to the last, which happens on the first step.
+	if a.loopCount > 0 && (whole+count-1)/count >= a.loopCount {
+		return 0
+	}
+	return wrapIndex(-whole, count)
+}
+
+// FrameAtElapsed is like FrameAtTicks, but returns the frame the animation would be on elapsed after its start, when it
+// is advanced once every tick (with the first advance taking place tick after the start). See also CycleDuration.
+func (a *animation) FrameAtElapsed(elapsed, tick time.Duration) int {
+	if tick <= 0 {
+		return a.FrameAtTicks(0)
+	}
+	return a.FrameAtTicks(int(elapsed / tick))
+}
+
+// wrapIndex returns index wrapped into the range [0, count), including for negative indexes. If count is 0, it returns
+// 0.
+func wrapIndex(index, count int) int {
+	if count == 0 {
+		return 0
+	}
+	index %= count
+	if index < 0 {
+		index += count
+	}
+	return index
+}
+
+// setMode changes the animation's current Mode, discarding any queued Modes.
+func (a *animation) setMode(mode *Mode) {
+	a.mu.Lock()
+	defer a.unlock()
+	a.switchMode(mode)
+	a.loopsCompleted = 0
+	a.queue = nil
+}
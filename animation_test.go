@@ -0,0 +1,760 @@
+package sprites
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestAnimationConcurrent exercises an Instance from several goroutines at once; run with -race.
+func TestAnimationConcurrent(t *testing.T) {
+	i := mustInstance(t, mustEntity(t, mustSheet(t), 0), 0)
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for k := 0; k < 1000; k++ {
+			i.Frame()
+			i.IsInActiveWindow()
+			i.CurrentFrameIndex()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for k := 0; k < 1000; k++ {
+			i.StartAnimation()
+			i.SetSpeedScale(float64(k%4) / 2)
+			i.StopAnimation()
+			i.Running()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for k := 0; k < 1000; k++ {
+			if err := i.SetModeByIndex(k % 3); err != nil {
+				t.Error(err)
+				return
+			}
+			i.Clone()
+		}
+	}()
+	wg.Wait()
+}
+
+func TestSetCurrentFrame(t *testing.T) {
+	i := mustInstance(t, mustEntity(t, mustSheet(t), 0), 0)
+	for _, index := range []int{-1, 4} {
+		if err := i.SetCurrentFrame(index); err == nil {
+			t.Fatalf("SetCurrentFrame(%d) accepted with 4 frames", index)
+		}
+	}
+	if i.CurrentFrameIndex() != 0 {
+		t.Fatalf("failed SetCurrentFrame moved to frame %d", i.CurrentFrameIndex())
+	}
+
+	if err := i.SetCurrentFrame(3); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := i.GetFrame(3)
+	if i.CurrentFrameIndex() != 3 || i.Running() || i.Frame() != want {
+		t.Fatalf("after SetCurrentFrame(3): frame %d, running %v", i.CurrentFrameIndex(), i.Running())
+	}
+
+	i.StartAnimation()
+	if err := i.SetCurrentFrame(2); err != nil {
+		t.Fatal(err)
+	}
+	want, _ = i.GetFrame(2)
+	if !i.Running() || i.Frame() != want {
+		t.Fatal("SetCurrentFrame(2) on a running animation did not make frame 2 the next frame, or stopped it")
+	}
+}
+
+// framesAdvanced returns the total number of frames a running Instance of a 4 frame Mode advances over calls calls to
+// Advance at the given speed scale. Each call must advance fewer than 4 frames.
+func framesAdvanced(t *testing.T, scale float64, calls int) int {
+	i := mustInstance(t, mustEntity(t, mustSheet(t), 0), 0)
+	i.StartAnimation()
+	i.SetSpeedScale(scale)
+	total := 0
+	for n := 0; n < calls; n++ {
+		before := i.CurrentFrameIndex()
+		i.Advance()
+		total += wrapIndex(i.CurrentFrameIndex()-before, 4)
+	}
+	return total
+}
+
+func TestSpeedScale(t *testing.T) {
+	for _, c := range []struct {
+		scale       float64
+		calls, want int
+	}{{1, 100, 100}, {0.5, 100, 50}, {2, 100, 200}, {1.5, 4, 6}, {1.0 / 3, 300, 100}} {
+		if got := framesAdvanced(t, c.scale, c.calls); got != c.want {
+			t.Fatalf("%d calls at %gx advanced %d frames, want %d", c.calls, c.scale, got, c.want)
+		}
+	}
+
+	i := mustInstance(t, mustEntity(t, mustSheet(t), 0), 0)
+	i.StartAnimation()
+	i.SetSpeedScale(-1)
+	i.Advance()
+	if i.CurrentFrameIndex() != 3 {
+		t.Fatalf("reverse playback advanced from frame 0 to %d, want 3", i.CurrentFrameIndex())
+	}
+	if i.Clone().SpeedScale() != -1 {
+		t.Fatal("Clone did not copy the speed scale")
+	}
+}
+
+func TestCycleTicks(t *testing.T) {
+	e := mustEntity(t, mustSheet(t), 0)
+	for _, c := range []struct {
+		scale float64
+		want  int
+	}{{1, 4}, {0.5, 8}, {1.0 / 3, 12}, {2, 2}, {-1, 4}, {0.25, 16}} {
+		i := mustInstance(t, e, 0)
+		i.StartAnimation()
+		i.SetSpeedScale(c.scale)
+		if got := i.CycleTicks(); got != c.want {
+			t.Fatalf("CycleTicks at %gx is %d, want %d", c.scale, got, c.want)
+		}
+		// Count the advances until the animation is back at the start of frame 0.
+		n := 0
+		for {
+			i.Advance()
+			n++
+			if i.CurrentFrameIndex() == 0 && i.FrameProgress() == 0 {
+				break
+			}
+		}
+		if n != c.want {
+			t.Fatalf("%d advances to play through once at %gx, but CycleTicks is %d", n, c.scale, c.want)
+		}
+	}
+
+	i := mustInstance(t, e, 0)
+	if got := i.CycleDuration(time.Second); got != 4*time.Second {
+		t.Fatalf("CycleDuration at 1s ticks is %v, want 4s", got)
+	}
+	i.SetSpeedScale(0)
+	if i.CycleTicks() != 0 {
+		t.Fatalf("CycleTicks at speed scale 0 is %d, want 0", i.CycleTicks())
+	}
+}
+
+// framesUntilFinished returns the number of calls to Frame until i stops running, failing t if it runs for more than
+// limit calls.
+func framesUntilFinished(t *testing.T, i *Instance, limit int) int {
+	t.Helper()
+	n := 0
+	for i.Running() {
+		if n == limit {
+			t.Fatalf("still running after %d frames", limit)
+		}
+		i.Frame()
+		n++
+	}
+	return n
+}
+
+func TestLoopCount(t *testing.T) {
+	e := mustEntity(t, mustSheet(t), 0)
+	for _, loops := range []int{1, 3} {
+		i := mustInstance(t, e, 0)
+		i.SetLoopCount(loops)
+		i.StartAnimation()
+		if n := framesUntilFinished(t, i, 100); n != 4*loops {
+			t.Fatalf("%d loops of 4 frames stopped after %d frames", loops, n)
+		}
+		if !i.Finished() || i.CurrentFrameIndex() != 3 {
+			t.Fatalf("%d loops: finished %v on frame %d, want finished on frame 3", loops, i.Finished(), i.CurrentFrameIndex())
+		}
+		i.StartAnimation()
+		if i.Finished() || !i.Running() || i.CurrentFrameIndex() != 0 {
+			t.Fatalf("%d loops: StartAnimation did not restart the finished animation", loops)
+		}
+	}
+
+	// RestartAnimation part way through clears the loops completed so far.
+	i := mustInstance(t, e, 0)
+	i.SetLoopCount(2)
+	i.StartAnimation()
+	for n := 0; n < 6; n++ {
+		i.Frame()
+	}
+	i.RestartAnimation()
+	if n := framesUntilFinished(t, i, 100); n != 8 {
+		t.Fatalf("2 loops after RestartAnimation stopped after %d frames, want 8", n)
+	}
+}
+
+func TestOnFrame(t *testing.T) {
+	i := mustInstance(t, mustEntity(t, mustSheet(t), 0), 0)
+	fired := 0
+	// The callback may use the Instance.
+	i.OnFrame(2, func() {
+		fired++
+		i.CurrentFrameIndex()
+	})
+	i.StartAnimation()
+	i.Advance()
+	i.Advance()
+	if fired != 1 || i.CurrentFrameIndex() != 2 {
+		t.Fatalf("advancing onto frame 2 fired %d times, want 1", fired)
+	}
+
+	// Staying on the frame, whether stopped or part way to the next frame, does not fire again.
+	i.StopAnimation()
+	i.Advance()
+	i.Frame()
+	i.StartAnimation()
+	i.SetSpeedScale(0.25)
+	i.Advance()
+	i.Advance()
+	if fired != 1 {
+		t.Fatalf("staying on frame 2 fired %d times, want 1", fired)
+	}
+
+	// Wrapping around onto the frame again fires once more.
+	i.SetSpeedScale(1)
+	for n := 0; n < 4; n++ {
+		i.Advance()
+	}
+	if fired != 2 {
+		t.Fatalf("looping back onto frame 2 fired %d times in total, want 2", fired)
+	}
+
+	// In reverse, frames 1 and 0 are entered from above.
+	firedZero := 0
+	i.OnFrame(0, func() { firedZero++ })
+	i.SetSpeedScale(-1)
+	for n := 0; n < 3; n++ {
+		i.Advance()
+	}
+	if fired != 2 || firedZero != 1 {
+		t.Fatalf("reverse from frame 2 to 3 fired frame 2 %d times and frame 0 %d times, want 2 and 1", fired, firedZero)
+	}
+}
+
+func TestPauseAnimation(t *testing.T) {
+	e := mustEntity(t, mustSheet(t), 0)
+	paused, steady := mustInstance(t, e, 0), mustInstance(t, e, 0)
+	for _, i := range []*Instance{paused, steady} {
+		i.SetSpeedScale(0.5)
+		i.StartAnimation()
+		for n := 0; n < 3; n++ {
+			i.Advance()
+		}
+	}
+	// Part way between frames 1 and 2, pausing (and advancing while paused) must not lose the progress.
+	paused.PauseAnimation()
+	paused.Advance()
+	if paused.Running() || paused.CurrentFrameIndex() != 1 || paused.FrameProgress() != 0.5 {
+		t.Fatalf("paused at frame %d with progress %g, running %v", paused.CurrentFrameIndex(), paused.FrameProgress(),
+			paused.Running())
+	}
+	paused.ResumeAnimation()
+	for n := 0; n < 5; n++ {
+		paused.Advance()
+		steady.Advance()
+		if paused.CurrentFrameIndex() != steady.CurrentFrameIndex() || paused.FrameProgress() != steady.FrameProgress() {
+			t.Fatalf("advance %d after resuming: at frame %d + %g, want frame %d + %g", n, paused.CurrentFrameIndex(),
+				paused.FrameProgress(), steady.CurrentFrameIndex(), steady.FrameProgress())
+		}
+	}
+
+	// Stopping, by contrast, discards the progress.
+	paused.StopAnimation()
+	if paused.FrameProgress() != 0 {
+		t.Fatalf("progress %g after StopAnimation, want 0", paused.FrameProgress())
+	}
+}
+
+func TestFrameBlended(t *testing.T) {
+	e := mustEntity(t, mustSheet(t), 0)
+	i := mustInstance(t, e, 0)
+	frame := func(index int) *image.RGBA {
+		f, _ := mustMode(t, e, 0).GetFrame(index)
+		return f.(*image.RGBA)
+	}
+	if !rgbaEqual(i.FrameBlended(0), frame(0)) || !rgbaEqual(i.FrameBlended(1), frame(1)) {
+		t.Fatal("blend at 0 or 1 is not the current or next frame")
+	}
+	// t is clamped.
+	if !rgbaEqual(i.FrameBlended(-1), frame(0)) || !rgbaEqual(i.FrameBlended(2), frame(1)) {
+		t.Fatal("blend outside [0,1] not clamped")
+	}
+	// In reverse, the next frame is the previous one.
+	i.SetSpeedScale(-1)
+	if !rgbaEqual(i.FrameBlended(1), frame(3)) {
+		t.Fatal("blend at 1 in reverse is not the previous frame")
+	}
+	if i.CurrentFrameIndex() != 0 {
+		t.Fatal("FrameBlended advanced the animation")
+	}
+
+	// Blending is in premultiplied space, so opaque red fading to transparent stays red, without a dark halo.
+	red, clear := filledImage(1, 1, color.RGBA{R: 255, A: 255}), image.NewRGBA(image.Rect(0, 0, 1, 1))
+	if c := blendFrames(red, clear, 0.5).RGBAAt(0, 0); c != (color.RGBA{R: 128, A: 128}) {
+		t.Fatalf("half blend of red and transparent is %v, want {128 0 0 128}", c)
+	}
+}
+
+func TestRandomPlayback(t *testing.T) {
+	e := mustEntity(t, mustSheet(t), 0)
+	// frames returns the frame after each of n advances, in random playback with a source seeded with 42.
+	frames := func(scale float64, n int) []int {
+		i := mustInstance(t, e, 0)
+		i.SetRandomPlayback(true, rand.New(rand.NewSource(42)))
+		i.SetSpeedScale(scale)
+		i.StartAnimation()
+		got := make([]int, n)
+		for k := range got {
+			i.Advance()
+			got[k] = i.CurrentFrameIndex()
+		}
+		return got
+	}
+	rng := rand.New(rand.NewSource(42))
+	want := make([]int, 10)
+	for k := range want {
+		want[k] = rng.Intn(4)
+	}
+	if got := frames(1, 10); !reflect.DeepEqual(got, want) {
+		t.Fatalf("seeded random frames are %v, want %v", got, want)
+	}
+	// At half speed, a random frame is chosen only every other advance.
+	for k, frame := range frames(0.5, 20) {
+		if chosen := (k + 1) / 2; chosen == 0 && frame != 0 || chosen > 0 && frame != want[chosen-1] {
+			t.Fatalf("random frame after %d advances at half speed is %d", k+1, frame)
+		}
+	}
+
+	// Cycles are counted every FrameCount() frames advanced.
+	i := mustInstance(t, e, 0)
+	i.SetRandomPlayback(true, nil)
+	i.SetLoopCount(2)
+	i.StartAnimation()
+	if n := framesUntilFinished(t, i, 100); n != 8 {
+		t.Fatalf("random playback of 2 loops of 4 frames finished after %d advances, want 8", n)
+	}
+}
+
+func TestFrameCopy(t *testing.T) {
+	e := mustEntity(t, mustSheet(t), 3)
+	m := mustMode(t, e, 1)
+	i := mustInstance(t, e, 1)
+	i.SetCurrentFrame(2)
+	for _, c := range []struct {
+		name string
+		copy func() *image.RGBA
+	}{
+		{"Instance", i.FrameCopy},
+		{"Mode", func() *image.RGBA {
+			frame, err := m.FrameCopy(2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return frame
+		}},
+	} {
+		frame := c.copy()
+		if frame.Rect != image.Rect(0, 0, 4, 4) {
+			t.Fatalf("%s FrameCopy bounds are %v, want (0,0)-(4,4)", c.name, frame.Rect)
+		}
+		want := cellColor(4, 6)
+		if got := frame.RGBAAt(0, 0); got != want {
+			t.Fatalf("%s FrameCopy pixel is %v, want %v", c.name, got, want)
+		}
+		// Modifying the copy leaves the frame, and so the sheet image, unchanged.
+		draw.Draw(frame, frame.Rect, image.Transparent, image.Point{}, draw.Src)
+		if got := m.GetFrameWrapped(2).At(4*4, 6*4); got != want {
+			t.Fatalf("modifying the %s FrameCopy changed the frame to %v", c.name, got)
+		}
+	}
+	if i.CurrentFrameIndex() != 2 {
+		t.Error("FrameCopy advanced the animation")
+	}
+	if _, err := m.FrameCopy(4); err == nil {
+		t.Error("FrameCopy of an out of range frame did not fail")
+	}
+}
+
+func TestTicksUntilAdvance(t *testing.T) {
+	i := mustInstance(t, mustEntity(t, mustSheet(t), 0), 0)
+	if n := i.TicksUntilAdvance(); n != -1 {
+		t.Fatalf("TicksUntilAdvance of a stopped animation is %d, want -1", n)
+	}
+	i.StartAnimation()
+	if n := i.TicksUntilAdvance(); n != 0 {
+		t.Fatalf("TicksUntilAdvance at 1x is %d, want 0", n)
+	}
+	// The countdown matches the advances observed, including after changes of speed.
+	for _, scale := range []float64{0.25, 1.0 / 3, -0.5, 0.4, 1} {
+		i.SetSpeedScale(scale)
+		for k := 0; k < 20; k++ {
+			n, before := i.TicksUntilAdvance(), i.CurrentFrameIndex()
+			for tick := 0; tick < n; tick++ {
+				i.Frame()
+				if i.CurrentFrameIndex() != before {
+					t.Fatalf("at %gx, moved frame after %d of %d ticks", scale, tick+1, n)
+				}
+			}
+			i.Frame()
+			if i.CurrentFrameIndex() == before {
+				t.Fatalf("at %gx, did not move frame after %d ticks", scale, n+1)
+			}
+		}
+	}
+	// Progress made in one direction must be undone after reversing.
+	i.SetSpeedScale(0.5)
+	i.Frame()
+	i.SetSpeedScale(-0.5)
+	if n := i.TicksUntilAdvance(); n != 2 {
+		t.Fatalf("TicksUntilAdvance after reversing is %d, want 2", n)
+	}
+
+	i.RestartAnimation()
+	i.SetSpeedScale(0.25)
+	if n := i.TicksUntilAdvance(); n != 3 {
+		t.Fatalf("TicksUntilAdvance after restarting at 0.25x is %d, want 3", n)
+	}
+	if d := i.TimeUntilAdvance(10 * time.Millisecond); d != 30*time.Millisecond {
+		t.Fatalf("TimeUntilAdvance is %v, want 30ms", d)
+	}
+	i.SetSpeedScale(0)
+	if n, d := i.TicksUntilAdvance(), i.TimeUntilAdvance(time.Second); n != -1 || d >= 0 {
+		t.Fatalf("at 0x, TicksUntilAdvance is %d and TimeUntilAdvance %v, want -1 and negative", n, d)
+	}
+}
+
+func TestCurrentFrame(t *testing.T) {
+	e := mustEntity(t, mustSheet(t), 0)
+	i := mustInstance(t, e, 0)
+	i.StartAnimation()
+	i.Frame()
+	want, _ := mustMode(t, e, 0).GetFrame(1)
+	canvas := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for n := 0; n < 10; n++ {
+		if i.CurrentFrame() != want || i.CurrentFrameIndex() != 1 {
+			t.Fatalf("CurrentFrame call %d advanced the animation", n+1)
+		}
+		i.PlaceOnNoAdvance(canvas, image.Point{})
+		if i.CurrentFrameIndex() != 1 {
+			t.Fatalf("PlaceOnNoAdvance call %d advanced the animation", n+1)
+		}
+	}
+	if c := canvas.RGBAAt(0, 0); c != cellColor(0, 1) {
+		t.Errorf("PlaceOnNoAdvance drew %v, want frame 1's %v", c, cellColor(0, 1))
+	}
+	// Frame returns the same frame, and then advances.
+	if i.Frame() != want || i.CurrentFrameIndex() != 2 {
+		t.Error("Frame does not return the current frame and advance")
+	}
+}
+
+func TestStep(t *testing.T) {
+	i := mustInstance(t, mustEntity(t, mustSheet(t), 0), 0)
+	// Step ignores the speed scale, and moves a stopped animation without starting it.
+	i.SetSpeedScale(0.25)
+	var fired []int
+	for f := 0; f < 4; f++ {
+		f := f
+		i.OnFrame(f, func() { fired = append(fired, f) })
+	}
+	for _, c := range []struct{ n, want int }{{3, 3}, {2, 1}, {-3, 2}, {0, 2}, {8, 2}} {
+		i.Step(c.n)
+		if got := i.CurrentFrameIndex(); got != c.want {
+			t.Fatalf("Step(%d) moved to frame %d, want %d", c.n, got, c.want)
+		}
+	}
+	if i.Running() {
+		t.Fatal("Step started the animation")
+	}
+	if want := []int{3, 1, 2, 2}; !reflect.DeepEqual(fired, want) {
+		t.Fatalf("OnFrame callbacks fired for frames %v, want %v", fired, want)
+	}
+
+	// Wrapping completes cycles.
+	i = mustInstance(t, mustEntity(t, mustSheet(t), 0), 0)
+	i.SetLoopCount(2)
+	i.StartAnimation()
+	i.Step(7)
+	if i.Finished() {
+		t.Fatal("Finished after 7 of 8 frames")
+	}
+	i.Step(1)
+	if !i.Finished() {
+		t.Fatal("not Finished after 8 of 8 frames")
+	}
+	// So does moving several cycles at once, by Step or by Advance at a high speed.
+	for _, step := range []func(i *Instance){
+		func(i *Instance) { i.Step(9) },
+		func(i *Instance) { i.Step(-8) },
+		func(i *Instance) { i.SetSpeedScale(9); i.Advance() },
+	} {
+		i := mustInstance(t, mustEntity(t, mustSheet(t), 0), 0)
+		i.SetLoopCount(2)
+		i.StartAnimation()
+		step(i)
+		if !i.Finished() {
+			t.Fatalf("not Finished after moving 2 cycles at once (on frame %d)", i.CurrentFrameIndex())
+		}
+	}
+	// NextFrameDiff predicts finishing, holding on the last frame, after a whole number of cycles at once.
+	i = mustInstance(t, mustEntity(t, mustSheet(t), 0), 0)
+	i.SetLoopCount(2)
+	i.StartAnimation()
+	i.Step(1)
+	i.SetSpeedScale(8)
+	if !i.NextFrameDiff() {
+		t.Fatal("NextFrameDiff is false before finishing on another frame")
+	}
+	i.Advance()
+	if !i.Finished() || i.CurrentFrameIndex() != 3 {
+		t.Fatalf("after 2 cycles at once, on frame %d (Finished %t), want Finished on 3", i.CurrentFrameIndex(), i.Finished())
+	}
+}
+
+func TestFrameAtTicks(t *testing.T) {
+	i := mustInstance(t, mustEntity(t, mustSheet(t), 0), 0)
+	// played returns the frame i is on after being advanced ticks times from its start.
+	played := func(ticks int) int {
+		i.RestartAnimation()
+		for n := 0; n < ticks; n++ {
+			i.Advance()
+		}
+		return i.CurrentFrameIndex()
+	}
+	for _, scale := range []float64{1, 0.5, 1.5, 0.25, 0.75, 3, 5, -1, -0.5, -2.5, -5} {
+		for _, loops := range []int{0, 1, 2} {
+			i.SetSpeedScale(scale)
+			i.SetLoopCount(loops)
+			for ticks := 0; ticks < 30; ticks++ {
+				if got, want := i.FrameAtTicks(ticks), played(ticks); got != want {
+					t.Fatalf("at %gx, %d loops: FrameAtTicks(%d) is %d, but playing gives %d", scale, loops, ticks, got, want)
+				}
+			}
+		}
+	}
+
+	i.SetSpeedScale(1)
+	i.SetLoopCount(0)
+	if got := i.FrameAtTicks(-1); got != 0 {
+		t.Errorf("FrameAtTicks(-1) is %d, want 0", got)
+	}
+	// 50ms is 3 whole 16ms ticks.
+	if got := i.FrameAtElapsed(50*time.Millisecond, 16*time.Millisecond); got != 3 {
+		t.Errorf("FrameAtElapsed(50ms, 16ms) is %d, want 3", got)
+	}
+	i.SetRandomPlayback(true, nil)
+	if got := i.FrameAtTicks(5); got != -1 {
+		t.Errorf("FrameAtTicks in random playback is %d, want -1", got)
+	}
+}
+
+func TestModeWithoutFrames(t *testing.T) {
+	e := mustEntity(t, mustSheet(t), 0)
+	m := mustMode(t, e, 0)
+	// The frame editors refuse to remove the last frame.
+	if err := m.SetFrameCount(0); err == nil || m.FrameCount() != 4 {
+		t.Fatal("SetFrameCount(0) removed every frame")
+	}
+	for m.FrameCount() > 1 {
+		if err := m.RemoveFrame(0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.RemoveFrame(0); err == nil || m.FrameCount() != 1 {
+		t.Fatal("RemoveFrame removed the last frame")
+	}
+
+	// Otherwise, Instances of a Mode without frames get a transparent placeholder rather than panicking.
+	i := mustInstance(t, e, 0)
+	i.StartAnimation()
+	m.frames = nil
+	m.framesChanged()
+	frame := toRGBA(i.Frame())
+	if frame.Bounds().Size() != image.Pt(4, 4) || !reflect.DeepEqual(frame.Pix, make([]uint8, 4*4*4)) {
+		t.Fatalf("placeholder frame is %v, want a transparent 4x4 frame", frame.Bounds())
+	}
+	i.Advance()
+	i.Step(3)
+	i.SetSpeedScale(-1.5)
+	i.Advance()
+	i.SetRandomPlayback(true, nil)
+	i.Advance()
+	_ = i.CurrentFrame()
+	_ = i.FrameBlended(0.5)
+	_ = i.FrameAtTicks(5)
+	_ = i.IsInActiveWindow()
+	_ = i.FrameResized(8, 8)
+	canvas := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	i.PlaceOn(canvas, image.Point{})
+	i.PlaceOnOnionSkin(canvas, image.Point{}, 1, 1, 100)
+	_ = i.VisibleBounds(image.Point{})
+	_ = i.PixelOverlaps(mustInstance(t, e, 1), image.Point{}, image.Point{})
+	if i.CurrentFrameIndex() != 0 {
+		t.Errorf("animation without frames is on frame %d, want 0", i.CurrentFrameIndex())
+	}
+	if _, err := m.GetFrame(0); err == nil {
+		t.Error("GetFrame of a Mode without frames did not fail")
+	}
+}
+
+func TestOnLastFrame(t *testing.T) {
+	i := mustInstance(t, mustEntity(t, mustSheet(t), 0), 0)
+	i.StartAnimation()
+	// Looping: only frame 3 is last, each cycle.
+	for n := 0; n < 8; n++ {
+		if got, want := i.OnLastFrame(), n%4 == 3; got != want {
+			t.Fatalf("looping, frame %d: OnLastFrame %v, want %v", i.CurrentFrameIndex(), got, want)
+		}
+		i.Advance()
+	}
+
+	// Played once, the animation finishes, and holds, on its last frame.
+	i.RestartAnimation()
+	i.SetLoopCount(1)
+	for n := 0; n < 8; n++ {
+		i.Advance()
+	}
+	if !i.Finished() || !i.OnLastFrame() {
+		t.Fatalf("played once: Finished %v, OnLastFrame %v; want both true", i.Finished(), i.OnLastFrame())
+	}
+
+	// In reverse, frame 0 is last.
+	i.SetLoopCount(0)
+	i.RestartAnimation()
+	i.SetSpeedScale(-1)
+	if !i.OnLastFrame() {
+		t.Fatal("reversed, frame 0 is not last")
+	}
+	i.Advance()
+	if i.CurrentFrameIndex() != 3 || i.OnLastFrame() {
+		t.Fatalf("reversed, frame %d: OnLastFrame %v; want frame 3, false", i.CurrentFrameIndex(), i.OnLastFrame())
+	}
+	i.Step(-3)
+	if !i.OnLastFrame() {
+		t.Fatalf("reversed, frame %d is not last", i.CurrentFrameIndex())
+	}
+
+	i.SetRandomPlayback(true, nil)
+	if i.OnLastFrame() {
+		t.Fatal("random playback has a last frame")
+	}
+}
+
+func TestNextFrameDiff(t *testing.T) {
+	e := mustEntity(t, mustSheet(t), 0)
+	// check advances i ticks times, failing if NextFrameDiff ever mispredicts whether the frame changes.
+	check := func(name string, i *Instance, ticks int) {
+		t.Helper()
+		for n := 0; n < ticks; n++ {
+			before := i.CurrentFrame().Bounds()
+			predicted := i.NextFrameDiff()
+			i.Advance()
+			if changed := i.CurrentFrame().Bounds() != before; changed != predicted {
+				t.Fatalf("%s, tick %d: NextFrameDiff %v, but the frame changed: %v", name, n, predicted, changed)
+			}
+		}
+	}
+	for _, speed := range []float64{1, 0.25, 0.1, 1.0 / 3, -0.3, 2.5, 4} {
+		i := mustInstance(t, e, 0)
+		i.SetSpeedScale(speed)
+		i.SetLoopCount(2)
+		i.StartAnimation()
+		name := fmt.Sprintf("speed %v", speed)
+		check(name, i, 200)
+		if !i.Finished() || i.NextFrameDiff() {
+			t.Fatalf("%s: NextFrameDiff is true once Finished", name)
+		}
+	}
+
+	// Switching to a queued Mode changes the frame.
+	i := mustInstance(t, e, 0)
+	if err := i.QueueModes(e.ModeNames()[1], e.ModeNames()[2]); err != nil {
+		t.Fatal(err)
+	}
+	i.SetLoopCount(1)
+	i.SetSpeedScale(0.5)
+	i.StartAnimation()
+	check("queued Modes", i, 40)
+	if i.Mode.Name() != e.ModeNames()[2] {
+		t.Fatalf("in Mode %q after playing the queue, want %q", i.Mode.Name(), e.ModeNames()[2])
+	}
+
+	i = mustInstance(t, e, 0)
+	if i.NextFrameDiff() {
+		t.Fatal("NextFrameDiff is true while stopped")
+	}
+	i.StartAnimation()
+	i.PauseAnimation()
+	if i.NextFrameDiff() {
+		t.Fatal("NextFrameDiff is true while paused")
+	}
+}
+
+func TestState(t *testing.T) {
+	s := mustSheet(t)
+	i := mustInstance(t, mustEntity(t, s, 0), 0)
+	if got := i.State(); got != Stopped {
+		t.Fatalf("new Instance is %v, want stopped", got)
+	}
+	for _, c := range []struct {
+		name       string
+		transition func()
+		want       PlaybackState
+	}{
+		{"PauseAnimation while stopped", i.PauseAnimation, Stopped},
+		{"StartAnimation", i.StartAnimation, Playing},
+		{"PauseAnimation", i.PauseAnimation, Paused},
+		{"ResumeAnimation", i.ResumeAnimation, Playing},
+		{"StopAnimation", i.StopAnimation, Stopped},
+		{"RestartAnimation", i.RestartAnimation, Playing},
+		{"PauseAnimation", i.PauseAnimation, Paused},
+		{"ResetAnimation", i.ResetAnimation, Stopped},
+		{"playing once through", func() {
+			i.SetLoopCount(1)
+			i.StartAnimation()
+			for n := 0; n < 4; n++ {
+				i.Advance()
+			}
+		}, Finished},
+		{"pausing and resuming once Finished", func() { i.PauseAnimation(); i.ResumeAnimation() }, Finished},
+		{"SetSpeedScale once Finished", func() { i.SetSpeedScale(2) }, Finished},
+		{"StartAnimation once Finished", i.StartAnimation, Playing},
+		{"PauseAnimation", i.PauseAnimation, Paused},
+	} {
+		c.transition()
+		if got := i.State(); got != c.want {
+			t.Fatalf("after %s, %v; want %v", c.name, got, c.want)
+		}
+		// The state is saved and restored.
+		state, err := i.MarshalState()
+		if err != nil {
+			t.Fatal(err)
+		}
+		restored, err := s.RestoreInstance(state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := restored.State(); got != c.want {
+			t.Fatalf("after %s, restored Instance is %v; want %v", c.name, got, c.want)
+		}
+	}
+
+	for state, want := range map[PlaybackState]string{Stopped: "stopped", Playing: "playing", Paused: "paused",
+		Finished: "finished", PlaybackState(9): "PlaybackState(9)"} {
+		if got := state.String(); got != want {
+			t.Fatalf("String() = %q, want %q", got, want)
+		}
+	}
+}
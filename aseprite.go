@@ -0,0 +1,135 @@
+package sprites
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"time"
+
+	ccsl_graphics "github.com/HaileyStorm/CCSL_go/graphics"
+)
+
+// asepriteTag is a tag (a named range of frames) in an Aseprite JSON export.
+type asepriteTag struct {
+	Name      string `json:"name"`
+	From      int    `json:"from"`
+	To        int    `json:"to"`
+	Direction string `json:"direction"`
+}
+
+// asepriteJSON is the data used from an Aseprite JSON export.
+type asepriteJSON struct {
+	Frames json.RawMessage `json:"frames"`
+	Meta   struct {
+		Image     string        `json:"image"`
+		FrameTags []asepriteTag `json:"frameTags"`
+	} `json:"meta"`
+}
+
+// NewSheetFromAseprite creates a Sheet from a sprite sheet image exported by Aseprite, along with the JSON data file
+// exported with it (in either the "Hash" or "Array" format). The Sheet has a single Entity, named after the image file
+// given in the JSON (or "Entity0" if there is none), with a Mode for each tag, named after the tag and containing
+// the tag's range of frames; "reverse" and "pingpong" tags have their frames ordered accordingly. If there are no tags,
+// the Entity has a single Mode, "Mode0", of all the frames. Each frame's duration is recorded (see
+// Mode.FrameDuration).
+// Every frame must be the same size, and must not be rotated; trimmed frames are therefore not supported.
+func NewSheetFromAseprite(img ccsl_graphics.SubImager, asepriteData []byte) (*Sheet, error) {
+	var data asepriteJSON
+	if err := json.Unmarshal(asepriteData, &data); err != nil {
+		return nil, fmt.Errorf("invalid Aseprite JSON: %w", err)
+	}
+	frames, err := decodeAtlasFrames(data.Frames)
+	if err != nil {
+		return nil, err
+	}
+	if len(frames) == 0 {
+		return nil, errors.New("Aseprite JSON has no frames")
+	}
+
+	// Check the frames are consistent and within the image.
+	size := image.Pt(frames[0].Frame.W, frames[0].Frame.H)
+	if size.X <= 0 || size.Y <= 0 {
+		return nil, fmt.Errorf("Aseprite frame 0 size (%v) must be > 0", size)
+	}
+	bounds := img.Bounds()
+	rects := make([]image.Rectangle, len(frames))
+	for n, f := range frames {
+		if f.Rotated {
+			return nil, fmt.Errorf("Aseprite frame %d is rotated, which is not supported", n)
+		}
+		if f.Trimmed {
+			return nil, fmt.Errorf("Aseprite frame %d is trimmed, which is not supported", n)
+		}
+		if f.Frame.W != size.X || f.Frame.H != size.Y {
+			return nil, fmt.Errorf("Aseprite frame %d: %w (that of frame 0); all frames must be the same size (and not "+
+				"trimmed)", n, &ErrFrameSizeMismatch{Got: f.Frame.rect(), Want: image.Rectangle{Max: size}})
+		}
+		rects[n] = image.Rect(f.Frame.X, f.Frame.Y, f.Frame.X+f.Frame.W, f.Frame.Y+f.Frame.H).Add(bounds.Min)
+		if !rects[n].In(bounds) {
+			return nil, fmt.Errorf("Aseprite frame %d (%v) is not within the image (%v)", n,
+				rects[n].Sub(bounds.Min), bounds.Size())
+		}
+	}
+
+	tags := data.Meta.FrameTags
+	if len(tags) == 0 {
+		tags = []asepriteTag{{Name: "Mode0", From: 0, To: len(frames) - 1}}
+	}
+	for _, tag := range tags {
+		if tag.From < 0 || tag.To >= len(frames) || tag.From > tag.To {
+			return nil, fmt.Errorf("Aseprite tag %s frame range [%d,%d] must satisfy 0 <= from <= to < frame count (%d)",
+				tag.Name, tag.From, tag.To, len(frames))
+		}
+	}
+
+	rgba := atlasRGBA(img)
+
+	name := data.Meta.Image
+	if name == "" {
+		name = DefaultNameFormatter("entity", 0)
+	}
+	entity := &Entity{
+		name:             name,
+		modes:            make(map[int]*Mode),
+		modeNamesToIndex: make(map[string]int),
+	}
+	for j, tag := range tags {
+		if _, ok := entity.modeNamesToIndex[tag.Name]; ok {
+			return nil, fmt.Errorf("Aseprite tag %s is duplicated", tag.Name)
+		}
+		mode := newMode(tag.Name, image.Rectangle{Max: size})
+		for _, n := range asepriteTagFrames(tag) {
+			mode.frames = append(mode.frames, rgba.SubImage(rects[n]))
+			mode.frameDurations = append(mode.frameDurations, time.Duration(frames[n].Duration)*time.Millisecond)
+		}
+		mode.framesChanged()
+		entity.modes[j] = mode
+		entity.modeNamesToIndex[tag.Name] = j
+	}
+
+	return &Sheet{
+		entities:           map[int]*Entity{0: entity},
+		entityNamesToIndex: map[string]int{name: 0},
+	}, nil
+}
+
+// asepriteTagFrames returns the indexes of the frames in tag, in the order they play.
+func asepriteTagFrames(tag asepriteTag) []int {
+	var indexes []int
+	for n := tag.From; n <= tag.To; n++ {
+		indexes = append(indexes, n)
+	}
+	switch tag.Direction {
+	case "reverse":
+		for l, r := 0, len(indexes)-1; l < r; l, r = l+1, r-1 {
+			indexes[l], indexes[r] = indexes[r], indexes[l]
+		}
+	case "pingpong":
+		// Back down again, without repeating the first and last frames.
+		for n := tag.To - 1; n > tag.From; n-- {
+			indexes = append(indexes, n)
+		}
+	}
+	return indexes
+}
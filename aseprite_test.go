@@ -0,0 +1,135 @@
+package sprites
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// asepriteImage returns an 8x4 NRGBA image, with Bounds().Min at (2,3), of four 2x4 frames side by side. The top-left
+// pixel of each frame has its own red value: 30 times its x.
+func asepriteImage() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(2, 3, 10, 7))
+	for x := 0; x < 8; x++ {
+		img.Set(2+x, 3, color.NRGBA{R: uint8(x * 30), A: 255})
+	}
+	return img
+}
+
+// frameXs returns the x of each of m's frames in the sheet image, relative to the image's origin at (2,3), as found
+// from the red value of their top-left pixels.
+func frameXs(t *testing.T, m *Mode) []int {
+	t.Helper()
+	xs := make([]int, m.FrameCount())
+	for n := range xs {
+		frame, _ := m.GetFrame(n)
+		r, _, _, _ := frame.At(frame.Bounds().Min.X, frame.Bounds().Min.Y).RGBA()
+		xs[n] = int(r>>8) / 30
+	}
+	return xs
+}
+
+// frameDurations returns the durations of m's frames.
+func frameDurations(m *Mode) []time.Duration {
+	durations := make([]time.Duration, m.FrameCount())
+	for n := range durations {
+		durations[n] = m.FrameDuration(n)
+	}
+	return durations
+}
+
+func TestNewSheetFromAseprite(t *testing.T) {
+	data := `{"frames": {
+		"hero 0.aseprite": {"frame": {"x": 0, "y": 0, "w": 2, "h": 4}, "rotated": false, "duration": 100},
+		"hero 1.aseprite": {"frame": {"x": 2, "y": 0, "w": 2, "h": 4}, "rotated": false, "duration": 50},
+		"hero 2.aseprite": {"frame": {"x": 4, "y": 0, "w": 2, "h": 4}, "rotated": false, "duration": 100},
+		"hero 3.aseprite": {"frame": {"x": 6, "y": 0, "w": 2, "h": 4}, "rotated": false, "duration": 70}
+	},
+	"meta": {"image": "hero.png", "frameTags": [
+		{"name": "walk", "from": 0, "to": 1, "direction": "forward"},
+		{"name": "spin", "from": 1, "to": 3, "direction": "pingpong"},
+		{"name": "back", "from": 2, "to": 3, "direction": "reverse"}
+	]}}`
+	s, err := NewSheetFromAseprite(asepriteImage(), []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	e, err := s.GetEntityByName("hero.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms := time.Millisecond
+	for j, want := range []struct {
+		name      string
+		xs        []int
+		durations []time.Duration
+	}{
+		{"walk", []int{0, 2}, []time.Duration{100 * ms, 50 * ms}},
+		{"spin", []int{2, 4, 6, 4}, []time.Duration{50 * ms, 100 * ms, 70 * ms, 100 * ms}},
+		{"back", []int{6, 4}, []time.Duration{70 * ms, 100 * ms}},
+	} {
+		m := mustMode(t, e, j)
+		if m.Name() != want.name || m.SpriteSize() != image.Rect(0, 0, 2, 4) {
+			t.Fatalf("Mode %d is %s of size %v, want %s of size 2x4", j, m.Name(), m.SpriteSize(), want.name)
+		}
+		if xs := frameXs(t, m); !reflect.DeepEqual(xs, want.xs) {
+			t.Fatalf("%s frames are at x %v, want %v", want.name, xs, want.xs)
+		}
+		if durations := frameDurations(m); !reflect.DeepEqual(durations, want.durations) {
+			t.Fatalf("%s frame durations are %v, want %v", want.name, durations, want.durations)
+		}
+	}
+}
+
+func TestNewSheetFromAsepriteArray(t *testing.T) {
+	// Without tags or an image name, there is a single default Entity and Mode of all the frames.
+	data := `{"frames": [
+		{"frame": {"x": 0, "y": 0, "w": 2, "h": 4}, "duration": 10},
+		{"frame": {"x": 2, "y": 0, "w": 2, "h": 4}, "duration": 20}
+	], "meta": {}}`
+	s, err := NewSheetFromAseprite(asepriteImage(), []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := mustEntity(t, s, 0)
+	m := mustMode(t, e, 0)
+	if e.Name() != DefaultNameFormatter("entity", 0) || m.Name() != DefaultNameFormatter("mode", 0) {
+		t.Fatalf("Entity %s Mode %s, want the default names", e.Name(), m.Name())
+	}
+	if xs := frameXs(t, m); !reflect.DeepEqual(xs, []int{0, 2}) {
+		t.Fatalf("frames are at x %v, want [0 2]", xs)
+	}
+	// Durations follow their frames.
+	if err := m.RemoveFrame(0); err != nil {
+		t.Fatal(err)
+	}
+	if m.FrameDuration(0) != 20*time.Millisecond {
+		t.Fatalf("frame 0 duration after removing the first frame is %v, want 20ms", m.FrameDuration(0))
+	}
+}
+
+func TestNewSheetFromAsepriteInvalid(t *testing.T) {
+	frame := func(x, w int, extra string) string {
+		return fmt.Sprintf(`{"frame": {"x": %d, "y": 0, "w": %d, "h": 4}%s}`, x, w, extra)
+	}
+	for _, c := range []struct{ problem, data string }{
+		{"mismatched frame sizes", `{"frames": [` + frame(0, 2, "") + `, ` + frame(2, 3, "") + `]}`},
+		{"rotated frame", `{"frames": [` + frame(0, 2, `, "rotated": true`) + `]}`},
+		{"trimmed frame", `{"frames": [` + frame(0, 2, `, "trimmed": true`) + `]}`},
+		{"frame outside the image", `{"frames": [` + frame(8, 2, "") + `]}`},
+		{"tag past the last frame", `{"frames": [` + frame(0, 2, "") + `], "meta": {"frameTags": [{"name": "a", "from": 0, "to": 1}]}}`},
+		{"duplicate tag", `{"frames": [` + frame(0, 2, "") + `], "meta": {"frameTags": [{"name": "a", "from": 0, "to": 0}, {"name": "a", "from": 0, "to": 0}]}}`},
+		{"no frames", `{"frames": []}`},
+		{"invalid JSON", `{"frames": [`},
+	} {
+		if _, err := NewSheetFromAseprite(asepriteImage(), []byte(c.data)); err == nil {
+			t.Fatalf("%s accepted", c.problem)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package sprites
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+
+	ccsl_graphics "github.com/HaileyStorm/CCSL_go/graphics"
+)
+
+// atlasRect is a rectangle in a texture atlas JSON file (as exported by Aseprite, TexturePacker, etc.).
+type atlasRect struct {
+	X, Y, W, H int
+}
+
+// rect returns r as an image.Rectangle.
+func (r atlasRect) rect() image.Rectangle {
+	return image.Rect(r.X, r.Y, r.X+r.W, r.Y+r.H)
+}
+
+// atlasFrame is a frame in a texture atlas JSON file.
+type atlasFrame struct {
+	// Filename is the name of the frame. In the "Hash" format it is the frame's key.
+	Filename string    `json:"filename"`
+	Frame    atlasRect `json:"frame"`
+	Rotated  bool      `json:"rotated"`
+	// Trimmed indicates transparent borders were removed from the frame; SpriteSourceSize is then the location of
+	// Frame within the original frame, of size SourceSize.
+	Trimmed          bool      `json:"trimmed"`
+	SpriteSourceSize atlasRect `json:"spriteSourceSize"`
+	SourceSize       struct {
+		W, H int
+	} `json:"sourceSize"`
+	// Duration is the frame's duration in milliseconds (Aseprite only).
+	Duration int `json:"duration"`
+}
+
+// decodeAtlasFrames decodes the frames of a texture atlas JSON file, which are either an array (the "Array" format),
+// or an object keyed by frame name (the "Hash" format), in which case they are returned in the order given.
+func decodeAtlasFrames(raw json.RawMessage) ([]atlasFrame, error) {
+	var frames []atlasFrame
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	if raw[0] == '[' {
+		if err := json.Unmarshal(raw, &frames); err != nil {
+			return nil, fmt.Errorf("invalid JSON frames: %w", err)
+		}
+		return frames, nil
+	}
+
+	// An object, which must be decoded in order.
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("invalid JSON frames: %w", err)
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON frames: %w", err)
+		}
+		var f atlasFrame
+		if err := dec.Decode(&f); err != nil {
+			return nil, fmt.Errorf("invalid JSON frames: %w", err)
+		}
+		f.Filename, _ = key.(string)
+		frames = append(frames, f)
+	}
+	return frames, nil
+}
+
+// atlasRGBA returns img as an *image.RGBA (converting it if it is not one already), so frames can be taken from it as
+// SubImages.
+func atlasRGBA(img ccsl_graphics.SubImager) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba
+	}
+	rgba := image.NewRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Rect, img, rgba.Rect.Min, draw.Src)
+	return rgba
+}
@@ -0,0 +1,136 @@
+package sprites
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// BlendMode selects how Instance.PlaceOnBlend combines a frame's pixels with the canvas' pixels beneath them.
+type BlendMode int
+
+const (
+	// BlendOver draws the frame over the canvas (the Porter-Duff "over" operator), exactly as PlaceOn does.
+	BlendOver BlendMode = iota
+	// BlendAdd adds the frame's color to the canvas', clamping each channel at its maximum; it can only brighten. It
+	// suits glows, lights and particles, particularly over a dark background.
+	BlendAdd
+	// BlendMultiply multiplies the frame's color with the canvas'; it can only darken (opaque white leaves the canvas
+	// unchanged). It suits shadows and tinting.
+	BlendMultiply
+	// BlendScreen multiplies the inverse of the frame's color with the inverse of the canvas', and inverts the result;
+	// it can only brighten (opaque black leaves the canvas unchanged), but unlike BlendAdd does not saturate as quickly.
+	BlendScreen
+)
+
+// blend draws frame onto the dst rectangle of canvas (clipped to canvas' bounds) using mode, other than BlendOver. It
+// works per pixel, in premultiplied alpha space (where these operations, with the frame's transparency accounted for,
+// are defined; see the W3C Compositing and Blending spec), with 16 bits per channel.
+func blend(frame Sprite, canvas draw.Image, dst image.Rectangle, mode BlendMode) {
+	visible := dst.Intersect(canvas.Bounds())
+	offset := frame.Bounds().Min.Sub(dst.Min)
+	for y := visible.Min.Y; y < visible.Max.Y; y++ {
+		for x := visible.Min.X; x < visible.Max.X; x++ {
+			sr, sg, sb, sa := frame.At(x+offset.X, y+offset.Y).RGBA()
+			if sa == 0 {
+				continue
+			}
+			dr, dg, db, da := canvas.At(x, y).RGBA()
+			a := blendChannel(mode, sa, sa, da, da)
+			canvas.Set(x, y, color.RGBA64{
+				R: minUint16(blendChannel(mode, sr, sa, dr, da), a),
+				G: minUint16(blendChannel(mode, sg, sa, dg, da), a),
+				B: minUint16(blendChannel(mode, sb, sa, db, da), a),
+				A: a,
+			})
+		}
+	}
+}
+
+// minUint16 returns the lesser of a and b. blend uses it to keep each color channel <= alpha, which rounding could
+// otherwise violate by 1.
+func minUint16(a, b uint16) uint16 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// blendChannel returns the result of blending premultiplied channel value s (of a pixel with alpha sa) over
+// premultiplied channel value d (of a pixel with alpha da) using mode, clamped to the valid range. Blending the alphas
+// themselves (s = sa, d = da) gives the result's alpha.
+func blendChannel(mode BlendMode, s, sa, d, da uint32) uint16 {
+	const max = 0xffff
+	var result uint32
+	switch mode {
+	case BlendAdd:
+		result = s + d
+	case BlendMultiply:
+		// s*(1-da) + d*(1-sa) + s*d; for the alpha channel this is sa + da - sa*da, as for BlendScreen.
+		result = uint32((uint64(s)*(max-uint64(da)) + uint64(d)*(max-uint64(sa)) + uint64(s)*uint64(d)) / max)
+	case BlendScreen:
+		// s + d - s*d
+		result = s + d - s*d/max
+	default:
+		// s + d*(1-sa)
+		result = s + d*(max-sa)/max
+	}
+	if result > max {
+		result = max
+	}
+	return uint16(result)
+}
+
+// placeLinear draws frame over the dst rectangle of canvas (clipped to canvas' bounds) as draw.Over does, but
+// compositing in linear light: each color is decoded from sRGB to linear, composited, and re-encoded to sRGB.
+func placeLinear(frame Sprite, canvas draw.Image, dst image.Rectangle) {
+	visible := dst.Intersect(canvas.Bounds())
+	offset := frame.Bounds().Min.Sub(dst.Min)
+	for y := visible.Min.Y; y < visible.Max.Y; y++ {
+		for x := visible.Min.X; x < visible.Max.X; x++ {
+			src := frame.At(x+offset.X, y+offset.Y)
+			if _, _, _, alpha := src.RGBA(); alpha == 0 {
+				continue
+			} else if alpha == 0xffff {
+				canvas.Set(x, y, src)
+				continue
+			}
+			sr, sg, sb, sa := unpremultiplied(src)
+			dr, dg, db, da := unpremultiplied(canvas.At(x, y))
+			// Porter-Duff over, with (linear, premultiplied) colors weighted by their alphas.
+			a := sa + da*(1-sa)
+			over := func(s, d float64) float64 {
+				return (srgbToLinear(s)*sa + srgbToLinear(d)*da*(1-sa)) / a
+			}
+			canvas.Set(x, y, color.NRGBA64{
+				R: uint16(math.Round(linearToSRGB(over(sr, dr)) * 0xffff)),
+				G: uint16(math.Round(linearToSRGB(over(sg, dg)) * 0xffff)),
+				B: uint16(math.Round(linearToSRGB(over(sb, db)) * 0xffff)),
+				A: uint16(math.Round(a * 0xffff)),
+			})
+		}
+	}
+}
+
+// unpremultiplied returns the non-premultiplied color channels and alpha of c, each in [0,1].
+func unpremultiplied(c color.Color) (r, g, b, a float64) {
+	n := color.NRGBA64Model.Convert(c).(color.NRGBA64)
+	return float64(n.R) / 0xffff, float64(n.G) / 0xffff, float64(n.B) / 0xffff, float64(n.A) / 0xffff
+}
+
+// srgbToLinear decodes the sRGB-encoded value v, in [0,1], to linear light.
+func srgbToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// linearToSRGB encodes the linear light value v, in [0,1], as sRGB.
+func linearToSRGB(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
@@ -0,0 +1,82 @@
+package sprites
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestPlaceOnBlend(t *testing.T) {
+	gray := color.RGBA{R: 100, G: 100, B: 100, A: 255}
+	i := mustInstance(t, singleSpriteEntity(t, filledImage(4, 4, gray)), 0)
+	for _, c := range []struct {
+		mode BlendMode
+		want uint8
+	}{{BlendOver, 100}, {BlendAdd, 200}, {BlendMultiply, 39}, {BlendScreen, 161}} {
+		canvas := filledImage(8, 8, gray)
+		i.PlaceOnBlend(canvas, image.Pt(2, 2), c.mode)
+		if got := canvas.RGBAAt(3, 3); got != (color.RGBA{R: c.want, G: c.want, B: c.want, A: 255}) {
+			t.Fatalf("mode %d: blended pixel is %v, want gray %d", c.mode, got, c.want)
+		}
+		if got := canvas.RGBAAt(1, 1); got != gray {
+			t.Fatalf("mode %d: pixel outside the frame changed to %v", c.mode, got)
+		}
+	}
+
+	// Repeated additive blending of overlapping mid-gray brightens toward white, saturating there. Frames partly off
+	// the canvas are clipped.
+	canvas := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for n, want := range []uint8{100, 200, 255, 255} {
+		i.PlaceOnBlend(canvas, image.Pt(6, 6), BlendAdd)
+		if got := canvas.RGBAAt(7, 7); got != (color.RGBA{R: want, G: want, B: want, A: 255}) {
+			t.Fatalf("after %d additive blends, pixel is %v, want gray %d", n+1, got, want)
+		}
+	}
+
+	// Translucent frames are blended in premultiplied space, so no channel exceeds alpha.
+	translucent := mustInstance(t, singleSpriteEntity(t, filledImage(1, 1, color.NRGBA{R: 255, G: 255, B: 255, A: 128})), 0)
+	for _, mode := range []BlendMode{BlendAdd, BlendMultiply, BlendScreen} {
+		canvas := filledImage(1, 1, color.NRGBA{R: 200, A: 64})
+		translucent.PlaceOnBlend(canvas, image.Point{}, mode)
+		if got := canvas.RGBAAt(0, 0); got.R > got.A || got.G > got.A || got.A < 128 {
+			t.Fatalf("mode %d: translucent blend is %v", mode, got)
+		}
+	}
+	canvas = filledImage(1, 1, color.NRGBA{R: 200, A: 64})
+	translucent.PlaceOnBlend(canvas, image.Point{}, BlendAdd)
+	if got := canvas.RGBAAt(0, 0); got != (color.RGBA{R: 178, G: 128, B: 128, A: 192}) {
+		t.Fatalf("translucent additive blend is %v, want {178 128 128 192}", got)
+	}
+}
+
+func TestPlaceOnLinear(t *testing.T) {
+	// Half-transparent white, but for an opaque red pixel at (1,0) and a transparent one at (2,0).
+	img := filledImage(4, 1, color.NRGBA{R: 255, G: 255, B: 255, A: 128})
+	img.SetRGBA(1, 0, color.RGBA{R: 255, A: 255})
+	img.SetRGBA(2, 0, color.RGBA{})
+	e := singleSpriteEntity(t, img)
+	black := color.RGBA{A: 255}
+	linear, naive := filledImage(4, 1, black), filledImage(4, 1, black)
+	mustInstance(t, e, 0).PlaceOnLinear(linear, image.Point{})
+	mustInstance(t, e, 0).PlaceOn(naive, image.Point{})
+
+	// Half of white's linear light is sRGB 188, not the naive average of 128.
+	if got := linear.RGBAAt(0, 0); got.R < 186 || got.R > 190 || got.R != got.G || got.R != got.B || got.A != 255 {
+		t.Errorf("half-transparent white over black in linear light is %v, want gray 188", got)
+	}
+	if got := naive.RGBAAt(0, 0); got.R != 128 {
+		t.Errorf("half-transparent white over black by PlaceOn is %v, want gray 128", got)
+	}
+	// Opaque and transparent pixels are the same either way.
+	for x := 1; x <= 2; x++ {
+		if linear.RGBAAt(x, 0) != naive.RGBAAt(x, 0) {
+			t.Errorf("pixel (%d,0) is %v in linear light, but %v by PlaceOn", x, linear.RGBAAt(x, 0), naive.RGBAAt(x, 0))
+		}
+	}
+	// Over a transparent canvas, the frame is copied.
+	transparent := image.NewRGBA(image.Rect(0, 0, 4, 1))
+	mustInstance(t, e, 0).PlaceOnLinear(transparent, image.Point{})
+	if got, want := transparent.RGBAAt(0, 0), img.RGBAAt(0, 0); got.A != want.A || got.R < want.R-1 || got.R > want.R+1 {
+		t.Errorf("over a transparent canvas, pixel is %v, want %v", got, want)
+	}
+}
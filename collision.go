@@ -0,0 +1,150 @@
+package sprites
+
+import (
+	"fmt"
+	"image"
+)
+
+// CollisionAlphaThreshold is the alpha value a pixel must exceed to be considered solid by collision tests such as
+// Instance.PixelOverlaps. The default of 0 treats any pixel that is not fully transparent as solid.
+var CollisionAlphaThreshold uint8 = 0
+
+// CollisionMask returns the collision mask of the frame at index, using CollisionAlphaThreshold. See
+// CollisionMaskWithThreshold.
+func (m *Mode) CollisionMask(index int) (*image.Alpha, error) {
+	return m.CollisionMaskWithThreshold(index, CollisionAlphaThreshold)
+}
+
+// CollisionMaskWithThreshold returns the collision mask of the frame at index: an image the size of the frame, with
+// Bounds().Min at (0,0), in which each pixel is opaque (alpha 255) if the frame's alpha there is above threshold, and
+// transparent (alpha 0) otherwise. Masks are cached by the Mode (until its frames change), so repeated requests are
+// cheap. The returned mask is shared and must not be modified.
+func (m *Mode) CollisionMaskWithThreshold(index int, threshold uint8) (*image.Alpha, error) {
+	if index < 0 || index >= m.FrameCount() {
+		return nil, fmt.Errorf("frame index (%d) must be >= 0 and < the frame count (%d)", index, m.FrameCount())
+	}
+	return m.collisionMask(index, threshold), nil
+}
+
+// collisionMask returns the collision mask of the frame at index, using the cached copy if there is one.
+func (m *Mode) collisionMask(index int, threshold uint8) *image.Alpha {
+	key := maskKey{index, threshold}
+	m.cache.mu.Lock()
+	defer m.cache.mu.Unlock()
+	if mask, ok := m.cache.masks[key]; ok {
+		return mask
+	}
+
+	frame := m.frameAt(index).(*image.RGBA)
+	mask := image.NewAlpha(image.Rectangle{Max: frame.Rect.Size()})
+	for y := 0; y < mask.Rect.Dy(); y++ {
+		row := rgbaRow(frame, frame.Rect.Min.Y+y)
+		maskRow := mask.Pix[y*mask.Stride : y*mask.Stride+mask.Rect.Dx()]
+		for x := range maskRow {
+			if row[x*4+3] > threshold {
+				maskRow[x] = 255
+			}
+		}
+	}
+
+	if m.cache.masks == nil {
+		m.cache.masks = make(map[maskKey]*image.Alpha)
+	}
+	m.cache.masks[key] = mask
+	return mask
+}
+
+// VisiblePixelCount returns the number of pixels in the frame at index with an alpha above CollisionAlphaThreshold.
+// Counts are cached by the Mode (until its frames change). If the Mode is FullyOpaque and CollisionAlphaThreshold is
+// below OpacityThreshold, every pixel is visible, so this is simply the number of pixels in the frame.
+func (m *Mode) VisiblePixelCount(index int) (int, error) {
+	if index < 0 || index >= m.FrameCount() {
+		return 0, fmt.Errorf("frame index (%d) must be >= 0 and < the frame count (%d)", index, m.FrameCount())
+	}
+	threshold := CollisionAlphaThreshold
+	if m.isFullyOpaque() && opaqueIsSolid(threshold) {
+		size := m.frameAt(index).Bounds().Size()
+		return size.X * size.Y, nil
+	}
+
+	key := maskKey{index, threshold}
+	m.cache.mu.Lock()
+	count, ok := m.cache.visible[key]
+	m.cache.mu.Unlock()
+	if ok {
+		return count, nil
+	}
+
+	for _, a := range m.collisionMask(index, threshold).Pix {
+		if a != 0 {
+			count++
+		}
+	}
+	m.cache.mu.Lock()
+	if m.cache.visible == nil {
+		m.cache.visible = make(map[maskKey]int)
+	}
+	m.cache.visible[key] = count
+	m.cache.mu.Unlock()
+	return count, nil
+}
+
+// VisibleFraction returns the fraction (from 0 to 1) of the pixels in the frame at index which are visible. See
+// VisiblePixelCount.
+func (m *Mode) VisibleFraction(index int) (float64, error) {
+	count, err := m.VisiblePixelCount(index)
+	if err != nil {
+		return 0, err
+	}
+	size := m.frameAt(index).Bounds().Size()
+	return float64(count) / float64(size.X*size.Y), nil
+}
+
+// PixelOverlaps returns whether i and other, with their current frames placed at iAt and otherAt respectively, overlap:
+// that is, whether any pixel position is solid (has an alpha above CollisionAlphaThreshold) in both frames. It does not
+// advance either animation.
+// If the sprites' rectangles do not intersect, it returns false without examining any pixels. If both current Modes
+// are FullyOpaque and CollisionAlphaThreshold is below OpacityThreshold, every pixel is solid, so it returns true as
+// soon as the rectangles intersect. Otherwise, the frames' cached collision masks (see Mode.CollisionMask) are compared.
+func (i *Instance) PixelOverlaps(other *Instance, iAt, otherAt image.Point) bool {
+	iIndex, iMode := i.currentFrameIndexAndMode()
+	otherIndex, otherMode := other.currentFrameIndexAndMode()
+
+	iRect := image.Rectangle{Max: iMode.frameAt(iIndex).Bounds().Size()}.Add(iAt)
+	otherRect := image.Rectangle{Max: otherMode.frameAt(otherIndex).Bounds().Size()}.Add(otherAt)
+	overlap := iRect.Intersect(otherRect)
+	if overlap.Empty() {
+		return false
+	}
+
+	threshold := CollisionAlphaThreshold
+	if threshold == 255 {
+		return false
+	}
+	if iMode.isFullyOpaque() && otherMode.isFullyOpaque() && opaqueIsSolid(threshold) {
+		return true
+	}
+
+	iMask := iMode.collisionMask(iIndex, threshold)
+	otherMask := otherMode.collisionMask(otherIndex, threshold)
+	// Translate the overlap into each mask's coordinate space.
+	iMin := overlap.Min.Sub(iAt)
+	otherMin := overlap.Min.Sub(otherAt)
+	for dy := 0; dy < overlap.Dy(); dy++ {
+		iOff := iMask.PixOffset(iMin.X, iMin.Y+dy)
+		otherOff := otherMask.PixOffset(otherMin.X, otherMin.Y+dy)
+		for dx := 0; dx < overlap.Dx(); dx++ {
+			if iMask.Pix[iOff+dx]&otherMask.Pix[otherOff+dx] != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// opaqueIsSolid returns whether every pixel of a FullyOpaque Mode is solid at the collision alpha threshold: that is,
+// whether the lowest alpha a FullyOpaque Mode may have, OpacityThreshold, is above threshold. When OpacityThreshold
+// has been lowered to (or below) threshold, a FullyOpaque Mode may have pixels which are not solid.
+func opaqueIsSolid(threshold uint8) bool {
+	return threshold < OpacityThreshold
+}
@@ -0,0 +1,188 @@
+package sprites
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// edgedImage returns a 4x4 opaque gray image whose top row and left column have an alpha of edgeAlpha.
+func edgedImage(edgeAlpha uint8) *image.RGBA {
+	img := filledImage(4, 4, color.RGBA{R: 100, G: 100, B: 100, A: 255})
+	for n := 0; n < 4; n++ {
+		img.Set(n, 0, color.NRGBA{R: 100, G: 100, B: 100, A: edgeAlpha})
+		img.Set(0, n, color.NRGBA{R: 100, G: 100, B: 100, A: edgeAlpha})
+	}
+	return img
+}
+
+func TestOpacityThreshold(t *testing.T) {
+	if singleSpriteMode(t, edgedImage(254)).FullyOpaque() {
+		t.Fatal("254-edged Mode is fully opaque at the default threshold")
+	}
+
+	OpacityThreshold = 250
+	defer func() { OpacityThreshold = 255 }()
+	if !singleSpriteMode(t, edgedImage(254)).FullyOpaque() {
+		t.Fatal("254-edged Mode is not fully opaque at threshold 250")
+	}
+	if singleSpriteMode(t, edgedImage(249)).FullyOpaque() {
+		t.Fatal("249-edged Mode is fully opaque at threshold 250")
+	}
+}
+
+func TestVisiblePixelCountLoweredOpacityThreshold(t *testing.T) {
+	OpacityThreshold = 250
+	CollisionAlphaThreshold = 252
+	defer func() {
+		OpacityThreshold = 255
+		CollisionAlphaThreshold = 0
+	}()
+	m := singleSpriteMode(t, edgedImage(251))
+	if !m.FullyOpaque() {
+		t.Fatal("Mode is not fully opaque")
+	}
+	count, err := m.VisiblePixelCount(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The 7 edge pixels are below the collision threshold.
+	if count != 9 {
+		t.Fatalf("VisiblePixelCount = %d, want 9", count)
+	}
+
+	CollisionAlphaThreshold = 0
+	if count, _ = m.VisiblePixelCount(0); count != 16 {
+		t.Fatalf("VisiblePixelCount = %d, want 16", count)
+	}
+}
+
+func TestPixelOverlapsLoweredOpacityThreshold(t *testing.T) {
+	OpacityThreshold = 250
+	CollisionAlphaThreshold = 252
+	defer func() {
+		OpacityThreshold = 255
+		CollisionAlphaThreshold = 0
+	}()
+	a := mustInstance(t, singleSpriteEntity(t, edgedImage(251)), 0)
+	b := a.Clone()
+	// Only b's top-left pixel, which is not solid, overlaps a's bottom-right pixel.
+	if a.PixelOverlaps(b, image.Point{}, image.Pt(3, 3)) {
+		t.Fatal("overlap of a solid and a non-solid pixel reported")
+	}
+	if !a.PixelOverlaps(b, image.Point{}, image.Pt(2, 2)) {
+		t.Fatal("overlap of solid pixels not reported")
+	}
+
+	CollisionAlphaThreshold = 0
+	if !a.PixelOverlaps(b, image.Point{}, image.Pt(3, 3)) {
+		t.Fatal("overlap at the default collision threshold not reported")
+	}
+}
+
+// circleImage returns a 10x10 image of an opaque red disc of radius 5, centered in the image; its corners are
+// transparent.
+func circleImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			dx, dy := float64(x)-4.5, float64(y)-4.5
+			if dx*dx+dy*dy <= 25 {
+				img.Set(x, y, color.RGBA{R: 255, A: 255})
+			}
+		}
+	}
+	return img
+}
+
+func TestPixelOverlaps(t *testing.T) {
+	a := mustInstance(t, singleSpriteEntity(t, circleImage()), 0)
+	b := a.Clone()
+	if !a.PixelOverlaps(b, image.Point{}, image.Pt(9, 0)) {
+		t.Fatal("circles touching side by side not reported")
+	}
+	// Diagonally, the bounding boxes overlap, but only in the transparent corners.
+	if a.PixelOverlaps(b, image.Point{}, image.Pt(8, 8)) {
+		t.Fatal("circles just missing diagonally reported")
+	}
+	if a.PixelOverlaps(b, image.Point{}, image.Pt(10, 0)) {
+		t.Fatal("disjoint circles reported")
+	}
+	if !b.PixelOverlaps(a, image.Pt(9, 0), image.Point{}) {
+		t.Fatal("touching circles not reported when checked the other way around")
+	}
+}
+
+func TestCollisionMask(t *testing.T) {
+	// An 8x1 white gradient, with alpha 0, 32, ..., 224.
+	img := image.NewRGBA(image.Rect(0, 0, 8, 1))
+	for x := 0; x < 8; x++ {
+		img.Set(x, 0, color.NRGBA{R: 255, G: 255, B: 255, A: uint8(x * 32)})
+	}
+	m := singleSpriteMode(t, img)
+	assertMask := func(mask *image.Alpha, threshold int) {
+		t.Helper()
+		for x := 0; x < 8; x++ {
+			if solid := mask.AlphaAt(x, 0).A == 255; solid != (x*32 > threshold) {
+				t.Fatalf("threshold %d: pixel %d with alpha %d solid %v", threshold, x, x*32, solid)
+			}
+		}
+	}
+
+	mask, err := m.CollisionMaskWithThreshold(0, 128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMask(mask, 128)
+	if again, _ := m.CollisionMaskWithThreshold(0, 128); again != mask {
+		t.Fatal("mask not cached")
+	}
+	mask, _ = m.CollisionMask(0)
+	assertMask(mask, 0)
+
+	CollisionAlphaThreshold = 100
+	defer func() { CollisionAlphaThreshold = 0 }()
+	mask, _ = m.CollisionMask(0)
+	assertMask(mask, 100)
+
+	if _, err := m.CollisionMask(1); err == nil {
+		t.Fatal("CollisionMask of a nonexistent frame succeeded")
+	}
+	// Editing the frames discards the cached masks.
+	if err := m.InsertFrame(0, image.NewRGBA(image.Rect(0, 0, 8, 1))); err != nil {
+		t.Fatal(err)
+	}
+	mask, _ = m.CollisionMask(0)
+	assertMask(mask, 255)
+}
+
+func TestVisiblePixelCount(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	img.Set(0, 0, color.RGBA{R: 1, G: 1, B: 1, A: 255})
+	img.Set(3, 2, color.RGBA{R: 1, G: 1, B: 1, A: 255})
+	img.Set(1, 1, color.NRGBA{A: 10})
+	m := singleSpriteMode(t, img)
+	for n := 0; n < 2; n++ {
+		count, err := m.VisiblePixelCount(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 3 {
+			t.Fatalf("VisiblePixelCount = %d, want 3", count)
+		}
+	}
+	if fraction, _ := m.VisibleFraction(0); fraction != 3.0/16 {
+		t.Fatalf("VisibleFraction = %g, want 3/16", fraction)
+	}
+	if _, err := m.VisiblePixelCount(1); err == nil {
+		t.Fatal("VisiblePixelCount of a nonexistent frame succeeded")
+	}
+
+	opaque := singleSpriteMode(t, edgedImage(255))
+	if count, _ := opaque.VisiblePixelCount(0); count != 16 {
+		t.Fatalf("VisiblePixelCount of an opaque frame = %d, want 16", count)
+	}
+	if fraction, _ := opaque.VisibleFraction(0); fraction != 1 {
+		t.Fatalf("VisibleFraction of an opaque frame = %g, want 1", fraction)
+	}
+}
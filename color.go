@@ -0,0 +1,158 @@
+package sprites
+
+import (
+	"fmt"
+	"image/color"
+)
+
+// AverageColor returns the mean color of the non-transparent pixels of sprite. The mean is taken in premultiplied
+// alpha space, so it is alpha-weighted and partially transparent (e.g. anti-aliased edge) pixels do not darken the
+// result; the returned color's alpha is the mean alpha of the non-transparent pixels. For example, the average of a
+// sprite with opaque red pixels on a transparent background is opaque red. A fully transparent sprite returns the zero
+// color.RGBA.
+func AverageColor(sprite Sprite) color.RGBA {
+	rgba := toRGBA(sprite)
+	var sum colorSum
+	for y := rgba.Rect.Min.Y; y < rgba.Rect.Max.Y; y++ {
+		row := rgbaRow(rgba, y)
+		for x := 0; x < len(row); x += 4 {
+			sum.add(row[x : x+4])
+		}
+	}
+	return sum.mean()
+}
+
+// DominantColor returns the most common color of the non-transparent pixels of sprite. Similar colors are grouped
+// together (by the 4 most significant bits of each color channel), with each pixel counted in proportion to its alpha;
+// the result is the AverageColor of the pixels in the largest group. A fully transparent sprite returns the zero
+// color.RGBA.
+func DominantColor(sprite Sprite) color.RGBA {
+	rgba := toRGBA(sprite)
+	groups := make(map[uint16]*colorSum)
+	var dominant *colorSum
+	for y := rgba.Rect.Min.Y; y < rgba.Rect.Max.Y; y++ {
+		row := rgbaRow(rgba, y)
+		for x := 0; x < len(row); x += 4 {
+			pix := row[x : x+4]
+			if pix[3] == 0 {
+				continue
+			}
+			// Group by the un-premultiplied color, so the same color at different alphas is grouped together.
+			c := color.NRGBAModel.Convert(color.RGBA{pix[0], pix[1], pix[2], pix[3]}).(color.NRGBA)
+			key := uint16(c.R>>4)<<8 | uint16(c.G>>4)<<4 | uint16(c.B>>4)
+			group, ok := groups[key]
+			if !ok {
+				group = &colorSum{}
+				groups[key] = group
+			}
+			group.add(pix)
+			if dominant == nil || group.a > dominant.a {
+				dominant = group
+			}
+		}
+	}
+	if dominant == nil {
+		return color.RGBA{}
+	}
+	return dominant.mean()
+}
+
+// colorSum accumulates premultiplied RGBA pixels for AverageColor and DominantColor.
+type colorSum struct {
+	r, g, b, a uint64
+	n          uint64
+}
+
+// add adds the (premultiplied) pixel pix, given as R, G, B and A bytes, to s if it is not fully transparent.
+func (s *colorSum) add(pix []uint8) {
+	if pix[3] == 0 {
+		return
+	}
+	s.r += uint64(pix[0])
+	s.g += uint64(pix[1])
+	s.b += uint64(pix[2])
+	s.a += uint64(pix[3])
+	s.n++
+}
+
+// mean returns the mean of the pixels added to s, rounded to nearest, or the zero color.RGBA if there are none.
+func (s *colorSum) mean() color.RGBA {
+	if s.n == 0 {
+		return color.RGBA{}
+	}
+	half := s.n / 2
+	return color.RGBA{
+		R: uint8((s.r + half) / s.n),
+		G: uint8((s.g + half) / s.n),
+		B: uint8((s.b + half) / s.n),
+		A: uint8((s.a + half) / s.n),
+	}
+}
+
+// PalettedAlphaThreshold is the alpha at or above which a pixel is drawn by Instance.PlaceOnPaletted as opaque; pixels
+// with a lower alpha are treated as transparent.
+var PalettedAlphaThreshold uint8 = 128
+
+// paletteMatcher finds the nearest opaque color in a palette, caching its results.
+type paletteMatcher struct {
+	palette []color.NRGBA
+	// opaque holds the indexes of the palette entries which are not fully transparent.
+	opaque []int
+	// transparent is the index of the first fully transparent palette entry, or -1 if there is none.
+	transparent int
+	cache       map[color.NRGBA]uint8
+}
+
+// newPaletteMatcher returns a paletteMatcher for palette, or an error if palette has more than 256 entries (so cannot
+// be indexed by an *image.Paletted).
+func newPaletteMatcher(palette color.Palette) (*paletteMatcher, error) {
+	if len(palette) > 256 {
+		return nil, fmt.Errorf("palette has %d entries, but may have at most 256", len(palette))
+	}
+	pm := &paletteMatcher{
+		palette:     make([]color.NRGBA, len(palette)),
+		transparent: -1,
+		cache:       make(map[color.NRGBA]uint8),
+	}
+	for i, c := range palette {
+		pm.palette[i] = color.NRGBAModel.Convert(c).(color.NRGBA)
+		if pm.palette[i].A != 0 {
+			pm.opaque = append(pm.opaque, i)
+		} else if pm.transparent < 0 {
+			pm.transparent = i
+		}
+	}
+	return pm, nil
+}
+
+// transparentIndex returns the index of the palette's first fully transparent entry. It returns false if the palette
+// has no such entry.
+func (pm *paletteMatcher) transparentIndex() (uint8, bool) {
+	if pm.transparent < 0 {
+		return 0, false
+	}
+	return uint8(pm.transparent), true
+}
+
+// index returns the index of the opaque palette entry nearest, by RGB distance, to c with its alpha ignored. It
+// returns false if the palette has no opaque entries.
+func (pm *paletteMatcher) index(c color.RGBA) (uint8, bool) {
+	if len(pm.opaque) == 0 {
+		return 0, false
+	}
+	nc := color.NRGBAModel.Convert(c).(color.NRGBA)
+	nc.A = 255
+	if index, ok := pm.cache[nc]; ok {
+		return index, true
+	}
+	best, bestDist := pm.opaque[0], -1
+	for _, i := range pm.opaque {
+		p := pm.palette[i]
+		dr, dg, db := int(p.R)-int(nc.R), int(p.G)-int(nc.G), int(p.B)-int(nc.B)
+		if dist := dr*dr + dg*dg + db*db; bestDist < 0 || dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	pm.cache[nc] = uint8(best)
+	return uint8(best), true
+}
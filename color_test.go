@@ -0,0 +1,97 @@
+package sprites
+
+import (
+	"image"
+	"image/color"
+	"image/color/palette"
+	"testing"
+)
+
+func TestAverageColor(t *testing.T) {
+	// An opaque red pixel and a half transparent red one, on a transparent background.
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	img.Set(1, 1, color.RGBA{R: 255, A: 255})
+	img.Set(2, 1, color.NRGBA{R: 255, A: 128})
+	avg := AverageColor(img)
+	if avg != (color.RGBA{R: 192, A: 192}) {
+		t.Fatalf("AverageColor = %v, want {192 0 0 192}", avg)
+	}
+	// Un-premultiplied, the average is pure red, not darkened by the transparent pixels or the translucent one.
+	if n := color.NRGBAModel.Convert(avg).(color.NRGBA); n != (color.NRGBA{R: 255, A: 192}) {
+		t.Fatalf("AverageColor is %v un-premultiplied, want pure red", n)
+	}
+	if c := AverageColor(image.NewRGBA(image.Rect(0, 0, 2, 2))); c != (color.RGBA{}) {
+		t.Fatalf("AverageColor of a transparent sprite = %v, want zero", c)
+	}
+}
+
+func TestDominantColor(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	img.Set(1, 1, color.RGBA{R: 255, A: 255})
+	img.Set(2, 1, color.NRGBA{R: 255, A: 128})
+	for y := 0; y < 3; y++ {
+		img.Set(0, y, color.RGBA{B: 255, A: 255})
+	}
+	if c := DominantColor(img); c != (color.RGBA{B: 255, A: 255}) {
+		t.Fatalf("DominantColor = %v, want blue", c)
+	}
+	if c := DominantColor(image.NewRGBA(image.Rect(0, 0, 2, 2))); c != (color.RGBA{}) {
+		t.Fatalf("DominantColor of a transparent sprite = %v, want zero", c)
+	}
+}
+
+func TestPlaceOnPaletted(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 1))
+	img.Set(0, 0, color.RGBA{R: 250, G: 10, B: 10, A: 255})
+	img.Set(1, 0, color.RGBA{R: 10, G: 10, B: 200, A: 255})
+	img.Set(2, 0, color.RGBA{A: 50})
+	img.Set(3, 0, color.RGBA{R: 1, G: 2, B: 3, A: 255})
+	i := mustInstance(t, singleSpriteEntity(t, img), 0)
+	// Index 3 is black, not transparent, so that the transparent index must be found by its alpha.
+	pal := color.Palette{color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}, color.RGBA{}, color.RGBA{A: 255}}
+
+	canvas := image.NewPaletted(image.Rect(0, 0, 6, 2), pal)
+	// Fill the canvas with a stale color, as if it had been used before.
+	for n := range canvas.Pix {
+		canvas.Pix[n] = 1
+	}
+	if err := i.PlaceOnPaletted(canvas, image.Pt(1, 1)); err != nil {
+		t.Fatal(err)
+	}
+	want := []uint8{0, 1, 2, 3}
+	for x, index := range want {
+		if got := canvas.ColorIndexAt(1+x, 1); got != index {
+			t.Fatalf("index at (%d,1) = %d, want %d", 1+x, got, index)
+		}
+	}
+	// Pixels outside the sprite are unchanged.
+	if canvas.ColorIndexAt(0, 1) != 1 || canvas.ColorIndexAt(1, 0) != 1 {
+		t.Fatal("pixels outside the sprite changed")
+	}
+}
+
+func TestPlaceOnPalettedNoTransparentEntry(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	i := mustInstance(t, singleSpriteEntity(t, img), 0)
+	canvas := image.NewPaletted(image.Rect(0, 0, 2, 1), color.Palette{color.RGBA{R: 255, A: 255}, color.RGBA{G: 255, A: 255}})
+	canvas.Pix[1] = 1
+	if err := i.PlaceOnPaletted(canvas, image.Point{}); err != nil {
+		t.Fatal(err)
+	}
+	if canvas.Pix[0] != 0 || canvas.Pix[1] != 1 {
+		t.Fatalf("indexes = %v, want [0 1]", canvas.Pix)
+	}
+}
+
+func TestPlaceOnPalettedLargePalette(t *testing.T) {
+	i := mustInstance(t, mustEntity(t, mustSheet(t), 0), 0)
+	i.StartAnimation()
+	pal := append(color.Palette{color.RGBA{}}, palette.Plan9...)
+	if err := i.PlaceOnPaletted(image.NewPaletted(image.Rect(0, 0, 4, 4), pal), image.Point{}); err == nil {
+		t.Fatal("palette of 257 entries accepted")
+	}
+	if i.CurrentFrameIndex() != 0 {
+		t.Fatal("animation advanced")
+	}
+}
@@ -1,8 +1,12 @@
 package sprites
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"image"
+	"sort"
 )
 
 type Entity struct {
@@ -10,13 +14,21 @@ type Entity struct {
 
 	modes            map[int]*Mode
 	modeNamesToIndex map[string]int
+
+	// meta holds the Entity's metadata. See SetMeta.
+	meta map[string]string
+
+	// tracker is the instanceTracker of the Entity's Sheet, or nil if it is not tracking Instances. See
+	// Sheet.TrackInstances.
+	tracker *instanceTracker
 }
 
 func (e *Entity) Name() string {
 	return e.name
 }
 
-//describe index order in docstring
+// GetModeByIndex returns the Mode with index idx. For an Entity read from a sprite grid, Modes are indexed left to right
+// (or, if SheetDimensions.FramesRunRows, top to bottom) within the Entity, unless since changed by e.g. ReorderModes.
 func (e *Entity) GetModeByIndex(idx int) (*Mode, error) {
 	mode, ok := e.modes[idx]
 	if ok {
@@ -58,10 +70,139 @@ func (e *Entity) RenameMode(oldName, newName string) error {
 	return nil
 }
 
+// RemoveMode removes the Mode named name from the Entity. The Modes after it move down one index each, so indexes still
+// run contiguously from 0. The Entity's only Mode cannot be removed. Instances hold their current Mode directly, so
+// those currently using the removed Mode continue to play it until their Mode is changed, and those using other Modes
+// are unaffected (although subsequent lookups by index, e.g. Instance.SetModeByIndex, use the new indexes).
+func (e *Entity) RemoveMode(name string) error {
+	removed, ok := e.modeNamesToIndex[name]
+	if !ok {
+		return fmt.Errorf("mode with name %s does not exist in Entity", name)
+	}
+	count := len(e.modes)
+	if count == 1 {
+		return errors.New("cannot remove the only mode in Entity")
+	}
+	for idx := removed; idx < count-1; idx++ {
+		e.modes[idx] = e.modes[idx+1]
+		e.modeNamesToIndex[e.modes[idx].name] = idx
+	}
+	delete(e.modes, count-1)
+	delete(e.modeNamesToIndex, name)
+	return nil
+}
+
+// ReorderModes rearranges the Entity's Modes so that the Mode previously at index newOrder[i] is at index i. newOrder
+// must be a permutation of the existing indexes (0 to ModeCount()-1). Mode names, and Instances currently using a
+// Mode, are unaffected, but subsequent lookups by index (e.g. Instance.SetModeByIndex) use the new order.
+func (e *Entity) ReorderModes(newOrder []int) error {
+	if err := checkPermutation(newOrder, len(e.modes)); err != nil {
+		return err
+	}
+	modes := make(map[int]*Mode, len(e.modes))
+	for idx, oldIdx := range newOrder {
+		modes[idx] = e.modes[oldIdx]
+		e.modeNamesToIndex[modes[idx].name] = idx
+	}
+	e.modes = modes
+	return nil
+}
+
+// checkPermutation returns an error if order is not a permutation of the indexes 0 to count-1.
+func checkPermutation(order []int, count int) error {
+	if len(order) != count {
+		return fmt.Errorf("new order has %d indexes, but there are %d", len(order), count)
+	}
+	seen := make([]bool, count)
+	for _, idx := range order {
+		if idx < 0 || idx >= count {
+			return fmt.Errorf("index %d in new order must be >= 0 and < %d", idx, count)
+		}
+		if seen[idx] {
+			return fmt.Errorf("index %d appears more than once in new order", idx)
+		}
+		seen[idx] = true
+	}
+	return nil
+}
+
+// clone returns a copy of the Entity, including copies of each of its Modes, which can be modified without affecting
+// e. The frame image data itself is shared.
+func (e *Entity) clone() *Entity {
+	c := &Entity{
+		name:             e.name,
+		modes:            make(map[int]*Mode, len(e.modes)),
+		modeNamesToIndex: make(map[string]int, len(e.modeNamesToIndex)),
+		meta:             copyMeta(e.meta),
+	}
+	for idx, mode := range e.modes {
+		c.modes[idx] = mode.clone()
+	}
+	for name, idx := range e.modeNamesToIndex {
+		c.modeNamesToIndex[name] = idx
+	}
+	return c
+}
+
+// deepCopy is like clone, but the frame image data is copied as well (see Mode.deepCopy), so the copy shares nothing
+// with e.
+func (e *Entity) deepCopy() *Entity {
+	c := e.clone()
+	for idx, mode := range c.modes {
+		c.modes[idx] = mode.deepCopy()
+	}
+	return c
+}
+
+// sortedModeIndexes returns the indexes of the Entity's Modes in ascending order.
+func (e *Entity) sortedModeIndexes() []int {
+	idxs := make([]int, 0, len(e.modes))
+	for idx := range e.modes {
+		idxs = append(idxs, idx)
+	}
+	sort.Ints(idxs)
+	return idxs
+}
+
+// firstFrame returns the first frame of the Entity's lowest-indexed Mode, or nil if the Entity has no Modes or that
+// Mode has no frames.
+func (e *Entity) firstFrame() Sprite {
+	idxs := e.sortedModeIndexes()
+	if len(idxs) == 0 {
+		return nil
+	}
+	frame, err := e.modes[idxs[0]].GetFrame(0)
+	if err != nil {
+		return nil
+	}
+	return frame
+}
+
+// ModeNames returns the names of the Entity's Modes, in index order. If there are gaps in the indexes, the names are
+// still returned contiguously, in ascending index order.
+func (e *Entity) ModeNames() []string {
+	idxs := e.sortedModeIndexes()
+	names := make([]string, len(idxs))
+	for n, idx := range idxs {
+		names[n] = e.modes[idx].name
+	}
+	return names
+}
+
 func (e *Entity) ModeCount() int {
 	return len(e.modes)
 }
 
+// TotalFrameCount returns the total number of frames in all the Entity's Modes, reflecting any changes to their frames
+// (e.g. SetFrameCount).
+func (e *Entity) TotalFrameCount() int {
+	total := 0
+	for _, m := range e.modes {
+		total += m.FrameCount()
+	}
+	return total
+}
+
 //only decrease
 func (e *Entity) SetModeCount(count int) error {
 	if count > 0 && count <= len(e.modes) {
@@ -74,7 +215,7 @@ func (e *Entity) SetModeCount(count int) error {
 		for _, d := range delList {
 			delete(e.modeNamesToIndex, d)
 		}
-		for i := count; i < len(e.modes); i++ {
+		for i, n := count, len(e.modes); i < n; i++ {
 			delete(e.modes, i)
 		}
 		return nil
@@ -83,33 +224,125 @@ func (e *Entity) SetModeCount(count int) error {
 	}
 }
 
+// SpriteSize returns the size of the Entity's sprites. It is taken from the lowest-indexed Mode, so does not require
+// Mode 0 to exist; if the Entity has no Modes, it returns the zero Rectangle. An Entity's Modes are created with the
+// same sprite size, but a Mode cropped by Mode.CropToContent may then differ from the others; use Mode.SpriteSize for
+// the size of a particular Mode's sprites.
 func (e *Entity) SpriteSize() image.Rectangle {
-	return e.modes[0].SpriteSize()
+	if mode, ok := e.modes[0]; ok {
+		return mode.SpriteSize()
+	}
+	if idxs := e.sortedModeIndexes(); len(idxs) > 0 {
+		return e.modes[idxs[0]].SpriteSize()
+	}
+	return image.Rectangle{}
 }
 
 func (e *Entity) NewInstance(initialMode int) (*Instance, error) {
+	if len(e.modes) == 0 {
+		return nil, errors.New("entity has no modes")
+	}
 	if mode, ok := e.modes[initialMode]; ok {
-		return &Instance{
-			Entity: e,
-			animation: &animation{
-				Mode:         mode,
-				running:      false,
-				currentFrame: 0,
-			},
-		}, nil
+		return e.track(&Instance{
+			Entity:    e,
+			animation: newAnimation(mode),
+		}), nil
 	} else {
 		return nil, fmt.Errorf("mode with index %d does not exist in Entity", initialMode)
 	}
 }
 
 func (e *Entity) NewInstanceWithModeName(initialMode string) (*Instance, error) {
+	if len(e.modes) == 0 {
+		return nil, errors.New("entity has no modes")
+	}
 	if idx, ok := e.modeNamesToIndex[initialMode]; ok {
-		if instance, err := e.NewInstance(idx); err == nil {
-			return instance, nil
-		} else {
-			panic(fmt.Errorf("internal error:%v", err))
-		}
+		return e.NewInstance(idx)
 	} else {
 		return nil, fmt.Errorf("mode with name %s does not exist in Entity", initialMode)
 	}
 }
+
+// NewInstancePerMode creates one Instance of the Entity for each of its Modes, in Mode index order, each in its own
+// Mode and with its animation started - e.g. to preview all of an Entity's animations at once.
+func (e *Entity) NewInstancePerMode() ([]*Instance, error) {
+	if len(e.modes) == 0 {
+		return nil, errors.New("entity has no modes")
+	}
+	idxs := e.sortedModeIndexes()
+	instances := make([]*Instance, len(idxs))
+	for n, idx := range idxs {
+		instance, err := e.NewInstance(idx)
+		if err != nil {
+			return nil, err
+		}
+		instance.StartAnimation()
+		instances[n] = instance
+	}
+	return instances, nil
+}
+
+// validate checks the internal consistency of the Entity and its Modes. See Sheet.Validate.
+func (e *Entity) validate() error {
+	if len(e.modeNamesToIndex) != len(e.modes) {
+		return fmt.Errorf("entity has %d mode names but %d modes", len(e.modeNamesToIndex), len(e.modes))
+	}
+	for name, idx := range e.modeNamesToIndex {
+		mode, ok := e.modes[idx]
+		if !ok {
+			return fmt.Errorf("mode name %s maps to index %d, which does not exist in Entity", name, idx)
+		}
+		if mode.name != name {
+			return fmt.Errorf("mode name %s maps to index %d, which is named %s", name, idx, mode.name)
+		}
+	}
+	for idx := 0; idx < len(e.modes); idx++ {
+		mode, ok := e.modes[idx]
+		if !ok {
+			return fmt.Errorf("mode indexes are not contiguous: index %d does not exist in Entity", idx)
+		}
+		if err := mode.validate(); err != nil {
+			return fmt.Errorf("mode %s (index %d): %w", mode.name, idx, err)
+		}
+	}
+	return nil
+}
+
+// pixelDigest returns a digest of the pixel data of all the Entity's frames, in Mode index and frame order, which
+// also depends on the number of Modes and frames. See Sheet.FindDuplicateEntities.
+func (e *Entity) pixelDigest() uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	for _, idx := range e.sortedModeIndexes() {
+		mode := e.modes[idx]
+		mode.loadFrames()
+		binary.LittleEndian.PutUint64(buf[:], uint64(len(mode.frames)))
+		_, _ = h.Write(buf[:])
+		for _, frame := range mode.frames {
+			binary.LittleEndian.PutUint64(buf[:], pixelDigest(frame.(*image.RGBA)))
+			_, _ = h.Write(buf[:])
+		}
+	}
+	return h.Sum64()
+}
+
+// pixelsEqual returns whether the Entity and other have the same number of Modes and, in each Mode (in index order),
+// the same number of frames with identical pixel data.
+func (e *Entity) pixelsEqual(other *Entity) bool {
+	idxs, otherIdxs := e.sortedModeIndexes(), other.sortedModeIndexes()
+	if len(idxs) != len(otherIdxs) {
+		return false
+	}
+	for n, idx := range idxs {
+		frames, otherFrames := e.modes[idx].Frames(), other.modes[otherIdxs[n]].Frames()
+		if len(frames) != len(otherFrames) {
+			return false
+		}
+		for f, frame := range frames {
+			if !rgbaEqual(frame.(*image.RGBA), otherFrames[f].(*image.RGBA)) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,147 @@
+package sprites
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestModeNames(t *testing.T) {
+	e := mustEntity(t, mustSheet(t), 0)
+	want := []string{DefaultNameFormatter("mode", 0), DefaultNameFormatter("mode", 1), DefaultNameFormatter("mode", 2)}
+	if got := e.ModeNames(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ModeNames gave %v, want %v", got, want)
+	}
+	// Leave a gap in the indexes.
+	delete(e.modes, 1)
+	want = []string{want[0], want[2]}
+	if got := e.ModeNames(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ModeNames gave %v, want %v", got, want)
+	}
+}
+
+func TestReorderModes(t *testing.T) {
+	s := mustSheet(t)
+	e := mustEntity(t, s, 0)
+	modes := []*Mode{mustMode(t, e, 0), mustMode(t, e, 1), mustMode(t, e, 2)}
+	i := mustInstance(t, e, 0)
+	for _, order := range [][]int{{0, 0, 1}, {0, 1}, {0, 1, 3}} {
+		if err := e.ReorderModes(order); err == nil {
+			t.Fatalf("order %v accepted", order)
+		}
+	}
+
+	order := []int{2, 0, 1}
+	if err := e.ReorderModes(order); err != nil {
+		t.Fatal(err)
+	}
+	for idx, old := range order {
+		if m := mustMode(t, e, idx); m != modes[old] {
+			t.Fatalf("Mode %d is %s, want %s", idx, m.Name(), modes[old].Name())
+		}
+		if m, err := e.GetModeByName(modes[old].Name()); err != nil || m != modes[old] {
+			t.Fatalf("Mode %s not found by name", modes[old].Name())
+		}
+	}
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if i.Mode != modes[0] {
+		t.Fatal("reordering changed an existing Instance's Mode")
+	}
+	if err := i.SetModeByIndex(0); err != nil || i.Mode != modes[2] {
+		t.Fatal("SetModeByIndex does not use the new order")
+	}
+}
+
+func TestSpriteSizeWithoutMode0(t *testing.T) {
+	e := mustEntity(t, mustSheet(t), 0)
+	delete(e.modes, 0)
+	if size := e.SpriteSize(); size != image.Rect(0, 0, 4, 4) {
+		t.Fatalf("SpriteSize without Mode 0 is %v, want 4x4", size)
+	}
+	e.modes = map[int]*Mode{}
+	if size := e.SpriteSize(); size != (image.Rectangle{}) {
+		t.Fatalf("SpriteSize without Modes is %v, want empty", size)
+	}
+}
+
+func TestEntityWithoutModes(t *testing.T) {
+	e := mustEntity(t, mustSheet(t), 0)
+	if err := e.SetModeCount(0); err == nil || e.ModeCount() != 3 {
+		t.Fatal("SetModeCount(0) removed every Mode")
+	}
+	e.modes, e.modeNamesToIndex = map[int]*Mode{}, map[string]int{}
+	if _, err := e.NewInstance(0); err == nil || err.Error() != "entity has no modes" {
+		t.Errorf("NewInstance error is %v, want entity has no modes", err)
+	}
+	if _, err := e.NewInstanceWithModeName(DefaultNameFormatter("mode", 0)); err == nil || err.Error() != "entity has no modes" {
+		t.Errorf("NewInstanceWithModeName error is %v, want entity has no modes", err)
+	}
+	if _, err := e.NewInstancePerMode(); err == nil || err.Error() != "entity has no modes" {
+		t.Errorf("NewInstancePerMode error is %v, want entity has no modes", err)
+	}
+	if size := e.SpriteSize(); !size.Empty() {
+		t.Errorf("SpriteSize is %v, want empty", size)
+	}
+}
+
+func TestRemoveMode(t *testing.T) {
+	s := mustSheet(t)
+	e := mustEntity(t, s, 0)
+	names := e.ModeNames()
+	removed := mustMode(t, e, 1)
+	onRemoved, onLast := mustInstance(t, e, 1), mustInstance(t, e, 2)
+	if err := e.RemoveMode(names[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := e.ModeNames(), []string{names[0], names[2]}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Mode names are %v, want %v", got, want)
+	}
+	for idx, name := range e.ModeNames() {
+		if byName, err := e.GetModeByName(name); err != nil || byName != mustMode(t, e, idx) {
+			t.Fatalf("Mode %s is not Mode %d", name, idx)
+		}
+	}
+	if err := e.RemoveMode(names[1]); err == nil {
+		t.Error("removing a nonexistent Mode did not fail")
+	}
+
+	// Instances keep playing their current Mode, and later changes use the new indexes.
+	if onRemoved.Mode != removed || onRemoved.Frame() == nil || onLast.Mode.Name() != names[2] {
+		t.Error("Instances' Modes changed by RemoveMode")
+	}
+	if err := onRemoved.SetModeByIndex(2); err == nil {
+		t.Error("SetModeByIndex of a removed index did not fail")
+	}
+	if err := onRemoved.SetModeByIndex(1); err != nil || onRemoved.Mode.Name() != names[2] {
+		t.Errorf("SetModeByIndex(1) after RemoveMode gave %s (%v), want %s", onRemoved.Mode.Name(), err, names[2])
+	}
+
+	// The only Mode cannot be removed.
+	if err := e.RemoveMode(names[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.RemoveMode(names[2]); err == nil || e.ModeCount() != 1 {
+		t.Error("removing the only Mode did not fail")
+	}
+}
+
+func TestNewInstancePerMode(t *testing.T) {
+	e := mustEntity(t, mustSheet(t), 0)
+	instances, err := e.NewInstancePerMode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(instances) != e.ModeCount() {
+		t.Fatalf("%d Instances, want %d", len(instances), e.ModeCount())
+	}
+	for j, i := range instances {
+		if i.Mode != mustMode(t, e, j) || i.Mode.Name() != e.ModeNames()[j] || !i.Running() {
+			t.Fatalf("Instance %d is in Mode %s (running %t), want running in %s", j, i.Mode.Name(), i.Running(), e.ModeNames()[j])
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package sprites
+
+import (
+	"fmt"
+	"image"
+)
+
+// ErrFrameSizeMismatch is the error returned when a frame is not the size required, e.g. by Mode.InsertFrame or
+// Sheet.Validate, or when a frame read by NewSheetFromAseprite or NewSheetFromTexturePacker does not match the size
+// of the other frames. It may be wrapped with details of which frame failed; use errors.As to retrieve it.
+type ErrFrameSizeMismatch struct {
+	// Got is the bounds of the frame.
+	Got image.Rectangle
+	// Want is the bounds of the size required. Only the sizes of Got and Want are compared, not their positions.
+	Want image.Rectangle
+}
+
+func (e *ErrFrameSizeMismatch) Error() string {
+	return fmt.Sprintf("frame size %v does not match the required size %v", e.Got.Size(), e.Want.Size())
+}
+
+// ErrSheetSizeMismatch is the error returned by the Sheet factories (NewSheet, etc.) when the sheet image (or, if
+// SheetDimensions.OriginX or OriginY is set, the region of it used) is not the size of the sprite grid described by the
+// SheetDimensions.
+type ErrSheetSizeMismatch struct {
+	// Got is the size of the sheet image.
+	Got image.Point
+	// Want is the size of the sprite grid.
+	Want image.Point
+}
+
+func (e *ErrSheetSizeMismatch) Error() string {
+	if e.Got.X != e.Want.X {
+		return fmt.Sprintf("image width (%d) is not 2*Margin + EntitiesPerRow * #cols/Entity * (SpriteWidth + Spacing) - Spacing (%d)",
+			e.Got.X, e.Want.X)
+	}
+	return fmt.Sprintf("image height (%d) is not 2*Margin + EntitiesPerColumn * #rows/Entity * (SpriteHeight + Spacing) - Spacing (%d)",
+		e.Got.Y, e.Want.Y)
+}
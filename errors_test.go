@@ -0,0 +1,46 @@
+package sprites
+
+import (
+	"errors"
+	"image"
+	"strings"
+	"testing"
+)
+
+func TestErrSheetSizeMismatch(t *testing.T) {
+	d := basicDims()
+	img := testSheetImage(d)
+	for _, c := range []struct {
+		got  image.Point
+		word string
+	}{{image.Pt(23, 32), "width"}, {image.Pt(24, 31), "height"}} {
+		_, err := NewSheet(img.SubImage(image.Rectangle{Max: c.got}).(*image.RGBA), d)
+		var mismatch *ErrSheetSizeMismatch
+		if !errors.As(err, &mismatch) {
+			t.Fatalf("%v sheet: error %v is not an ErrSheetSizeMismatch", c.got, err)
+		}
+		if mismatch.Got != c.got || mismatch.Want != image.Pt(24, 32) {
+			t.Fatalf("%v sheet: mismatch is %v, want %v", c.got, mismatch, image.Pt(24, 32))
+		}
+		if !strings.Contains(err.Error(), c.word) {
+			t.Fatalf("%v sheet: error %q does not mention the %s", c.got, err, c.word)
+		}
+	}
+}
+
+func TestErrFrameSizeMismatch(t *testing.T) {
+	s := mustSheet(t)
+	m := mustMode(t, mustEntity(t, s, 0), 0)
+	err := m.InsertFrame(0, image.NewRGBA(image.Rect(0, 0, 3, 4)))
+	var mismatch *ErrFrameSizeMismatch
+	if !errors.As(err, &mismatch) || mismatch.Got.Size() != image.Pt(3, 4) || mismatch.Want.Size() != image.Pt(4, 4) {
+		t.Fatalf("InsertFrame error is %v, want a 3x4 vs 4x4 ErrFrameSizeMismatch", err)
+	}
+
+	// Validate wraps the error with the frame index.
+	m.frames[2] = image.NewRGBA(image.Rect(0, 0, 4, 5))
+	err = s.Validate()
+	if !errors.As(err, &mismatch) || mismatch.Got.Size() != image.Pt(4, 5) || !strings.Contains(err.Error(), "frame 2") {
+		t.Fatalf("Validate error is %v, want a frame 2 ErrFrameSizeMismatch", err)
+	}
+}
@@ -0,0 +1,134 @@
+package sprites
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"os"
+)
+
+// ExportOptions are options for the functions which create images of sprites to export or display, such as
+// SpriteToPNGWith, ComposeInstancesWith and ContactSheetWith. The zero value gives the behavior of the corresponding
+// functions without options (e.g. SpriteToPNG).
+type ExportOptions struct {
+	// Background is OPTIONAL. If not nil, the image is filled with it before the sprites are drawn onto it (with
+	// draw.Over), for targets which do not handle transparency well. If nil, the image is transparent wherever the
+	// sprites are.
+	Background color.Color
+}
+
+// newCanvas returns a new image with bounds r, filled with background if it is not nil.
+func newCanvas(r image.Rectangle, background color.Color) *image.RGBA {
+	canvas := image.NewRGBA(r)
+	if background != nil {
+		draw.Draw(canvas, r, image.NewUniform(background), image.Point{}, draw.Src)
+	}
+	return canvas
+}
+
+// SpriteToPNG writes s to w in PNG format. Only the sprite's own Bounds() are encoded (not, for example, the rest of
+// the sheet image a frame is a SubImage of), with the sprite's top-left becoming (0,0).
+func SpriteToPNG(s Sprite, w io.Writer) error {
+	return SpriteToPNGWith(s, w, ExportOptions{})
+}
+
+// SpriteToPNGWith is like SpriteToPNG, but with opts; if opts.Background is set, the sprite is drawn over it.
+func SpriteToPNGWith(s Sprite, w io.Writer, opts ExportOptions) error {
+	if opts.Background != nil {
+		canvas := newCanvas(image.Rectangle{Max: s.Bounds().Size()}, opts.Background)
+		draw.Draw(canvas, canvas.Rect, s, s.Bounds().Min, draw.Over)
+		return png.Encode(w, canvas)
+	}
+	return png.Encode(w, toRGBA(s))
+}
+
+// SpriteToFile writes s to the file at path in PNG format (see SpriteToPNG), creating or truncating it.
+func SpriteToFile(s Sprite, path string) error {
+	return SpriteToFileWith(s, path, ExportOptions{})
+}
+
+// SpriteToFileWith is like SpriteToFile, but with opts (see SpriteToPNGWith).
+func SpriteToFileWith(s Sprite, path string, opts ExportOptions) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := SpriteToPNGWith(s, f, opts); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// SpriteToDataURL returns s as a "data:image/png;base64,..." URL, for example for embedding in HTML. As with
+// SpriteToPNG, only the sprite's own Bounds() are encoded.
+func SpriteToDataURL(s Sprite) (string, error) {
+	return SpriteToDataURLWith(s, ExportOptions{})
+}
+
+// SpriteToDataURLWith is like SpriteToDataURL, but with opts (see SpriteToPNGWith).
+func SpriteToDataURLWith(s Sprite, opts ExportOptions) (string, error) {
+	var buf bytes.Buffer
+	if err := SpriteToPNGWith(s, &buf, opts); err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// ComposeSheet packs the Entity's Modes (in index order) and their frames into a new, minimal sprite sheet image - with
+// each Mode a column and its frames running down it, or if dimensions.FramesRunRows is set, each Mode a row and its
+// frames running along it - e.g. to share a single character. It returns the image along with the SheetDimensions which
+// load it as a Sheet of the one Entity (for example with NewSheetWithEntityAndSharedModeNames, given the Entity's name
+// and ModeNames()). Of dimensions, only FramesRunRows, Margin and Spacing (which must be >= 0) are used; the other
+// fields are ignored. All of the Entity's Modes must have the same sprite size (so it is an error if one has been
+// cropped to a different size by Mode.CropToContent). The grid has as many frames per Mode as the longest Mode has, and
+// the cells past the end of shorter Modes are left transparent; to reload such Modes with their original frame counts,
+// use NewSheetWithNames with EntityAndModeNames.FrameCounts.
+func (e *Entity) ComposeSheet(dimensions SheetDimensions) (*image.RGBA, SheetDimensions, error) {
+	if len(e.modes) == 0 {
+		return nil, SheetDimensions{}, errors.New("entity has no modes")
+	}
+	if dimensions.Margin < 0 || dimensions.Spacing < 0 {
+		return nil, SheetDimensions{}, errors.New("SheetDimensions Margin and Spacing must be >= 0")
+	}
+	idxs := e.sortedModeIndexes()
+	spriteSize := e.modes[idxs[0]].spriteSize.Size()
+	frames := 0
+	for _, idx := range idxs {
+		mode := e.modes[idx]
+		if size := mode.spriteSize.Size(); size != spriteSize {
+			return nil, SheetDimensions{}, fmt.Errorf("mode %s sprite size (%dx%d) is not the same as that of mode %s (%dx%d)",
+				mode.name, size.X, size.Y, e.modes[idxs[0]].name, spriteSize.X, spriteSize.Y)
+		}
+		if mode.FrameCount() > frames {
+			frames = mode.FrameCount()
+		}
+	}
+
+	composed := SheetDimensions{
+		EntitiesPerRow:     1,
+		EntitiesPerColumn:  1,
+		ModesPerEntity:     len(idxs),
+		FramesPerAnimation: frames,
+		FramesRunRows:      dimensions.FramesRunRows,
+		SpriteWidth:        spriteSize.X,
+		SpriteHeight:       spriteSize.Y,
+		Margin:             dimensions.Margin,
+		Spacing:            dimensions.Spacing,
+	}
+	composed.init()
+	img := image.NewRGBA(image.Rectangle{Max: composed.imageSize()})
+	for j, idx := range idxs {
+		for f, frame := range e.modes[idx].Frames() {
+			draw.Draw(img, composed.cellRect(0, j, f), frame, frame.Bounds().Min, draw.Src)
+		}
+	}
+	composed.numEntityColumns, composed.numEntityRows = 0, 0
+	return img, composed, nil
+}
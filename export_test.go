@@ -0,0 +1,171 @@
+package sprites
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// assertSpritePNG fails t if data is not a PNG of exactly sprite's pixels, with Bounds().Min at (0,0).
+func assertSpritePNG(t *testing.T, data []byte, sprite Sprite) {
+	t.Helper()
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := sprite.Bounds()
+	if img.Bounds() != b.Sub(b.Min) {
+		t.Fatalf("PNG bounds %v, want %v", img.Bounds(), b.Sub(b.Min))
+	}
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			assertColor(t, img, x, y, sprite.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+}
+
+// lastFrame returns the last frame of the last Mode of the last Entity of mustSheet(t), which is far from the sheet
+// image's origin.
+func lastFrame(t *testing.T) Sprite {
+	t.Helper()
+	frame, err := mustMode(t, mustEntity(t, mustSheet(t), 3), 2).GetFrame(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return frame
+}
+
+func TestSpriteToPNG(t *testing.T) {
+	frame := lastFrame(t)
+	var buf bytes.Buffer
+	if err := SpriteToPNG(frame, &buf); err != nil {
+		t.Fatal(err)
+	}
+	assertSpritePNG(t, buf.Bytes(), frame)
+
+	path := filepath.Join(t.TempDir(), "frame.png")
+	if err := SpriteToFile(frame, path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSpritePNG(t, data, frame)
+}
+
+func TestSpriteToDataURL(t *testing.T) {
+	frame := lastFrame(t)
+	url, err := SpriteToDataURL(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const prefix = "data:image/png;base64,"
+	if !strings.HasPrefix(url, prefix) {
+		t.Fatalf("data URL %.30q... does not start with %q", url, prefix)
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(url, prefix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSpritePNG(t, data, frame)
+}
+
+func TestSpriteToPNGWithBackground(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, red)
+
+	var buf bytes.Buffer
+	if err := SpriteToPNGWith(img, &buf, ExportOptions{Background: color.White}); err != nil {
+		t.Fatal(err)
+	}
+	out, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertColor(t, out, 0, 0, red)
+	assertColor(t, out, 1, 0, color.White)
+
+	// Without a background, transparency is preserved.
+	buf.Reset()
+	if err := SpriteToPNG(img, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if out, err = png.Decode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	assertColor(t, out, 1, 0, color.Transparent)
+}
+
+func TestComposeInstancesWithBackground(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.RGBA{G: 255, A: 255})
+	i := mustInstance(t, singleSpriteEntity(t, img), 0)
+
+	composite, err := ComposeInstancesNoAdvanceWith([]*Instance{i}, ExportOptions{Background: color.White})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertColor(t, composite, 0, 0, color.RGBA{G: 255, A: 255})
+	assertColor(t, composite, 1, 1, color.White)
+
+	if composite, err = ComposeInstancesNoAdvance([]*Instance{i}); err != nil {
+		t.Fatal(err)
+	}
+	assertColor(t, composite, 1, 1, color.Transparent)
+}
+
+func TestPreviewsWithBackground(t *testing.T) {
+	blue := color.RGBA{B: 255, A: 255}
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, blue)
+	s := singleSpriteSheet(t, img)
+
+	contact := s.ContactSheetWith(1, 0, ExportOptions{Background: color.White})
+	assertColor(t, contact, 0, 0, blue)
+	assertColor(t, contact, 1, 1, color.White)
+	assertColor(t, s.ContactSheet(1, 0), 1, 1, color.Transparent)
+
+	preview, err := s.PreviewImageWith(0, 1, ExportOptions{Background: color.White})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertColor(t, preview, 0, 0, blue)
+	assertColor(t, preview, 1, 1, color.White)
+}
+
+func TestComposeSheet(t *testing.T) {
+	for _, framesRunRows := range []bool{false, true} {
+		e := mustEntity(t, mustSheet(t), 3)
+		// A shorter Mode leaves transparent cells, which FrameCounts drops on reloading.
+		if err := mustMode(t, e, 1).RemoveFrame(3); err != nil {
+			t.Fatal(err)
+		}
+		img, d, err := e.ComposeSheet(SheetDimensions{FramesRunRows: framesRunRows, Margin: 1, Spacing: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.EntitiesPerRow != 1 || d.EntitiesPerColumn != 1 || d.FramesRunRows != framesRunRows {
+			t.Fatalf("FramesRunRows %v: dimensions %+v are not of a single Entity", framesRunRows, d)
+		}
+		reloaded, err := NewSheetWithNames(img, d, []EntityAndModeNames{
+			{EntityName: e.Name(), ModeNames: e.ModeNames(), FrameCounts: []int{4, 3, 4}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := reloaded.GetEntityByName(e.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.pixelsEqual(e) {
+			t.Fatalf("FramesRunRows %v: reloaded Entity differs", framesRunRows)
+		}
+	}
+}
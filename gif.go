@@ -0,0 +1,70 @@
+package sprites
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"time"
+)
+
+// NewEntityFromGIF creates an Entity named name with a single Mode named modeName, whose frames are the frames of the
+// animated GIF g. GIF frames may cover only part of the image, and are drawn over the previous frame according to
+// their disposal methods, so each Sprite frame is the fully composited image, the size of the GIF's logical screen
+// (g.Config), or if that is not set, of the union of its frames' bounds. Each frame's delay is recorded as its
+// duration (see Mode.FrameDuration).
+func NewEntityFromGIF(g *gif.GIF, name string, modeName string) (*Entity, error) {
+	if g == nil || len(g.Image) == 0 {
+		return nil, errors.New("GIF has no frames")
+	}
+
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() {
+		bounds = image.Rectangle{}
+		for _, frame := range g.Image {
+			bounds = bounds.Union(frame.Rect)
+		}
+	}
+
+	mode := newMode(modeName, image.Rectangle{Max: bounds.Size()})
+	canvas := image.NewRGBA(bounds)
+	for n, frame := range g.Image {
+		if frame == nil {
+			return nil, fmt.Errorf("GIF frame %d is nil", n)
+		}
+		disposal := byte(gif.DisposalNone)
+		if n < len(g.Disposal) {
+			disposal = g.Disposal[n]
+		}
+		var previous *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			previous = copyFrame(canvas)
+		}
+
+		draw.Draw(canvas, frame.Rect, frame, frame.Rect.Min, draw.Over)
+		mode.frames = append(mode.frames, copyFrame(canvas))
+		var delay int
+		if n < len(g.Delay) {
+			delay = g.Delay[n]
+		}
+		mode.frameDurations = append(mode.frameDurations, time.Duration(delay)*10*time.Millisecond)
+
+		// Prepare the canvas for the next frame.
+		switch disposal {
+		case gif.DisposalBackground:
+			// As is usual (e.g. in browsers), the background is taken to be transparent rather than g.Config's
+			// background color.
+			draw.Draw(canvas, frame.Rect, image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			draw.Draw(canvas, bounds, previous, image.Point{}, draw.Src)
+		}
+	}
+	mode.framesChanged()
+
+	return &Entity{
+		name:             name,
+		modes:            map[int]*Mode{0: mode},
+		modeNamesToIndex: map[string]int{modeName: 0},
+	}, nil
+}
@@ -0,0 +1,82 @@
+package sprites
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+	"time"
+)
+
+// deltaGIF returns a 4x4, 3 frame GIF with the given disposal methods, whose later frames are deltas: frame 0 is all
+// red, frame 1 a single green pixel at (1,1), and frame 2 a 2x2 region at (2,2), transparent but for a green pixel at
+// (3,2).
+func deltaGIF(disposal []byte) *gif.GIF {
+	palette := color.Palette{color.RGBA{}, color.RGBA{R: 255, A: 255}, color.RGBA{G: 255, A: 255}}
+	frame0 := image.NewPaletted(image.Rect(0, 0, 4, 4), palette)
+	for n := range frame0.Pix {
+		frame0.Pix[n] = 1
+	}
+	frame1 := image.NewPaletted(image.Rect(1, 1, 2, 2), palette)
+	frame1.Pix[0] = 2
+	frame2 := image.NewPaletted(image.Rect(2, 2, 4, 4), palette)
+	frame2.Pix = []uint8{0, 2, 0, 0}
+	return &gif.GIF{
+		Image:    []*image.Paletted{frame0, frame1, frame2},
+		Delay:    []int{5, 10, 20},
+		Disposal: disposal,
+		Config:   image.Config{Width: 4, Height: 4},
+	}
+}
+
+func TestNewEntityFromGIF(t *testing.T) {
+	red, green := color.RGBA{R: 255, A: 255}, color.RGBA{G: 255, A: 255}
+	none := []byte{gif.DisposalNone, gif.DisposalNone, gif.DisposalNone}
+	e, err := NewEntityFromGIF(deltaGIF(none), "gif", "play")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := e.GetModeByName("play")
+	if err != nil || e.Name() != "gif" {
+		t.Fatalf("Entity %s has no Mode play (%v)", e.Name(), err)
+	}
+	if m.FrameCount() != 3 || m.SpriteSize() != image.Rect(0, 0, 4, 4) {
+		t.Fatalf("%d frames of %v, want 3 of 4x4", m.FrameCount(), m.SpriteSize())
+	}
+	for f, want := range []time.Duration{50 * time.Millisecond, 100 * time.Millisecond, 200 * time.Millisecond} {
+		if d := m.FrameDuration(f); d != want {
+			t.Fatalf("frame %d duration is %v, want %v", f, d, want)
+		}
+	}
+
+	// Without disposal, each frame is drawn over the last, and transparent pixels leave those beneath.
+	last, _ := m.FrameCopy(2)
+	if last.RGBAAt(0, 0) != red || last.RGBAAt(1, 1) != green || last.RGBAAt(2, 2) != red || last.RGBAAt(3, 2) != green {
+		t.Fatalf("composited last frame is %v", last.Pix)
+	}
+	if !m.FullyOpaque() {
+		t.Error("composited opaque frames are not FullyOpaque")
+	}
+
+	// Disposing of frame 1 to the previous frame removes its green pixel, and to the background clears it.
+	for disposal, want := range map[byte]color.RGBA{gif.DisposalPrevious: red, gif.DisposalBackground: {}} {
+		e, err := NewEntityFromGIF(deltaGIF([]byte{gif.DisposalNone, disposal, gif.DisposalNone}), "gif", "play")
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := mustMode(t, e, 0)
+		if frame, _ := m.FrameCopy(1); frame.RGBAAt(1, 1) != green {
+			t.Fatalf("disposal %d: frame 1 was disposed of before being recorded", disposal)
+		}
+		if frame, _ := m.FrameCopy(2); frame.RGBAAt(1, 1) != want || frame.RGBAAt(3, 2) != green {
+			t.Fatalf("disposal %d: last frame pixel is %v, want %v", disposal, frame.RGBAAt(1, 1), want)
+		}
+	}
+
+	if i := mustInstance(t, e, 0); i.Frame() == nil {
+		t.Error("Instance of the GIF Entity has no frame")
+	}
+	if _, err := NewEntityFromGIF(&gif.GIF{}, "gif", "play"); err == nil {
+		t.Error("NewEntityFromGIF of a GIF without frames did not fail")
+	}
+}
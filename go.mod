@@ -7,4 +7,6 @@ go 1.16
 require (
 	github.com/HaileyStorm/CCSL_go v0.0.0-20211023202908-d9f4deefba1e
 	github.com/corona10/goimagehash v1.0.3
+	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646
+	golang.org/x/image v0.0.0-20211028202545-6944b10bf410
 )
@@ -0,0 +1,217 @@
+package sprites
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/corona10/goimagehash"
+)
+
+// HashAlgo selects the perceptual hash algorithm used to hash Sprites.
+//
+// HashAverage is the fastest, and finds identical and near-identical (e.g. slightly recolored) images well, but is
+// easily thrown off by gamma or contrast changes. HashDifference is nearly as fast and is more robust to such
+// global changes, making it a good default for deduplication. HashPerception (DCT-based) is the slowest but the most
+// robust to small edits, scaling and noise.
+// Note that all three hash a grayscale, downscaled version of the image, so Sprites differing only in hue (or alpha)
+// may hash equal.
+type HashAlgo int
+
+const (
+	// HashAverage is the average hash (aHash) algorithm. This is what SpriteHash uses.
+	HashAverage HashAlgo = iota
+	// HashDifference is the difference hash (dHash) algorithm.
+	HashDifference
+	// HashPerception is the perception hash (pHash) algorithm.
+	HashPerception
+)
+
+// String returns the name of the algorithm.
+func (a HashAlgo) String() string {
+	switch a {
+	case HashAverage:
+		return "average"
+	case HashDifference:
+		return "difference"
+	case HashPerception:
+		return "perception"
+	default:
+		return fmt.Sprintf("HashAlgo(%d)", int(a))
+	}
+}
+
+// SpriteHash gets a string hash representation of sprite, using the average hash algorithm.
+// If the hash cannot be computed, the error message is returned in place of the hash; use SpriteHashErr to be able to
+// distinguish the two.
+//
+// License(s) - see internal\licenses:
+// goimagehash
+func SpriteHash(sprite Sprite) string {
+	hashstr, err := SpriteHashErr(sprite)
+	if err != nil {
+		return err.Error()
+	}
+	return hashstr
+}
+
+// SpriteHashErr gets a string hash representation of sprite, using the average hash algorithm. Unlike SpriteHash, a
+// failure to compute the hash is returned as an error (and the hash is then "").
+//
+// License(s) - see internal\licenses:
+// goimagehash
+func SpriteHashErr(sprite Sprite) (string, error) {
+	return SpriteHashWith(sprite, HashAverage)
+}
+
+// SpriteHashWith gets a (64 bit) string hash representation of sprite, using the algorithm algo. The string is in the
+// goimagehash ToString format (e.g. "a:00ff00ff00ff00ff").
+//
+// License(s) - see internal\licenses:
+// goimagehash
+func SpriteHashWith(sprite Sprite, algo HashAlgo) (string, error) {
+	return computeHash(func() (string, error) {
+		var hash *goimagehash.ImageHash
+		var err error
+		switch algo {
+		case HashAverage:
+			hash, err = goimagehash.AverageHash(sprite)
+		case HashDifference:
+			hash, err = goimagehash.DifferenceHash(sprite)
+		case HashPerception:
+			hash, err = goimagehash.PerceptionHash(sprite)
+		default:
+			return "", fmt.Errorf("unknown hash algorithm %v", algo)
+		}
+		if err != nil {
+			return "", err
+		}
+		return hash.ToString(), nil
+	})
+}
+
+// SpriteHashExt is like SpriteHashWith, but computes a hash of width * height bits (e.g. 16 x 16 for a 256 bit hash),
+// which distinguishes between images in more detail. width * height must be a power of 2. The string is in the
+// goimagehash ExtImageHash ToString format.
+//
+// License(s) - see internal\licenses:
+// goimagehash
+func SpriteHashExt(sprite Sprite, algo HashAlgo, width, height int) (string, error) {
+	size := width * height
+	if width <= 0 || height <= 0 || size&(size-1) != 0 {
+		return "", fmt.Errorf("hash width (%d) and height (%d) must be > 0 and width * height must be a power of 2", width, height)
+	}
+	return computeHash(func() (string, error) {
+		var hash *goimagehash.ExtImageHash
+		var err error
+		switch algo {
+		case HashAverage:
+			hash, err = goimagehash.ExtAverageHash(sprite, width, height)
+		case HashDifference:
+			hash, err = goimagehash.ExtDifferenceHash(sprite, width, height)
+		case HashPerception:
+			hash, err = goimagehash.ExtPerceptionHash(sprite, width, height)
+		default:
+			return "", fmt.Errorf("unknown hash algorithm %v", algo)
+		}
+		if err != nil {
+			return "", err
+		}
+		return hash.ToString(), nil
+	})
+}
+
+// ComputeFrameHashes computes the hash (see SpriteHashWith) of every frame of every Entity's Modes using algo, and
+// caches them in the Modes, to be retrieved with Mode.FrameHash. Hashes previously computed (with any algorithm) are
+// replaced. The Modes are hashed in parallel, by a pool of runtime.NumCPU() workers. If any frame cannot be hashed, its
+// Mode is left without hashes, and the first such error is returned once the remaining Modes have been hashed.
+func (s *Sheet) ComputeFrameHashes(algo HashAlgo) error {
+	var modes []*Mode
+	s.mu.RLock()
+	for _, idx := range s.sortedIndexes() {
+		e := s.entities[idx]
+		for _, modeIdx := range e.sortedModeIndexes() {
+			modes = append(modes, e.modes[modeIdx])
+		}
+	}
+	s.mu.RUnlock()
+
+	errs := make([]error, len(modes))
+	workers := runtime.NumCPU()
+	if workers > len(modes) {
+		workers = len(modes)
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = modes[i].computeFrameHashes(algo)
+			}
+		}()
+	}
+	for i := range modes {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("mode %s: %w", modes[i].name, err)
+		}
+	}
+	return nil
+}
+
+// computeFrameHashes computes and caches the hash of each of the Mode's frames using algo. See
+// Sheet.ComputeFrameHashes.
+func (m *Mode) computeFrameHashes(algo HashAlgo) error {
+	m.loadFrames()
+	hashes := make([]string, len(m.frames))
+	for i, frame := range m.frames {
+		hash, err := SpriteHashWith(frame, algo)
+		if err != nil {
+			m.cache.mu.Lock()
+			m.cache.hashes = nil
+			m.cache.mu.Unlock()
+			return fmt.Errorf("frame %d: %w", i, err)
+		}
+		hashes[i] = hash
+	}
+	m.cache.mu.Lock()
+	m.cache.hashes = hashes
+	m.cache.mu.Unlock()
+	return nil
+}
+
+// FrameHash returns the hash of the frame at index, as cached by Sheet.ComputeFrameHashes (using the algorithm passed
+// to it). It is an error if the hashes have not been computed, or have since been discarded because the Mode's frames
+// changed.
+func (m *Mode) FrameHash(index int) (string, error) {
+	m.cache.mu.Lock()
+	defer m.cache.mu.Unlock()
+	if m.cache.hashes == nil {
+		return "", errors.New("frame hashes have not been computed; see Sheet.ComputeFrameHashes")
+	}
+	if index < 0 || index >= len(m.cache.hashes) {
+		return "", fmt.Errorf("frame index (%d) must be >= 0 and < the frame count (%d)", index, len(m.cache.hashes))
+	}
+	return m.cache.hashes[index], nil
+}
+
+// computeHash calls hashFunc, converting any panic to an error; goimagehash may panic (index out of bounds) on some
+// images, rather than returning an error.
+func computeHash(hashFunc func() (string, error)) (hashstr string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			hashstr = ""
+			err = fmt.Errorf("hash index out of bounds error: %v", r)
+			logf("sprites: recovered from panic while hashing: %v", r)
+		}
+	}()
+	return hashFunc()
+}
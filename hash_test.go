@@ -0,0 +1,110 @@
+package sprites
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestSpriteHashErr(t *testing.T) {
+	frame, _ := mustMode(t, mustEntity(t, mustSheet(t), 3), 2).GetFrame(3)
+	hash, err := SpriteHashErr(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash == "" || SpriteHash(frame) != hash {
+		t.Fatalf("SpriteHashErr gave %q, SpriteHash %q", hash, SpriteHash(frame))
+	}
+
+	// goimagehash cannot hash an empty image.
+	empty := image.NewRGBA(image.Rect(0, 0, 0, 0))
+	hash, err = SpriteHashErr(empty)
+	if err == nil || hash != "" {
+		t.Fatalf("SpriteHashErr of an empty image gave %q, %v; want an error", hash, err)
+	}
+	if SpriteHash(empty) != err.Error() {
+		t.Fatalf("SpriteHash of an empty image gave %q, want %q", SpriteHash(empty), err)
+	}
+}
+
+// gradientImage returns a 16x16 grayscale gradient, increasing to the right, or downwards if vertical.
+func gradientImage(vertical bool) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			v := x
+			if vertical {
+				v = y
+			}
+			img.Set(x, y, color.Gray{Y: uint8(v * 16)})
+		}
+	}
+	return img
+}
+
+func TestSpriteHashWith(t *testing.T) {
+	for _, algo := range []HashAlgo{HashAverage, HashDifference, HashPerception} {
+		h1, err := SpriteHashWith(gradientImage(false), algo)
+		if err != nil {
+			t.Fatalf("%v: %v", algo, err)
+		}
+		h2, _ := SpriteHashWith(gradientImage(false), algo)
+		h3, _ := SpriteHashWith(gradientImage(true), algo)
+		if h1 != h2 {
+			t.Fatalf("%v: identical images hashed %q and %q", algo, h1, h2)
+		}
+		if h1 == h3 {
+			t.Fatalf("%v: distinct images both hashed %q", algo, h1)
+		}
+
+		x1, err := SpriteHashExt(gradientImage(false), algo, 16, 16)
+		if err != nil {
+			t.Fatalf("%v: %v", algo, err)
+		}
+		x2, _ := SpriteHashExt(gradientImage(true), algo, 16, 16)
+		if x1 == x2 {
+			t.Fatalf("%v: distinct images both hashed %q at 256 bits", algo, x1)
+		}
+	}
+	if _, err := SpriteHashExt(gradientImage(false), HashAverage, 3, 3); err == nil {
+		t.Fatal("SpriteHashExt accepted a 9 bit hash")
+	}
+	if _, err := SpriteHashWith(gradientImage(false), HashAlgo(99)); err == nil {
+		t.Fatal("SpriteHashWith accepted an unknown algorithm")
+	}
+}
+
+func TestComputeFrameHashes(t *testing.T) {
+	s := mustSheet(t)
+	m := mustMode(t, mustEntity(t, s, 2), 1)
+	if _, err := m.FrameHash(0); err == nil {
+		t.Fatal("FrameHash before ComputeFrameHashes did not fail")
+	}
+	for _, algo := range []HashAlgo{HashDifference, HashAverage} {
+		if err := s.ComputeFrameHashes(algo); err != nil {
+			t.Fatal(err)
+		}
+		for n := 0; n < s.EntityCount(); n++ {
+			for j := 0; j < 3; j++ {
+				mode := mustMode(t, mustEntity(t, s, n), j)
+				for f := 0; f < mode.FrameCount(); f++ {
+					frame, _ := mode.GetFrame(f)
+					want, _ := SpriteHashWith(frame, algo)
+					if got, err := mode.FrameHash(f); err != nil || got != want {
+						t.Fatalf("%v: cached hash of Entity %d Mode %d frame %d is %q (%v), want %q", algo, n, j, f, got, err, want)
+					}
+				}
+			}
+		}
+	}
+	if _, err := m.FrameHash(4); err == nil {
+		t.Error("FrameHash of an out of range frame did not fail")
+	}
+	// Changing the frames discards the hashes.
+	if err := m.RemoveFrame(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.FrameHash(0); err == nil {
+		t.Error("FrameHash after RemoveFrame did not fail")
+	}
+}
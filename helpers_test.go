@@ -0,0 +1,117 @@
+package sprites
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// testSheetImage returns a sheet image laid out as described by d, in which every cell is filled with its own opaque
+// color: the red channel is 10 times the cell's column, and the green channel 10 times its row.
+func testSheetImage(d SheetDimensions) *image.RGBA {
+	d.init()
+	w := d.EntitiesPerRow * d.numEntityColumns * d.SpriteWidth
+	h := d.EntitiesPerColumn * d.numEntityRows * d.SpriteHeight
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, cellColor(x/d.SpriteWidth, y/d.SpriteHeight))
+		}
+	}
+	return img
+}
+
+// cellColor returns the color testSheetImage fills the cell at column cx and row cy with.
+func cellColor(cx, cy int) color.RGBA {
+	return color.RGBA{R: uint8(cx * 10), G: uint8(cy * 10), B: 7, A: 255}
+}
+
+// basicDims returns the layout of a small sheet: a 2x2 grid of Entities, each with 3 Modes of 4 frames of 4x4 pixels.
+func basicDims() SheetDimensions {
+	return SheetDimensions{EntitiesPerRow: 2, EntitiesPerColumn: 2, ModesPerEntity: 3, FramesPerAnimation: 4, SpriteWidth: 4, SpriteHeight: 4}
+}
+
+// mustSheet returns a Sheet of testSheetImage(basicDims()).
+func mustSheet(t testing.TB) *Sheet {
+	t.Helper()
+	s, err := NewSheet(testSheetImage(basicDims()), basicDims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// mustEntity returns the Entity with index idx of s.
+func mustEntity(t testing.TB, s *Sheet, idx int) *Entity {
+	t.Helper()
+	e, err := s.GetEntityByIndex(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// mustMode returns the Mode with index idx of e.
+func mustMode(t testing.TB, e *Entity, idx int) *Mode {
+	t.Helper()
+	m, err := e.GetModeByIndex(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// mustInstance returns a new Instance of e in the Mode with index mode.
+func mustInstance(t testing.TB, e *Entity, mode int) *Instance {
+	t.Helper()
+	i, err := e.NewInstance(mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return i
+}
+
+// singleSpriteSheet returns a Sheet of img as a single sprite: one Entity, with one Mode of one frame.
+func singleSpriteSheet(t testing.TB, img *image.RGBA) *Sheet {
+	t.Helper()
+	size := img.Bounds().Size()
+	s, err := NewSheet(img, SheetDimensions{EntitiesPerRow: 1, EntitiesPerColumn: 1, ModesPerEntity: 1,
+		FramesPerAnimation: 1, SpriteWidth: size.X, SpriteHeight: size.Y})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// singleSpriteEntity returns the only Entity of singleSpriteSheet(t, img).
+func singleSpriteEntity(t testing.TB, img *image.RGBA) *Entity {
+	t.Helper()
+	return mustEntity(t, singleSpriteSheet(t, img), 0)
+}
+
+// singleSpriteMode returns the only Mode of singleSpriteEntity(t, img).
+func singleSpriteMode(t testing.TB, img *image.RGBA) *Mode {
+	t.Helper()
+	return mustMode(t, singleSpriteEntity(t, img), 0)
+}
+
+// filledImage returns a w x h image filled with c.
+func filledImage(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// assertColor fails t if the color of img at (x, y) is not want.
+func assertColor(t *testing.T, img image.Image, x, y int, want color.Color) {
+	t.Helper()
+	gr, gg, gb, ga := img.At(x, y).RGBA()
+	wr, wg, wb, wa := want.RGBA()
+	if gr != wr || gg != wg || gb != wb || ga != wa {
+		t.Fatalf("color at (%d,%d) = %v, want %v", x, y, img.At(x, y), want)
+	}
+}
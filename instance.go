@@ -1,9 +1,12 @@
 package sprites
 
 import (
+	"errors"
 	"fmt"
 	"image"
+	"image/color"
 	"image/draw"
+	"math"
 
 	ccsl_graphics "github.com/HaileyStorm/CCSL_go/graphics"
 )
@@ -25,18 +28,81 @@ func (i *Instance) SetName(name string) {
 	i.name = name
 }
 
+// Clone returns a new Instance of the same Entity, in the same Mode and with the same running state and speed scale as
+// i, but with its own animation starting from the first frame. The clone shares the (read-only) Entity and Mode data with i, but
+// advancing one does not advance the other. The clone has no name.
+func (i *Instance) Clone() *Instance {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	a := newAnimation(i.Mode)
+	a.running = i.running
+	a.speedScale = i.speedScale
+	return i.Entity.track(&Instance{
+		Entity:    i.Entity,
+		animation: a,
+	})
+}
+
+// Detach returns a new Instance like Clone does, but of a deep copy of i's Entity: every Mode, and every frame's pixels,
+// is copied into newly allocated images, so the detached Instance shares nothing with i, the Sheet or any other
+// Instance, and its Entity, Modes and frames may be edited freely (e.g. with RenameMode, InsertFrame and RemoveFrame)
+// without affecting them. This is the heavy counterpart to Clone: the detached Instance holds its own copy of all of the
+// Entity's frame data, which costs 4 bytes per pixel of every frame of every Mode, so it is intended for editors and the
+// like rather than for use in bulk.
+func (i *Instance) Detach() *Instance {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	entity := i.Entity.deepCopy()
+	var mode *Mode
+	for idx, m := range i.Entity.modes {
+		if m == i.Mode {
+			mode = entity.modes[idx]
+		}
+	}
+	if mode == nil {
+		// The current Mode does not belong to the Entity (e.g. it was removed from it).
+		mode = i.Mode.deepCopy()
+	}
+	a := newAnimation(mode)
+	a.running = i.running
+	a.speedScale = i.speedScale
+	return &Instance{
+		Entity:    entity,
+		animation: a,
+	}
+}
+
 //note in docstrings that changing mode does NOT stop or restart the animation
 // (if it was running, it still will be, and the currentFrame will be the same and Frame will get that frame from the
 // new mode - except that currentFrame is modulo'd with the len(frames) to ensure it's in range)
 func (i *Instance) SetModeByIndex(index int) error {
 	if mode, ok := i.modes[index]; ok {
-		i.Mode = mode
+		i.setMode(mode)
 		return nil
 	} else {
 		return fmt.Errorf("mode with index %d does not exist in instance Entity", index)
 	}
 }
 
+// SetModeByIndexClamped is like SetModeByIndex, but rather than returning an error for an index which is out of range,
+// it uses the nearest valid index: 0 for a negative index, or ModeCount()-1 for one which is too large. It returns the
+// index used, or -1 (leaving the Mode unchanged) if the Entity has no Modes.
+func (i *Instance) SetModeByIndexClamped(index int) int {
+	count := len(i.modes)
+	if count == 0 {
+		return -1
+	}
+	if index < 0 {
+		index = 0
+	} else if index >= count {
+		index = count - 1
+	}
+	if err := i.SetModeByIndex(index); err != nil {
+		return -1
+	}
+	return index
+}
+
 //note in docstrings that changing mode does NOT stop or restart the animation
 // (if it was running, it still will be, and the currentFrame will be the same and Frame will get that frame from the
 // new mode - except that currentFrame is modulo'd with the len(frames) to ensure it's in range)
@@ -45,7 +111,7 @@ func (i *Instance) SetModeByName(name string) error {
 	if ok {
 		mode, ok := i.modes[idx]
 		if ok {
-			i.Mode = mode
+			i.setMode(mode)
 			return nil
 		} else {
 			panic(fmt.Errorf("internal error: Mode with index %d does not exist in Entity; Entity is corrupted", idx))
@@ -56,34 +122,336 @@ func (i *Instance) SetModeByName(name string) error {
 
 }
 
+// QueueModes plays a sequence of the Instance's Modes, by name: the first Mode starts immediately (from its first
+// frame), and each Mode then plays through once before the animation switches to the next (again from its first
+// frame). The final Mode is not followed by any other, so it loops as usual - e.g. QueueModes("windup", "strike",
+// "recover", "idle") plays an attack and then settles on idle.
+// Any previously queued Modes are replaced. Changing the Mode with SetModeByIndex or SetModeByName, or calling
+// ClearQueue, discards the queue. QueueModes does not change whether the animation is running; the queue only
+// progresses while it is.
+func (i *Instance) QueueModes(names ...string) error {
+	if len(names) == 0 {
+		return errors.New("at least one Mode name must be queued")
+	}
+	modes := make([]*Mode, len(names))
+	for n, name := range names {
+		mode, err := i.GetModeByName(name)
+		if err != nil {
+			return err
+		}
+		modes[n] = mode
+	}
+
+	i.mu.Lock()
+	defer i.unlock()
+	i.switchMode(modes[0])
+	i.currentFrame = 0
+	i.progress = 0
+	i.loopsCompleted = 0
+	i.queue = modes[1:]
+	return nil
+}
+
 // note that placeAt is expected to be within canvas.Bounds() (that is, not necessarily relative to (0,0))
-// note that it gets next frame and places that. To not advance the animation, first stop it and then call this (and then start it again)
+// note that it gets next frame and places that (advancing the animation, as Frame does). To not advance the animation, use PlaceOnNoAdvance
 func (i *Instance) PlaceOn(canvas draw.Image, placeAt image.Point) {
-	frame := i.Frame()
-	i.place(frame, canvas, placeAt, i.SpriteSize())
+	frame, mode := i.frameAndMode()
+	place(frame, mode.isFullyOpaque(), canvas, placeAt)
+}
+
+// PlaceOnNoAdvance is like PlaceOn, but places the current frame without advancing the animation (see CurrentFrame).
+// To draw an Instance to several canvases each update (e.g. the main view and a minimap), place it on each with
+// PlaceOnNoAdvance and then call Advance once, so the animation does not run faster the more targets it is drawn to.
+func (i *Instance) PlaceOnNoAdvance(canvas draw.Image, placeAt image.Point) {
+	frame, mode := i.currentFrameAndMode()
+	place(frame, mode.isFullyOpaque(), canvas, placeAt)
 }
 
 func (i *Instance) PlaceOnResized(canvas draw.Image, placeAt image.Point, w, h uint) {
-	frame := i.Frame()
-	i.place(ccsl_graphics.ResizeMaintain(frame.(*image.RGBA), w, h), canvas, placeAt, i.SpriteSize())
+	index, mode := i.frameIndexAndMode()
+	place(mode.resizedFrame(index, w, h, mode.resizeFilter), mode.isFullyOpaque(), canvas, placeAt)
+}
+
+// PlaceOnClipped is like PlaceOn, but only draws the part of the frame within clip (e.g. a viewport within canvas),
+// which is in canvas' coordinate space. A frame entirely within clip is drawn exactly as by PlaceOn. The animation is
+// advanced even if the frame is entirely outside clip.
+func (i *Instance) PlaceOnClipped(canvas draw.Image, placeAt image.Point, clip image.Rectangle) {
+	frame, mode := i.frameAndMode()
+	placeClipped(frame, mode.isFullyOpaque(), canvas, fastCanvas(canvas), placeAt, clip)
+}
+
+// PlaceOnTiled is like PlaceOn, but repeats the current frame to fill region of canvas, e.g. for a scrolling
+// background: the frame is tiled in a grid with a tile's top-left at region.Min + offset (so scrolling is a matter of
+// changing offset, by any amount in either direction), and tiles at the edges of region are clipped to it. The
+// animation advances once, as for PlaceOn, and every tile is the same frame.
+func (i *Instance) PlaceOnTiled(canvas draw.Image, region image.Rectangle, offset image.Point) {
+	frame, mode := i.frameAndMode()
+	size := frame.Bounds().Size()
+	if region.Empty() || size.X == 0 || size.Y == 0 {
+		return
+	}
+	opaque := mode.isFullyOpaque()
+	img := fastCanvas(canvas)
+	// Start from the tile covering region.Min.
+	start := region.Min.Add(image.Point{X: wrapIndex(offset.X, size.X), Y: wrapIndex(offset.Y, size.Y)})
+	if start.X > region.Min.X {
+		start.X -= size.X
+	}
+	if start.Y > region.Min.Y {
+		start.Y -= size.Y
+	}
+	for y := start.Y; y < region.Max.Y; y += size.Y {
+		for x := start.X; x < region.Max.X; x += size.X {
+			placeClipped(frame, opaque, canvas, img, image.Point{X: x, Y: y}, region)
+		}
+	}
+}
+
+// PlaceOnScaled is like PlaceOn, but draws the frame scaled by scaleX and scaleY (which must be > 0), into a
+// round(w*scaleX) x round(h*scaleY) rectangle at placeAt, using the Mode's resize filter (from
+// SheetDimensions.ResizeFilter). Unlike PlaceOnResized, no resized copy of the frame is made (or cached), so it suits
+// sizes which are used only briefly or change often. At a scale of 1 it is the same as PlaceOn.
+func (i *Instance) PlaceOnScaled(canvas draw.Image, placeAt image.Point, scaleX, scaleY float64) {
+	frame, mode := i.frameAndMode()
+	if scaleX == 1 && scaleY == 1 {
+		place(frame, mode.isFullyOpaque(), canvas, placeAt)
+		return
+	}
+	size := frame.Bounds().Size()
+	dst := image.Rect(0, 0, int(math.Round(float64(size.X)*scaleX)), int(math.Round(float64(size.Y)*scaleY))).Add(placeAt)
+	op := draw.Over
+	if mode.isFullyOpaque() {
+		op = draw.Src
+	}
+	mode.resizeFilter.scaler().Scale(canvas, dst, frame, frame.Bounds(), op, nil)
+}
+
+// PlaceOnBlend is like PlaceOn, but combines the frame with canvas using mode (see BlendMode). Modes other than
+// BlendOver are computed per pixel, and so are considerably slower than PlaceOn.
+func (i *Instance) PlaceOnBlend(canvas draw.Image, placeAt image.Point, mode BlendMode) {
+	frame, m := i.frameAndMode()
+	if mode == BlendOver {
+		place(frame, m.isFullyOpaque(), canvas, placeAt)
+		return
+	}
+	blend(frame, canvas, placedRect(frame, placeAt), mode)
+}
+
+// PlaceOnLinear is like PlaceOn, but composites partially transparent pixels in linear light rather than directly on
+// their sRGB-encoded values, avoiding the dark fringes draw.Over gives soft edges, shadows and glows over light or
+// varied backgrounds. It is considerably slower than PlaceOn, and makes no difference to fully opaque or fully
+// transparent pixels, so most pixel art does not need it.
+func (i *Instance) PlaceOnLinear(canvas draw.Image, placeAt image.Point) {
+	frame, mode := i.frameAndMode()
+	if mode.isFullyOpaque() {
+		place(frame, true, canvas, placeAt)
+		return
+	}
+	placeLinear(frame, canvas, placedRect(frame, placeAt))
+}
+
+// PlaceOnAnchored is like PlaceOn, but positions the frame so that its Mode's anchor (see Mode.SetAnchor) lands on
+// anchorAt; that is, the frame is placed at anchorAt - Anchor().
+func (i *Instance) PlaceOnAnchored(canvas draw.Image, anchorAt image.Point) {
+	frame, mode := i.frameAndMode()
+	place(frame, mode.isFullyOpaque(), canvas, anchorAt.Sub(mode.anchor))
+}
+
+// PlaceOnPaletted is like PlaceOn, but for an *image.Paletted canvas (e.g. when assembling GIF frames), so each pixel
+// of the frame is mapped to the nearest color in canvas.Palette, by RGB distance. Pixels with alpha at or above
+// PalettedAlphaThreshold are treated as opaque, and never mapped to a palette entry which is itself transparent (alpha
+// 0). Pixels with a lower alpha are set to the palette's transparent index - its first entry with alpha 0 - so that
+// stale pixels do not show through when a canvas is reused; if the palette has no transparent entry, those pixels
+// leave canvas unchanged. If the palette has no opaque entries, the opaque pixels leave canvas unchanged.
+// It returns an error, without advancing the animation, if canvas.Palette has more than 256 entries.
+func (i *Instance) PlaceOnPaletted(canvas *image.Paletted, placeAt image.Point) error {
+	matcher, err := newPaletteMatcher(canvas.Palette)
+	if err != nil {
+		return err
+	}
+	frame := toRGBA(i.Frame())
+	dst := placedRect(frame, placeAt).Intersect(canvas.Rect)
+	offset := frame.Rect.Min.Sub(placeAt)
+	for y := dst.Min.Y; y < dst.Max.Y; y++ {
+		for x := dst.Min.X; x < dst.Max.X; x++ {
+			c := frame.RGBAAt(x+offset.X, y+offset.Y)
+			var index uint8
+			var ok bool
+			if c.A < PalettedAlphaThreshold {
+				index, ok = matcher.transparentIndex()
+			} else {
+				index, ok = matcher.index(c)
+			}
+			if ok {
+				canvas.SetColorIndex(x, y, index)
+			}
+		}
+	}
+	return nil
+}
+
+// PlaceOnOnionSkin is like PlaceOn, but for debugging animations also draws "ghosts" of the before preceding and after
+// following frames (wrapping around the Mode's frames), at an alpha of ghostAlpha, beneath the current frame: the
+// ghosts are drawn farthest first, and the current frame is drawn last, at full alpha, on top. It is slower than
+// PlaceOn, and intended only as a development aid.
+func (i *Instance) PlaceOnOnionSkin(canvas draw.Image, placeAt image.Point, before, after int, ghostAlpha uint8) {
+	index, mode := i.frameIndexAndMode()
+	ghostMask := image.NewUniform(color.Alpha{A: ghostAlpha})
+	drawGhost := func(offset int) {
+		frame := mode.GetFrameWrapped(index + offset)
+		draw.DrawMask(canvas, placedRect(frame, placeAt), frame, frame.Bounds().Min, ghostMask, image.Point{}, draw.Over)
+	}
+	for offset := before; offset > 0; offset-- {
+		drawGhost(-offset)
+	}
+	for offset := after; offset > 0; offset-- {
+		drawGhost(offset)
+	}
+	place(mode.frameAt(index), mode.isFullyOpaque(), canvas, placeAt)
+}
+
+// Bounds returns the rectangle of canvas which PlaceOn (or PlaceOnAnchored, etc., given the corresponding point) would
+// draw the current frame to, if it were placed at placeAt. It does not advance the animation.
+func (i *Instance) Bounds(placeAt image.Point) image.Rectangle {
+	frame, _ := i.currentFrameAndMode()
+	return placedRect(frame, placeAt)
+}
+
+// VisibleBounds is like Bounds, but returns only the smallest rectangle containing the current frame's visible (not
+// fully transparent) pixels. If the frame is entirely transparent, it returns an empty rectangle at placeAt. The
+// visible bounds of each frame are cached by the Mode.
+func (i *Instance) VisibleBounds(placeAt image.Point) image.Rectangle {
+	index, mode := i.currentFrameIndexAndMode()
+	return mode.visibleBounds(index).Add(placeAt)
+}
+
+// placedRect returns the rectangle frame occupies when placed at placeAt.
+func placedRect(frame Sprite, placeAt image.Point) image.Rectangle {
+	return frame.Bounds().Sub(frame.Bounds().Min).Add(placeAt)
+}
+
+// Placement is an Instance and the point (within the canvas' Bounds) at which to place it. See PlaceAll.
+type Placement struct {
+	Instance *Instance
+	At       image.Point
+}
+
+// PlaceAll places each of the placements' Instances on canvas, in order (so later placements are drawn over earlier
+// ones), exactly as calling PlaceOn for each would: each Instance's animation advances once. It is faster than doing
+// so for large numbers of Instances, as the canvas type is checked only once.
+func PlaceAll(canvas draw.Image, placements []Placement) {
+	img := fastCanvas(canvas)
+	for _, p := range placements {
+		frame, mode := p.Instance.frameAndMode()
+		placeOn(frame, mode.isFullyOpaque(), canvas, img, p.At)
+	}
+}
+
+// ComposeInstances draws the current frames of the Instances in order, bottom to top (with draw.Over), into a single new
+// image the size of their sprites, for example to combine the body, armor and weapon layers of a character. It
+// advances each Instance's animation once. All the Instances' current Modes must have the same sprite size (see
+// Mode.SpriteSize, which may differ from Entity.SpriteSize for a cropped Mode); if not, no animation is advanced and an
+// error is returned. See also ComposeInstancesNoAdvance.
+func ComposeInstances(order []*Instance) (*image.RGBA, error) {
+	return composeInstances(order, (*animation).frameAndMode, ExportOptions{})
+}
+
+// ComposeInstancesWith is like ComposeInstances, but with opts; if opts.Background is set, the Instances are drawn over
+// it.
+func ComposeInstancesWith(order []*Instance, opts ExportOptions) (*image.RGBA, error) {
+	return composeInstances(order, (*animation).frameAndMode, opts)
+}
+
+// ComposeInstancesNoAdvance is like ComposeInstances, but does not advance the animations.
+func ComposeInstancesNoAdvance(order []*Instance) (*image.RGBA, error) {
+	return composeInstances(order, (*animation).currentFrameAndMode, ExportOptions{})
+}
+
+// ComposeInstancesNoAdvanceWith is like ComposeInstancesWith, but does not advance the animations.
+func ComposeInstancesNoAdvanceWith(order []*Instance, opts ExportOptions) (*image.RGBA, error) {
+	return composeInstances(order, (*animation).currentFrameAndMode, opts)
+}
+
+// composeInstances does the work of ComposeInstances, getting each Instance's frame with frameAndMode.
+func composeInstances(order []*Instance, frameAndMode func(a *animation) (Sprite, *Mode), opts ExportOptions) (*image.RGBA, error) {
+	if len(order) == 0 {
+		return nil, errors.New("at least one Instance must be composed")
+	}
+	_, first := order[0].currentFrameIndexAndMode()
+	size := first.SpriteSize().Size()
+	for n, instance := range order[1:] {
+		if _, mode := instance.currentFrameIndexAndMode(); mode.SpriteSize().Size() != size {
+			return nil, fmt.Errorf("sprite size of Instance %d (%v) does not match that of Instance 0 (%v)",
+				n+1, mode.SpriteSize().Size(), size)
+		}
+	}
+
+	composite := newCanvas(image.Rectangle{Max: size}, opts.Background)
+	for _, instance := range order {
+		frame, _ := frameAndMode(instance.animation)
+		draw.Draw(composite, composite.Rect, frame, frame.Bounds().Min, draw.Over)
+	}
+	return composite, nil
+}
+
+func place(frame Sprite, fullyOpaque bool, canvas draw.Image, placeAt image.Point) {
+	placeOn(frame, fullyOpaque, canvas, fastCanvas(canvas), placeAt)
+}
+
+// placeClipped is like placeOn, but only draws the part of frame within clip. img is canvas as returned by fastCanvas.
+func placeClipped(frame Sprite, fullyOpaque bool, canvas draw.Image, img *ccsl_graphics.Image, placeAt image.Point, clip image.Rectangle) {
+	dst := placedRect(frame, placeAt)
+	visible := dst.Intersect(clip)
+	if visible.Empty() {
+		return
+	}
+	if visible != dst {
+		frame = toRGBA(frame).SubImage(visible.Sub(placeAt).Add(frame.Bounds().Min))
+	}
+	placeOn(frame, fullyOpaque, canvas, img, visible.Min)
+}
+
+// fastCanvas returns canvas as a *ccsl_graphics.Image if it is one which placeOn can copy opaque frames onto directly,
+// or nil if it is not. That requires it to wrap an *image.RGBA or *image.NRGBA (which for opaque pixels have the same
+// representation), and for its calculated bytes per pixel to be 4, which is not the case for a SubImage (unless it
+// extends to the end of the original image's pixel data).
+func fastCanvas(canvas draw.Image) *ccsl_graphics.Image {
+	img, ok := canvas.(*ccsl_graphics.Image)
+	if !ok {
+		return nil
+	}
+	switch img.Imager.(type) {
+	case *image.RGBA, *image.NRGBA:
+	default:
+		return nil
+	}
+	// This matches the bytes per pixel calculation in ccsl_graphics.NewImage, which PlaceAtPoint uses.
+	if area := img.Rect.Dx() * img.Rect.Dy(); area == 0 || len(img.Pix)/area != 4 {
+		return nil
+	}
+	return img
 }
 
-func (i *Instance) place(frame Sprite, canvas draw.Image, placeAt image.Point, rect image.Rectangle) {
-	// SpriteSize (Rect) + Point = rect translated (placed at) Point. This is placement location on dst. The zero point + frame.Bounds().Min is the rect in source to grab
-	// (this is the only area on the source - frame - that has data, but has to be done because Bounds() does not always start at (0,0) - indeed if made from a SubImage it doesn't unless the location on the original started at (0,0))
+// placeOn does the work of place. img is canvas as returned by fastCanvas.
+func placeOn(frame Sprite, fullyOpaque bool, canvas draw.Image, img *ccsl_graphics.Image, placeAt image.Point) {
+	// The frame's bounds, translated to (placed at) placeAt, is the placement location on canvas. placeAt is in the
+	// canvas' coordinate space, which does not necessarily start at (0,0) - e.g. if canvas is a SubImage. Likewise,
+	// frame.Bounds().Min is the top-left of the frame's data, as a frame made from a SubImage does not start at (0,0)
+	// unless its location on the original did.
+	dst := placedRect(frame, placeAt)
 	// If frame is fully opaque, we can use one of two faster methods to place it on canvas. If not, we must use
 	// draw.Draw with draw.Over to respect the transparencies in combining it with canvas.
-	if i.Mode.fullyOpaque {
-		var img *ccsl_graphics.Image
-		var ok bool
-		// If canvas is a ccsl_graphics.Image, we can use the specialized/simplified PlaceAtPoint instead of draw.Draw,
-		// which is much faster (even with draw.Src and nil mask).
-		if img, ok = canvas.(*ccsl_graphics.Image); ok {
-			img.PlaceAtPoint(frame.(*image.RGBA), placeAt)
+	if fullyOpaque {
+		// If canvas is a suitable ccsl_graphics.Image, we can use the specialized/simplified PlaceAtPoint instead of
+		// draw.Draw, which is much faster (even with draw.Src and nil mask). PlaceAtPoint does not clip, and takes
+		// the point relative to the start of the image's pixel data rather than in its coordinate space, so it is
+		// only used when the frame lies entirely within the canvas.
+		if img != nil && dst.In(img.Rect) {
+			img.PlaceAtPoint(frame.(*image.RGBA), placeAt.Sub(img.Rect.Min))
 		} else {
-			draw.Draw(canvas, rect.Add(placeAt), frame, frame.Bounds().Min, draw.Src)
+			draw.Draw(canvas, dst, frame, frame.Bounds().Min, draw.Src)
 		}
 	} else {
-		draw.Draw(canvas, rect.Add(placeAt), frame, frame.Bounds().Min, draw.Over)
+		draw.Draw(canvas, dst, frame, frame.Bounds().Min, draw.Over)
 	}
 }
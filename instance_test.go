@@ -0,0 +1,654 @@
+package sprites
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"reflect"
+	"testing"
+
+	ccsl_graphics "github.com/HaileyStorm/CCSL_go/graphics"
+)
+
+func TestInstanceCloneIndependent(t *testing.T) {
+	a := mustInstance(t, mustEntity(t, mustSheet(t), 0), 1)
+	a.SetName("a")
+	a.SetSpeedScale(0.5)
+	a.StartAnimation()
+	a.Frame()
+	a.Frame()
+
+	b := a.Clone()
+	if b.Name() != "" || b.Mode != a.Mode || b.Entity != a.Entity || !b.Running() || b.SpeedScale() != 0.5 {
+		t.Fatalf("clone has name %q, running %v, speed %v", b.Name(), b.Running(), b.SpeedScale())
+	}
+	if b.CurrentFrameIndex() != 0 {
+		t.Fatalf("clone starts on frame %d, want 0", b.CurrentFrameIndex())
+	}
+
+	// Advancing one does not advance the other.
+	for k := 0; k < 4; k++ {
+		a.Frame()
+	}
+	if a.CurrentFrameIndex() != 3 || b.CurrentFrameIndex() != 0 {
+		t.Fatalf("frames %d and %d, want 3 and 0", a.CurrentFrameIndex(), b.CurrentFrameIndex())
+	}
+	b.PauseAnimation()
+	if !a.Running() {
+		t.Fatal("pausing the clone paused the original")
+	}
+}
+
+func TestQueueModes(t *testing.T) {
+	e := mustEntity(t, mustSheet(t), 0)
+	names := e.ModeNames()
+	for j, count := range []int{2, 3, 4} {
+		if err := mustMode(t, e, j).SetFrameCount(count); err != nil {
+			t.Fatal(err)
+		}
+	}
+	i := mustInstance(t, e, 1)
+	i.StartAnimation()
+	i.Frame()
+	if err := i.QueueModes(names[0], "nope"); err == nil {
+		t.Fatal("QueueModes accepted an unknown Mode name")
+	}
+	if i.Mode.Name() != names[1] || i.CurrentFrameIndex() != 1 {
+		t.Fatal("failed QueueModes changed the Mode or frame")
+	}
+
+	if err := i.QueueModes(names...); err != nil {
+		t.Fatal(err)
+	}
+	// Modes 0 and 1 play through once each, from their first frames, and Mode 2 then loops.
+	want := [][2]int{{0, 0}, {0, 1}, {1, 0}, {1, 1}, {1, 2}, {2, 0}, {2, 1}, {2, 2}, {2, 3}, {2, 0}, {2, 1}}
+	for n, w := range want {
+		if i.Mode.Name() != names[w[0]] || i.CurrentFrameIndex() != w[1] {
+			t.Fatalf("step %d: at %s frame %d, want %s frame %d", n, i.Mode.Name(), i.CurrentFrameIndex(), names[w[0]], w[1])
+		}
+		i.Frame()
+	}
+
+	if err := i.QueueModes(names[0], names[1]); err != nil {
+		t.Fatal(err)
+	}
+	i.ClearQueue()
+	for n := 0; n < 5; n++ {
+		if i.Mode.Name() != names[0] {
+			t.Fatalf("after ClearQueue, switched to %s", i.Mode.Name())
+		}
+		i.Frame()
+	}
+	if err := i.QueueModes(names[0], names[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.SetModeByName(names[2]); err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < 5; n++ {
+		if i.Mode.Name() != names[2] {
+			t.Fatalf("after SetModeByName, switched to %s", i.Mode.Name())
+		}
+		i.Frame()
+	}
+}
+
+// placeAllTest returns two identical sets of running Instances, including overlapping opaque and translucent ones,
+// and the points at which to place them.
+func placeAllTest(t testing.TB) (a, b []*Instance, at []image.Point) {
+	s := mustSheet(t)
+	for k := 0; k < 4; k++ {
+		i := mustInstance(t, mustEntity(t, s, k), k%3)
+		i.StartAnimation()
+		a = append(a, i)
+		at = append(at, image.Pt(k*3, k))
+	}
+	i := mustInstance(t, singleSpriteEntity(t, filledImage(6, 6, color.NRGBA{R: 200, G: 100, A: 128})), 0)
+	a = append(a, i)
+	at = append(at, image.Pt(2, 1))
+	for _, i := range a {
+		b = append(b, i.Clone())
+	}
+	return a, b, at
+}
+
+func TestPlaceAll(t *testing.T) {
+	for _, newCanvas := range []func() draw.Image{
+		func() draw.Image { return image.NewRGBA(image.Rect(0, 0, 20, 20)) },
+		func() draw.Image { return image.NewNRGBA(image.Rect(0, 0, 20, 20)) },
+	} {
+		a, b, at := placeAllTest(t)
+		placements := make([]Placement, len(b))
+		for k, i := range b {
+			placements[k] = Placement{Instance: i, At: at[k]}
+		}
+		want, got := newCanvas(), newCanvas()
+		for r := 0; r < 3; r++ {
+			for k, i := range a {
+				i.PlaceOn(want, at[k])
+			}
+			PlaceAll(got, placements)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%T: PlaceAll differs from PlaceOn", want)
+		}
+		for k := range a {
+			if a[k].CurrentFrameIndex() != b[k].CurrentFrameIndex() {
+				t.Fatalf("%T: Instance %d on frame %d after PlaceAll, want %d", want, k, b[k].CurrentFrameIndex(),
+					a[k].CurrentFrameIndex())
+			}
+		}
+	}
+}
+
+func BenchmarkPlaceAll(b *testing.B) {
+	_, instances, at := placeAllTest(b)
+	placements := make([]Placement, len(instances))
+	for k, i := range instances {
+		placements[k] = Placement{Instance: i, At: at[k]}
+	}
+	canvas := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		PlaceAll(canvas, placements)
+	}
+}
+
+// BenchmarkPlaceAllNaive is the equivalent of BenchmarkPlaceAll using PlaceOn, for comparison.
+func BenchmarkPlaceAllNaive(b *testing.B) {
+	instances, _, at := placeAllTest(b)
+	canvas := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for k, i := range instances {
+			i.PlaceOn(canvas, at[k])
+		}
+	}
+}
+
+func TestPlaceOnAnchored(t *testing.T) {
+	e := mustEntity(t, mustSheet(t), 0)
+	m := mustMode(t, e, 0)
+	m.SetAnchor(image.Pt(2, 3))
+	i := mustInstance(t, e, 0)
+	canvas := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	i.PlaceOnAnchored(canvas, image.Pt(10, 10))
+
+	// The 4x4 frame must cover exactly the rectangle at (10,10) - (2,3).
+	want := image.Rect(8, 7, 12, 11)
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			c := canvas.RGBAAt(x, y)
+			if in := image.Pt(x, y).In(want); in != (c == cellColor(0, 0)) || !in && c != (color.RGBA{}) {
+				t.Fatalf("pixel (%d,%d) is %v; the frame should cover only %v", x, y, c, want)
+			}
+		}
+	}
+}
+
+func TestComposeInstances(t *testing.T) {
+	// Entity 0 is an opaque blue base, and Entity 1 a red decal pixel at (1,1) on a transparent background, each with
+	// one Mode of 2 identical frames.
+	blue, red := color.RGBA{B: 255, A: 255}, color.RGBA{R: 255, A: 255}
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 4; x++ {
+			img.SetRGBA(x, y, blue)
+		}
+	}
+	img.SetRGBA(5, 1, red)
+	img.SetRGBA(5, 5, red)
+	s, err := NewSheet(img, SheetDimensions{EntitiesPerRow: 2, EntitiesPerColumn: 1, ModesPerEntity: 1,
+		FramesPerAnimation: 2, SpriteWidth: 4, SpriteHeight: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, decal := mustInstance(t, mustEntity(t, s, 0), 0), mustInstance(t, mustEntity(t, s, 1), 0)
+	base.StartAnimation()
+	decal.StartAnimation()
+
+	composite, err := ComposeInstancesNoAdvance([]*Instance{base, decal})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			want := blue
+			if x == 1 && y == 1 {
+				want = red
+			}
+			if c := composite.RGBAAt(x, y); c != want {
+				t.Fatalf("pixel (%d,%d) is %v, want %v", x, y, c, want)
+			}
+		}
+	}
+	if base.CurrentFrameIndex() != 0 || decal.CurrentFrameIndex() != 0 {
+		t.Fatal("ComposeInstancesNoAdvance advanced the animations")
+	}
+
+	// Drawn the other way around, the base covers the decal.
+	if composite, _ = ComposeInstances([]*Instance{decal, base}); composite.RGBAAt(1, 1) != blue {
+		t.Fatalf("decal under the base shows through as %v", composite.RGBAAt(1, 1))
+	}
+	if base.CurrentFrameIndex() != 1 || decal.CurrentFrameIndex() != 1 {
+		t.Fatal("ComposeInstances did not advance the animations")
+	}
+
+	other := mustInstance(t, singleSpriteEntity(t, filledImage(2, 2, red)), 0)
+	other.StartAnimation()
+	if _, err := ComposeInstances([]*Instance{base, other}); err == nil {
+		t.Fatal("Instances of different sprite sizes composed")
+	}
+	if base.CurrentFrameIndex() != 1 {
+		t.Fatal("failed ComposeInstances advanced the animations")
+	}
+	if _, err := ComposeInstances(nil); err == nil {
+		t.Fatal("no Instances composed")
+	}
+}
+
+func TestPlaceOnNonZeroOrigin(t *testing.T) {
+	opaque := mustEntity(t, mustSheet(t), 1)
+	translucent := singleSpriteEntity(t, filledImage(4, 4, color.NRGBA{R: 200, G: 100, A: 128}))
+	// Each canvas is a view of a 20x20 image whose Bounds().Min is not (0,0). The first, spanning the rest of the image,
+	// is used by the ccsl_graphics fast path; the others are not.
+	canvases := []struct {
+		name   string
+		canvas func(base *image.RGBA) draw.Image
+		fast   bool
+	}{
+		{"ccsl_graphics.Image", func(base *image.RGBA) draw.Image {
+			img, err := ccsl_graphics.NewImage(base.SubImage(image.Rect(0, 5, 20, 20)).(*image.RGBA))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return img
+		}, true},
+		{"inner ccsl_graphics.Image", func(base *image.RGBA) draw.Image {
+			img, err := ccsl_graphics.NewImage(base.SubImage(image.Rect(5, 5, 15, 15)).(*image.RGBA))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return img
+		}, false},
+		{"RGBA", func(base *image.RGBA) draw.Image {
+			return base.SubImage(image.Rect(5, 5, 15, 15)).(*image.RGBA)
+		}, false},
+	}
+	for _, entity := range []*Entity{opaque, translucent} {
+		// Placements inside the view, and partly outside it (which must be clipped).
+		for _, at := range []image.Point{image.Pt(7, 8), image.Pt(13, 13)} {
+			want := image.NewRGBA(image.Rect(0, 0, 20, 20))
+			mustInstance(t, entity, 0).PlaceOn(want.SubImage(image.Rect(5, 5, 15, 15)).(*image.RGBA), at)
+			for _, c := range canvases {
+				base := image.NewRGBA(image.Rect(0, 0, 20, 20))
+				canvas := c.canvas(base)
+				if (fastCanvas(canvas) != nil) != c.fast {
+					t.Fatalf("%s: fast path use %v, want %v", c.name, fastCanvas(canvas) != nil, c.fast)
+				}
+				mustInstance(t, entity, 0).PlaceOn(canvas, at)
+				// The fast canvas covers more of the image, so compare only the area all the canvases cover.
+				for y := 5; y < 15; y++ {
+					for x := 5; x < 15; x++ {
+						if base.RGBAAt(x, y) != want.RGBAAt(x, y) {
+							t.Fatalf("%s, opaque %v, placed at %v: pixel (%d,%d) is %v, want %v", c.name,
+								entity == opaque, at, x, y, base.RGBAAt(x, y), want.RGBAAt(x, y))
+						}
+					}
+				}
+				if c.fast {
+					continue
+				}
+				for y := 0; y < 20; y++ {
+					for x := 0; x < 20; x++ {
+						if !image.Pt(x, y).In(image.Rect(5, 5, 15, 15)) && base.RGBAAt(x, y).A != 0 {
+							t.Fatalf("%s: pixel (%d,%d) outside the canvas drawn", c.name, x, y)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestPlaceOnOnionSkin(t *testing.T) {
+	// Frame f is a single pixel of red 50 * (f + 1), except frame 1, which is transparent so the ghosts show through.
+	img := image.NewRGBA(image.Rect(0, 0, 4, 1))
+	for f := 0; f < 4; f++ {
+		img.SetRGBA(f, 0, color.RGBA{R: uint8(50 * (f + 1)), A: 255})
+	}
+	img.SetRGBA(1, 0, color.RGBA{})
+	s, err := NewSheet(img, SheetDimensions{EntitiesPerRow: 1, EntitiesPerColumn: 1, ModesPerEntity: 1,
+		FramesPerAnimation: 4, FramesRunRows: true, SpriteWidth: 1, SpriteHeight: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := mustInstance(t, mustEntity(t, s, 0), 0)
+	onionSkin := func(before, after int) color.RGBA {
+		canvas := image.NewRGBA(image.Rect(0, 0, 1, 1))
+		i.PlaceOnOnionSkin(canvas, image.Point{}, before, after, 128)
+		return canvas.RGBAAt(0, 0)
+	}
+
+	i.SetCurrentFrame(1)
+	// The ghost of frame 0 (red 50), then that of frame 2 (red 150) over it, each at half alpha.
+	if c := onionSkin(1, 1); c != (color.RGBA{R: 88, A: 192}) {
+		t.Errorf("ghosts of frames 0 and 2 are %v, want {88 0 0 192}", c)
+	}
+	// Preceding frames wrap: the ghost of frame 3 (red 200), then that of frame 0 over it.
+	if c := onionSkin(2, 0); c != (color.RGBA{R: 75, A: 192}) {
+		t.Errorf("ghosts of frames 3 and 0 are %v, want {75 0 0 192}", c)
+	}
+
+	// An opaque current frame is drawn last, hiding the ghosts.
+	i.SetCurrentFrame(0)
+	if c := onionSkin(1, 1); c != (color.RGBA{R: 50, A: 255}) {
+		t.Errorf("onion skin over frame 0 is %v, want the frame itself", c)
+	}
+	if i.CurrentFrameIndex() != 0 {
+		t.Error("PlaceOnOnionSkin advanced the animation")
+	}
+}
+
+func TestBounds(t *testing.T) {
+	// Bounds is exactly the rectangle PlaceOn draws an opaque frame to.
+	i := mustInstance(t, mustEntity(t, mustSheet(t), 3), 1)
+	canvas := image.NewRGBA(image.Rect(0, 0, 30, 30))
+	i.PlaceOn(canvas, image.Pt(10, 20))
+	drawn := image.Rectangle{}
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 30; x++ {
+			if canvas.RGBAAt(x, y).A != 0 {
+				drawn = drawn.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	if b := i.Bounds(image.Pt(10, 20)); b != drawn || b != image.Rect(10, 20, 14, 24) {
+		t.Fatalf("Bounds is %v, but PlaceOn drew to %v", b, drawn)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 6, 6))
+	img.SetRGBA(2, 1, color.RGBA{A: 255})
+	img.SetRGBA(3, 4, color.RGBA{A: 255})
+	i = mustInstance(t, singleSpriteEntity(t, img), 0)
+	if b := i.Bounds(image.Pt(10, 20)); b != image.Rect(10, 20, 16, 26) {
+		t.Errorf("Bounds is %v, want (10,20)-(16,26)", b)
+	}
+	if b := i.VisibleBounds(image.Pt(10, 20)); b != image.Rect(12, 21, 14, 25) {
+		t.Errorf("VisibleBounds is %v, want (12,21)-(14,25)", b)
+	}
+	i = mustInstance(t, singleSpriteEntity(t, image.NewRGBA(image.Rect(0, 0, 6, 6))), 0)
+	if b := i.VisibleBounds(image.Pt(10, 20)); !b.Empty() || b.Min != image.Pt(10, 20) {
+		t.Errorf("VisibleBounds of a transparent frame is %v, want empty at (10,20)", b)
+	}
+}
+
+func TestOnModeChange(t *testing.T) {
+	e := mustEntity(t, mustSheet(t), 0)
+	names := e.ModeNames()
+	i := mustInstance(t, e, 0)
+	var got [][2]string
+	i.SetOnModeChange(func(oldMode, newMode string) {
+		// The Instance is unlocked, so may be used from the callback.
+		_ = i.CurrentFrameIndex()
+		got = append(got, [2]string{oldMode, newMode})
+	})
+	i.SetModeByIndex(1)
+	i.SetModeByIndex(1)
+	i.SetModeByName(names[2])
+	i.SetModeByName(names[2])
+	if err := i.QueueModes(names[2], names[0]); err != nil {
+		t.Fatal(err)
+	}
+	i.StartAnimation()
+	for n := 0; n < 8; n++ {
+		i.Frame()
+	}
+	want := [][2]string{{names[0], names[1]}, {names[1], names[2]}, {names[2], names[0]}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("mode changes are %v, want %v", got, want)
+	}
+
+	i.SetOnModeChange(nil)
+	i.SetModeByIndex(1)
+	if len(got) != len(want) {
+		t.Error("removed OnModeChange callback was called")
+	}
+}
+
+func TestPlaceOnScaled(t *testing.T) {
+	e := mustEntity(t, mustSheet(t), 1)
+	// At a scale of 1, the same as PlaceOn.
+	canvases := [2]*image.RGBA{image.NewRGBA(image.Rect(0, 0, 20, 20)), image.NewRGBA(image.Rect(0, 0, 20, 20))}
+	mustInstance(t, e, 2).PlaceOn(canvases[0], image.Pt(2, 3))
+	mustInstance(t, e, 2).PlaceOnScaled(canvases[1], image.Pt(2, 3), 1, 1)
+	if !reflect.DeepEqual(canvases[0].Pix, canvases[1].Pix) {
+		t.Fatal("PlaceOnScaled at 1x differs from PlaceOn")
+	}
+
+	// Each pixel of this frame differs, so a 2x upscale must repeat each exactly. A fractional scale rounds the size.
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			img.SetRGBA(x, y, cellColor(x, y))
+		}
+	}
+	i := mustInstance(t, singleSpriteEntity(t, img), 0)
+	for _, c := range []struct {
+		scaleX, scaleY float64
+		size           image.Point
+	}{{2, 2, image.Pt(8, 8)}, {1.4, 2.6, image.Pt(6, 10)}} {
+		canvas := image.NewRGBA(image.Rect(0, 0, 20, 20))
+		i.PlaceOnScaled(canvas, image.Pt(1, 1), c.scaleX, c.scaleY)
+		drawn := image.Rectangle{}
+		for y := 0; y < 20; y++ {
+			for x := 0; x < 20; x++ {
+				if canvas.RGBAAt(x, y).A != 0 {
+					drawn = drawn.Union(image.Rect(x, y, x+1, y+1))
+				}
+			}
+		}
+		if want := (image.Rectangle{Max: c.size}).Add(image.Pt(1, 1)); drawn != want {
+			t.Fatalf("%gx%g: drew to %v, want %v", c.scaleX, c.scaleY, drawn, want)
+		}
+		if c.scaleX != 2 {
+			continue
+		}
+		for y := 0; y < 8; y++ {
+			for x := 0; x < 8; x++ {
+				if got, want := canvas.RGBAAt(1+x, 1+y), cellColor(x/2, y/2); got != want {
+					t.Fatalf("2x: pixel (%d,%d) is %v, want %v", x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestPlaceOnClipped(t *testing.T) {
+	// Each pixel of the frame differs, so misplaced source points are caught. One is semi-transparent, so the frame is
+	// drawn with draw.Over.
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			img.SetRGBA(x, y, cellColor(x, y))
+		}
+	}
+	img.SetRGBA(3, 3, color.RGBA{R: 50, A: 128})
+	background := color.RGBA{B: 255, A: 255}
+	for _, e := range []*Entity{mustEntity(t, mustSheet(t), 1), singleSpriteEntity(t, img)} {
+		full := filledImage(20, 20, background)
+		mustInstance(t, e, 0).PlaceOn(full, image.Pt(3, 5))
+		for _, clip := range []image.Rectangle{
+			image.Rect(5, 0, 20, 20),   // Straddling the clip's left edge.
+			image.Rect(0, 0, 6, 7),     // And its bottom-right.
+			image.Rect(0, 0, 20, 20),   // Not clipped.
+			image.Rect(15, 15, 20, 20), // Entirely clipped.
+		} {
+			i := mustInstance(t, e, 0)
+			i.StartAnimation()
+			canvas := filledImage(20, 20, background)
+			i.PlaceOnClipped(canvas, image.Pt(3, 5), clip)
+			for y := 0; y < 20; y++ {
+				for x := 0; x < 20; x++ {
+					want := background
+					if image.Pt(x, y).In(clip) {
+						want = full.RGBAAt(x, y)
+					}
+					if got := canvas.RGBAAt(x, y); got != want {
+						t.Fatalf("clip %v: pixel (%d,%d) is %v, want %v", clip, x, y, got, want)
+					}
+				}
+			}
+			if i.CurrentFrameIndex() != 1%i.FrameCount() {
+				t.Fatalf("clip %v: animation not advanced", clip)
+			}
+		}
+	}
+}
+
+func TestSpeedScalePerInstance(t *testing.T) {
+	e := mustEntity(t, mustSheet(t), 0)
+	fast, slow := mustInstance(t, e, 0), mustInstance(t, e, 0)
+	fast.StartAnimation()
+	slow.StartAnimation()
+	fast.SetSpeedScale(2)
+	slow.SetSpeedScale(0.5)
+	fast.Advance()
+	slow.Advance()
+	if fast.CurrentFrameIndex() != 2 || slow.CurrentFrameIndex() != 0 {
+		t.Fatalf("after one advance at 2x and 0.5x, on frames %d and %d, want 2 and 0", fast.CurrentFrameIndex(), slow.CurrentFrameIndex())
+	}
+	// The scale belongs to the Instance, so survives Mode changes.
+	names := e.ModeNames()
+	if err := fast.SetModeByName(names[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := slow.SetModeByName(names[2]); err != nil {
+		t.Fatal(err)
+	}
+	if fast.SpeedScale() != 2 || slow.SpeedScale() != 0.5 {
+		t.Fatalf("after changing Modes, speed scales are %g and %g, want 2 and 0.5", fast.SpeedScale(), slow.SpeedScale())
+	}
+	if mustInstance(t, e, 1).SpeedScale() != 1 {
+		t.Fatal("a new Instance does not have a speed scale of 1")
+	}
+}
+
+func TestDetach(t *testing.T) {
+	s := mustSheet(t)
+	e := mustEntity(t, s, 0)
+	names := e.ModeNames()
+	i := mustInstance(t, e, 1)
+	i.StartAnimation()
+	i.SetSpeedScale(2)
+	detached := i.Detach()
+	if detached.Entity == i.Entity || detached.Mode == i.Mode || detached.Mode.Name() != names[1] || !detached.Running() ||
+		detached.SpeedScale() != 2 {
+		t.Fatal("Detach did not copy the Entity and Mode, with the same Mode and playback state")
+	}
+
+	// Editing the detached Instance's frames and Modes leaves the original's unchanged.
+	original, _ := mustMode(t, e, 1).FrameCopy(0)
+	frame, _ := detached.Mode.GetFrame(0)
+	draw.Draw(frame.(*image.RGBA), frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+	if err := detached.Mode.RemoveFrame(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := detached.Entity.RenameMode(names[2], "renamed"); err != nil {
+		t.Fatal(err)
+	}
+	sheetFrame, _ := mustMode(t, e, 1).GetFrame(0)
+	if equal, _, _, _ := SpriteDiff(sheetFrame, original); !equal {
+		t.Fatal("editing a detached frame changed the Sheet's")
+	}
+	if mustMode(t, e, 1).FrameCount() != 4 || !reflect.DeepEqual(e.ModeNames(), names) || i.Frame() == nil {
+		t.Fatal("editing the detached Modes changed the Sheet's")
+	}
+
+	// Mode changes use the detached Entity's Modes.
+	if err := detached.SetModeByIndex(2); err != nil || detached.Mode == mustMode(t, e, 2) || detached.Mode.Name() != "renamed" {
+		t.Fatal("detached Instance changed to a Mode of the original Entity")
+	}
+}
+
+func TestSetModeByIndexClamped(t *testing.T) {
+	e := mustEntity(t, mustSheet(t), 0)
+	names := e.ModeNames()
+	i := mustInstance(t, e, 1)
+	for _, c := range []struct{ index, want int }{{-3, 0}, {9, 2}, {1, 1}, {3, 2}, {-1, 0}} {
+		if got := i.SetModeByIndexClamped(c.index); got != c.want || i.Mode.Name() != names[c.want] {
+			t.Fatalf("SetModeByIndexClamped(%d) = %d, in Mode %q; want %d, in Mode %q", c.index, got, i.Mode.Name(),
+				c.want, names[c.want])
+		}
+	}
+	if err := i.SetModeByIndex(9); err == nil {
+		t.Fatal("SetModeByIndex did not reject an out of range index")
+	}
+
+	// Without Modes, there is no index to clamp to.
+	mode := i.Mode
+	e.modes, e.modeNamesToIndex = map[int]*Mode{}, map[string]int{}
+	if got := i.SetModeByIndexClamped(1); got != -1 || i.Mode != mode {
+		t.Fatalf("SetModeByIndexClamped without Modes = %d, in Mode %q; want -1, in Mode %q", got, i.Mode.Name(),
+			mode.Name())
+	}
+}
+
+func TestPlaceOnTiled(t *testing.T) {
+	sprite := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			sprite.SetRGBA(x, y, color.RGBA{uint8(x * 60), uint8(y * 60), 7, 255})
+		}
+	}
+	i := mustInstance(t, singleSpriteEntity(t, sprite), 0)
+	region := image.Rect(3, 2, 14, 17)
+	for _, c := range []struct {
+		name   string
+		canvas func(base *image.RGBA) draw.Image
+	}{
+		{"RGBA", func(base *image.RGBA) draw.Image { return base }},
+		{"ccsl_graphics.Image", func(base *image.RGBA) draw.Image {
+			img, err := ccsl_graphics.NewImage(base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return img
+		}},
+	} {
+		for _, offset := range []image.Point{{0, 0}, {1, 2}, {-5, 7}, {13, -1}} {
+			base := image.NewRGBA(image.Rect(0, 0, 20, 20))
+			i.PlaceOnTiled(c.canvas(base), region, offset)
+			// Every pixel of region is covered, by the tile grid anchored at region.Min + offset, and nothing else is.
+			for y := 0; y < 20; y++ {
+				for x := 0; x < 20; x++ {
+					want := color.RGBA{}
+					if (image.Point{X: x, Y: y}).In(region) {
+						want = sprite.RGBAAt(wrapIndex(x-region.Min.X-offset.X, 4), wrapIndex(y-region.Min.Y-offset.Y, 4))
+					}
+					if got := base.RGBAAt(x, y); got != want {
+						t.Fatalf("%s, offset %v: pixel (%d, %d) = %v, want %v", c.name, offset, x, y, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestPlaceOnNoAdvance(t *testing.T) {
+	i := mustInstance(t, mustEntity(t, mustSheet(t), 0), 0)
+	i.StartAnimation()
+	want, _ := i.Mode.GetFrame(0)
+	main, minimap := image.NewRGBA(image.Rect(0, 0, 8, 8)), image.NewRGBA(image.Rect(0, 0, 8, 8))
+	i.PlaceOnNoAdvance(main, image.Point{})
+	i.PlaceOnNoAdvance(minimap, image.Point{})
+	for _, canvas := range []*image.RGBA{main, minimap} {
+		if equal, _, _, _ := SpriteDiff(canvas.SubImage(image.Rect(0, 0, 4, 4)), want); !equal {
+			t.Fatal("PlaceOnNoAdvance did not place the current frame")
+		}
+	}
+	i.Advance()
+	if got := i.CurrentFrameIndex(); got != 1 {
+		t.Fatalf("frame %d after drawing twice and advancing once, want 1", got)
+	}
+}
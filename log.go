@@ -0,0 +1,16 @@
+package sprites
+
+// Logger, if set, is called (with a message in the manner of fmt.Printf) whenever the package silently corrects
+// something which may indicate a bug in the calling code, for example when an animation's current frame is out of
+// range of its Mode's frames (after the Mode's frame count was reduced, or the Instance switched to a Mode with fewer
+// frames) and is wrapped back into range, when SetEntityCount drops Entities, or when a panic while hashing is
+// recovered into an error. It does not change the package's behavior. It is nil (no logging) by default. It may be
+// called from any goroutine, and should be set before using the package.
+var Logger func(format string, args ...interface{})
+
+// logf calls Logger, if it is set.
+func logf(format string, args ...interface{}) {
+	if Logger != nil {
+		Logger(format, args...)
+	}
+}
@@ -0,0 +1,63 @@
+package sprites
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	s := mustSheet(t)
+	e := mustEntity(t, s, 0)
+	// shrink returns an Instance whose current frame is out of range, after its Mode's frame count was reduced.
+	shrink := func(mode int) *Instance {
+		i := mustInstance(t, e, mode)
+		i.SetCurrentFrame(3)
+		if err := i.Mode.SetFrameCount(2); err != nil {
+			t.Fatal(err)
+		}
+		return i
+	}
+	// Unset, nothing is logged (and nothing panics).
+	shrink(0).Frame()
+
+	var messages []string
+	Logger = func(format string, args ...interface{}) {
+		messages = append(messages, fmt.Sprintf(format, args...))
+	}
+	defer func() { Logger = nil }()
+
+	// An in-range current frame is not a correction.
+	mustInstance(t, e, 2).Frame()
+	if len(messages) != 0 {
+		t.Fatalf("logged %q for an in-range frame", messages)
+	}
+	shrink(1).Frame()
+	if len(messages) != 1 || !strings.Contains(messages[0], "out of range") {
+		t.Fatalf("logged %q for wrapping an out-of-range frame, want one message", messages)
+	}
+
+	names := s.EntityNames()
+	if err := s.SetEntityCount(2); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || !strings.Contains(messages[1], names[2]) || !strings.Contains(messages[1], names[3]) {
+		t.Fatalf("logged %q for SetEntityCount, want a message naming the dropped Entities", messages)
+	}
+
+	// A Mode without frames is logged once, however many placeholder frames are used, rather than as out of range.
+	messages = nil
+	i := mustInstance(t, e, 2)
+	if err := i.SetCurrentFrame(2); err != nil {
+		t.Fatal(err)
+	}
+	i.StartAnimation()
+	i.Mode.frames = nil
+	i.Mode.framesChanged()
+	if first := i.Frame(); i.Frame() != first {
+		t.Error("a new placeholder frame was made for each Frame")
+	}
+	if len(messages) != 1 || !strings.Contains(messages[0], "no frames") {
+		t.Fatalf("logged %q for Frame of a Mode without frames, want one message", messages)
+	}
+}
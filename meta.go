@@ -0,0 +1,44 @@
+package sprites
+
+// Meta returns the metadata value stored under key, and whether there is one. See SetMeta.
+func (e *Entity) Meta(key string) (string, bool) {
+	val, ok := e.meta[key]
+	return val, ok
+}
+
+// SetMeta stores val as the metadata value under key, replacing any existing value. Metadata (e.g. a collision layer)
+// is kept with the Entity, including when it is renamed or its Sheet is cloned, but is otherwise ignored by the package.
+func (e *Entity) SetMeta(key, val string) {
+	if e.meta == nil {
+		e.meta = make(map[string]string)
+	}
+	e.meta[key] = val
+}
+
+// Meta returns the metadata value stored under key, and whether there is one. See SetMeta.
+func (m *Mode) Meta(key string) (string, bool) {
+	val, ok := m.meta[key]
+	return val, ok
+}
+
+// SetMeta stores val as the metadata value under key, replacing any existing value. Metadata (e.g. a sound effect key)
+// is kept with the Mode, including when it is renamed, cloned or transformed (e.g. by Grayscale), but is otherwise
+// ignored by the package.
+func (m *Mode) SetMeta(key, val string) {
+	if m.meta == nil {
+		m.meta = make(map[string]string)
+	}
+	m.meta[key] = val
+}
+
+// copyMeta returns a copy of meta, or nil if it is empty.
+func copyMeta(meta map[string]string) map[string]string {
+	if len(meta) == 0 {
+		return nil
+	}
+	c := make(map[string]string, len(meta))
+	for key, val := range meta {
+		c[key] = val
+	}
+	return c
+}
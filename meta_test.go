@@ -0,0 +1,54 @@
+package sprites
+
+import "testing"
+
+func TestMeta(t *testing.T) {
+	s := mustSheet(t)
+	e := mustEntity(t, s, 0)
+	m := mustMode(t, e, 0)
+	if _, ok := e.Meta("layer"); ok {
+		t.Fatal("unset Entity metadata found")
+	}
+	e.SetMeta("layer", "solid")
+	m.SetMeta("sound", "step")
+	if err := s.RenameEntity(e.Name(), "hero"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.RenameMode(m.Name(), "walk"); err != nil {
+		t.Fatal(err)
+	}
+	if val, ok := e.Meta("layer"); val != "solid" || !ok {
+		t.Fatalf("Entity metadata after RenameEntity is %q, %v", val, ok)
+	}
+	if val, ok := m.Meta("sound"); val != "step" || !ok {
+		t.Fatalf("Mode metadata after RenameMode is %q, %v", val, ok)
+	}
+
+	c := s.Clone()
+	ce, err := c.GetEntityByName("hero")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm := mustMode(t, ce, 0)
+	if val, _ := ce.Meta("layer"); val != "solid" {
+		t.Fatalf("cloned Entity metadata is %q, want solid", val)
+	}
+	if val, _ := cm.Meta("sound"); val != "step" {
+		t.Fatalf("cloned Mode metadata is %q, want step", val)
+	}
+	ce.SetMeta("layer", "ghost")
+	cm.SetMeta("sound", "float")
+	if val, _ := e.Meta("layer"); val != "solid" {
+		t.Fatal("setting the clone's Entity metadata changed the original")
+	}
+	if val, _ := m.Meta("sound"); val != "step" {
+		t.Fatal("setting the clone's Mode metadata changed the original")
+	}
+
+	if val, _ := m.Grayscale().Meta("sound"); val != "step" {
+		t.Fatal("Grayscale did not keep the Mode metadata")
+	}
+	if val, _ := mustInstance(t, e, 0).Meta("layer"); val != "solid" {
+		t.Fatal("Instance does not see its Entity's metadata")
+	}
+}
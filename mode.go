@@ -4,10 +4,16 @@ import (
 	"errors"
 	"fmt"
 	"image"
+	"image/draw"
+	"sync"
+	"time"
 
-	"github.com/corona10/goimagehash"
+	ccsl_graphics "github.com/HaileyStorm/CCSL_go/graphics"
 )
 
+// Sprite is a single frame image. The frames the package creates (and the images derived from them by its transforms,
+// resizing, blending, etc.) are *image.RGBA, whose pixels are alpha-premultiplied: no color channel of a pixel exceeds
+// its alpha. Each operation works in, and preserves, that form, so chaining them never premultiplies twice.
 type Sprite image.Image
 
 type Mode struct {
@@ -15,8 +21,95 @@ type Mode struct {
 
 	spriteSize  image.Rectangle
 	fullyOpaque bool
+	// lazy is non-nil if frames and fullyOpaque have not been built yet, which is deferred for Modes loaded with
+	// SheetDimensions.Lazy. See loadFrames.
+	lazy *lazyFrames
+	// resizeFilter is the filter used by FrameResized. It is set from SheetDimensions.ResizeFilter.
+	resizeFilter ResizeFilter
 
 	frames []Sprite
+
+	// anchor is the point within a sprite, relative to its top-left, that is positioned by Instance.PlaceOnAnchored.
+	anchor image.Point
+
+	// meta holds the Mode's metadata. See SetMeta.
+	meta map[string]string
+
+	// frameNames holds the names of named frames, by index. See SetFrameName.
+	frameNames map[int]string
+	// derived indicates the Mode was created from the Mode named derivedFrom by the transform derivedKind. See
+	// DerivedFrom.
+	derived     bool
+	derivedFrom string
+	derivedKind string
+
+	// frameDurations holds the duration of each frame, if known (e.g. from NewSheetFromAseprite). See FrameDuration.
+	frameDurations []time.Duration
+
+	// hasActiveWindow indicates whether activeStart and activeEnd have been set by SetActiveWindow.
+	hasActiveWindow bool
+	// activeStart and activeEnd are the (inclusive) frame indexes of the Mode's active window.
+	activeStart int
+	activeEnd   int
+
+	// cache holds data derived from frames. It is shared by all Instances using the Mode, and is invalidated whenever
+	// frames change.
+	cache *frameCache
+}
+
+// frameCache holds data derived from a Mode's frames, computed on demand. It is safe for concurrent use.
+type frameCache struct {
+	mu sync.Mutex
+	// resized holds resized copies of frames, as requested via FrameResized.
+	resized map[resizeKey]Sprite
+	// masks holds collision masks of frames, as requested via CollisionMask.
+	masks map[maskKey]*image.Alpha
+	// visible holds counts of frames' visible pixels, as requested via VisiblePixelCount.
+	visible map[maskKey]int
+	// visibleBounds holds the bounds of frames' visible pixels, relative to the frames' top-left, as requested via
+	// Instance.VisibleBounds.
+	visibleBounds map[int]image.Rectangle
+	// hashes holds the hash of each frame, as requested via Sheet.ComputeFrameHashes.
+	hashes []string
+
+	// placeholderMu guards placeholder. It is separate from mu, as frames (and so the placeholder) are read while mu
+	// is held.
+	placeholderMu sync.Mutex
+	// placeholder is the frame used in place of the frames of a Mode with none. See frameAt.
+	placeholder *image.RGBA
+}
+
+// lazyFrames describes the frames of a Mode loaded with SheetDimensions.Lazy, which are extracted from the sheet image
+// the first time they are needed.
+type lazyFrames struct {
+	once sync.Once
+	// img is the sheet image, and rects the bounds within it of each frame, in order.
+	img   ccsl_graphics.SubImager
+	rects []image.Rectangle
+	// normalize indicates each frame is copied (see SheetDimensions.NormalizeOrigins).
+	normalize bool
+}
+
+// maskKey identifies a collision mask in a frameCache.
+type maskKey struct {
+	index     int
+	threshold uint8
+}
+
+// resizeKey identifies a resized frame in a frameCache.
+type resizeKey struct {
+	index  int
+	w, h   uint
+	filter ResizeFilter
+}
+
+// newMode creates an empty Mode with the given name and sprite size.
+func newMode(name string, spriteSize image.Rectangle) *Mode {
+	return &Mode{
+		name:       name,
+		spriteSize: spriteSize,
+		cache:      &frameCache{},
+	}
 }
 
 func (m *Mode) Name() string {
@@ -29,48 +122,547 @@ func (m *Mode) SpriteSize() image.Rectangle {
 }
 
 func (m *Mode) FullyOpaque() bool {
+	return m.isFullyOpaque()
+}
+
+// isFullyOpaque returns fullyOpaque, first computing it if that was deferred (see SheetDimensions.Lazy). It must be used
+// rather than reading fullyOpaque directly.
+func (m *Mode) isFullyOpaque() bool {
+	m.loadFrames()
 	return m.fullyOpaque
 }
 
+// loadFrames extracts the Mode's frames from the sheet image and computes fullyOpaque, if that was deferred (see
+// SheetDimensions.Lazy). It is safe for concurrent use, and must be called before frames is read.
+func (m *Mode) loadFrames() {
+	l := m.lazy
+	if l == nil {
+		return
+	}
+	l.once.Do(func() {
+		m.frames = make([]Sprite, len(l.rects))
+		for f, rect := range l.rects {
+			m.frames[f] = extractFrame(l.img, rect, l.normalize)
+		}
+		m.updateFullyOpaque()
+	})
+}
+
+// Anchor returns the Mode's anchor point (or hotspot). See SetAnchor.
+func (m *Mode) Anchor() image.Point {
+	return m.anchor
+}
+
+// SetAnchor sets the Mode's anchor point (or hotspot): the point within each sprite, relative to its top-left, which
+// Instance.PlaceOnAnchored positions on the canvas - for example a character's feet or the center of a projectile. The
+// default anchor is (0,0), the top-left. The anchor need not be within the SpriteSize().
+func (m *Mode) SetAnchor(anchor image.Point) {
+	m.anchor = anchor
+}
+
 //note that unlike Instance.Frame() this does not advance the current frame (there is no current frame in Mode - this is an Instance concept)
+// The returned Sprite is typically a view sharing its pixels with the Sheet's image, and must not be modified; see
+// FrameCopy.
 func (m *Mode) GetFrame(index int) (Sprite, error) {
-	if index < len(m.frames) {
-		return m.frames[index], nil
-	} else {
-		return nil, errors.New("index out of bounds")
+	m.loadFrames()
+	if index < 0 || index >= len(m.frames) {
+		return nil, fmt.Errorf("frame with index %d does not exist in Mode %s (which has %d frames)", index, m.name, len(m.frames))
+	}
+	return m.frames[index], nil
+}
+
+// frameAt returns the frame at index, which must be in range, unless the Mode has no frames, in which case it returns a
+// fully transparent placeholder of the Mode's sprite size. The frame editors do not allow a Mode to be left with no
+// frames, but this keeps animations and placement from panicking should one be.
+func (m *Mode) frameAt(index int) Sprite {
+	m.loadFrames()
+	if len(m.frames) == 0 {
+		return m.placeholder()
+	}
+	return m.frames[index]
+}
+
+// placeholder returns the placeholder frame frameAt uses for a Mode with no frames. It is created, and the missing
+// frames logged, only once (until the frames change); it is shared, and must not be modified.
+func (m *Mode) placeholder() Sprite {
+	m.cache.placeholderMu.Lock()
+	defer m.cache.placeholderMu.Unlock()
+	if m.cache.placeholder == nil {
+		logf("sprites: Mode %s has no frames; using a transparent placeholder", m.name)
+		m.cache.placeholder = image.NewRGBA(image.Rectangle{Max: m.spriteSize.Size()})
+	}
+	return m.cache.placeholder
+}
+
+// Frames returns the Mode's frames, in order. The returned slice is a copy, so may be modified (e.g. appended to or
+// reordered) without affecting the Mode, but the frames themselves are shared with the Mode (and, typically, the
+// Sheet's image) and must not be modified; see FrameCopy.
+func (m *Mode) Frames() []Sprite {
+	m.loadFrames()
+	frames := make([]Sprite, len(m.frames))
+	copy(frames, m.frames)
+	return frames
+}
+
+// FrameCopy returns a newly allocated copy of the frame at index, with Bounds().Min at (0,0), which the caller may
+// modify or hand to other code freely.
+func (m *Mode) FrameCopy(index int) (*image.RGBA, error) {
+	m.loadFrames()
+	if index < 0 || index >= len(m.frames) {
+		return nil, fmt.Errorf("frame index (%d) must be >= 0 and < the frame count (%d)", index, len(m.frames))
 	}
+	return copyFrame(m.frames[index]), nil
+}
+
+// GetFrameWrapped is like GetFrame, but index wraps around (in either direction) rather than being out of bounds: for
+// example, -1 returns the last frame, and FrameCount() the first. Like GetFrame, it does not advance any animation.
+func (m *Mode) GetFrameWrapped(index int) Sprite {
+	return m.frameAt(wrapIndex(index, m.FrameCount()))
 }
 
 func (m *Mode) FrameCount() int {
+	if m.lazy != nil {
+		// The frames are not changed until they have been loaded, so they need not be loaded to count them.
+		return len(m.lazy.rects)
+	}
 	return len(m.frames)
 }
 
 //only decrease
 func (m *Mode) SetFrameCount(count int) error {
+	m.loadFrames()
 	if count > 0 && count <= len(m.frames) {
 		m.frames = m.frames[0:count]
+		if m.frameDurations != nil {
+			m.frameDurations = m.frameDurations[0:count]
+		}
+		for index := range m.frameNames {
+			if index >= count {
+				delete(m.frameNames, index)
+			}
+		}
+		if m.hasActiveWindow {
+			if m.activeStart >= count {
+				m.ClearActiveWindow()
+			} else if m.activeEnd >= count {
+				m.activeEnd = count - 1
+			}
+		}
+		m.framesChanged()
 		return nil
 	} else {
 		return fmt.Errorf("new frame count (%d) must be <= the current frame count (%d) and > 0", count, len(m.frames))
 	}
 }
 
-// SpriteHash gets a string hash representation of sprite, using the average hash algorithm.
-//
-// License(s) - see internal\licenses:
-// goimagehash
-func SpriteHash(sprite Sprite) string {
-	var hashstr string
-	defer func() {
-		if r := recover(); r != nil {
-			hashstr = "hash index out of bounds error"
+// InsertFrame inserts s into the Mode's frames at index, shifting the frame at index (if any) and all following frames
+// up by one. index may be == FrameCount(), in which case s is appended.
+// s must be the same size as the Mode's SpriteSize(). If s is not an *image.RGBA, it is copied into one.
+// Instances using this Mode are not adjusted; their current frame index continues to refer to the same position.
+func (m *Mode) InsertFrame(index int, s Sprite) error {
+	m.loadFrames()
+	if index < 0 || index > len(m.frames) {
+		return fmt.Errorf("insert index (%d) must be >= 0 and <= the current frame count (%d)", index, len(m.frames))
+	}
+	if s == nil {
+		return errors.New("sprite to insert is nil")
+	}
+	if s.Bounds().Size() != m.spriteSize.Size() {
+		return &ErrFrameSizeMismatch{Got: s.Bounds(), Want: m.spriteSize}
+	}
+
+	m.frames = append(m.frames, nil)
+	copy(m.frames[index+1:], m.frames[index:])
+	m.frames[index] = toRGBA(s)
+	if m.frameDurations != nil {
+		m.frameDurations = append(m.frameDurations, 0)
+		copy(m.frameDurations[index+1:], m.frameDurations[index:])
+		m.frameDurations[index] = 0
+	}
+	m.frameNames = shiftFrameNames(m.frameNames, index, 1)
+	if m.hasActiveWindow && index <= m.activeEnd {
+		// A frame inserted within the window (after its start) becomes part of it.
+		if index <= m.activeStart {
+			m.activeStart++
 		}
-	}()
-	hash, e := goimagehash.AverageHash(sprite)
-	if e != nil {
-		hashstr = e.Error()
-	} else {
-		hashstr = hash.ToString()
+		m.activeEnd++
+	}
+	m.framesChanged()
+	return nil
+}
+
+// RemoveFrame removes the frame at index, shifting all following frames down by one. A Mode must always have at
+// least one frame, so removing the last remaining frame is an error.
+// Instances whose current frame index is now past the end will wrap back into range on their next call to Frame().
+func (m *Mode) RemoveFrame(index int) error {
+	m.loadFrames()
+	if index < 0 || index >= len(m.frames) {
+		return fmt.Errorf("remove index (%d) must be >= 0 and < the current frame count (%d)", index, len(m.frames))
+	}
+	if len(m.frames) == 1 {
+		return errors.New("cannot remove the only frame in Mode")
+	}
+
+	m.frames = append(m.frames[:index], m.frames[index+1:]...)
+	if m.frameDurations != nil {
+		m.frameDurations = append(m.frameDurations[:index], m.frameDurations[index+1:]...)
+	}
+	delete(m.frameNames, index)
+	m.frameNames = shiftFrameNames(m.frameNames, index+1, -1)
+	if m.hasActiveWindow && index <= m.activeEnd {
+		if index < m.activeStart {
+			m.activeStart--
+		}
+		m.activeEnd--
+		if m.activeEnd < m.activeStart {
+			// The window's only frame was removed.
+			m.ClearActiveWindow()
+		}
+	}
+	m.framesChanged()
+	return nil
+}
+
+// SetFrameName names the frame at index (e.g. "contact" for the frame in which an attack connects), so it can be found
+// with FrameIndexByName. Frame names are optional, and must be unique within the Mode; naming a frame replaces any
+// previous name it had, and an empty name removes it. Names follow their frames when frames are inserted or removed,
+// and are removed along with their frames.
+func (m *Mode) SetFrameName(index int, name string) error {
+	if index < 0 || index >= m.FrameCount() {
+		return fmt.Errorf("frame index (%d) must be >= 0 and < the frame count (%d)", index, m.FrameCount())
+	}
+	if name == "" {
+		delete(m.frameNames, index)
+		return nil
+	}
+	if other, err := m.FrameIndexByName(name); err == nil && other != index {
+		return fmt.Errorf("frame with name %s already exists in Mode (at index %d)", name, other)
+	}
+	if m.frameNames == nil {
+		m.frameNames = make(map[int]string)
+	}
+	m.frameNames[index] = name
+	return nil
+}
+
+// FrameName returns the name of the frame at index, or "" if it has none. See SetFrameName.
+func (m *Mode) FrameName(index int) string {
+	return m.frameNames[index]
+}
+
+// FrameIndexByName returns the index of the frame with the given name. See SetFrameName.
+func (m *Mode) FrameIndexByName(name string) (int, error) {
+	for index, frameName := range m.frameNames {
+		if frameName == name {
+			return index, nil
+		}
+	}
+	return 0, fmt.Errorf("frame with name %s does not exist in Mode", name)
+}
+
+// shiftFrameNames returns a copy of frameNames with delta added to each index >= from, or nil if it is empty.
+func shiftFrameNames(frameNames map[int]string, from, delta int) map[int]string {
+	if len(frameNames) == 0 {
+		return nil
+	}
+	shifted := make(map[int]string, len(frameNames))
+	for index, name := range frameNames {
+		if index >= from {
+			index += delta
+		}
+		shifted[index] = name
+	}
+	return shifted
+}
+
+// FrameDuration returns the duration of the frame at index, as given when the Mode was imported (e.g. by
+// NewSheetFromAseprite), or 0 if the duration is unknown (including for frames inserted since). Animation playback
+// advances per call to Frame or Advance and does not use frame durations; they are provided for callers' timing.
+func (m *Mode) FrameDuration(index int) time.Duration {
+	if index < 0 || index >= len(m.frameDurations) {
+		return 0
+	}
+	return m.frameDurations[index]
+}
+
+// SetActiveWindow marks the frames from startFrame to endFrame (inclusive) as the Mode's active window, for example
+// the frames of an attack animation during which the attack is able to hit. A Mode has at most one active window;
+// calling this again replaces it. The window follows its frames when frames are inserted or removed (growing to include
+// frames inserted within it, and shrinking as its frames are removed, or SetFrameCount removes them), and is cleared
+// if all of its frames are removed.
+func (m *Mode) SetActiveWindow(startFrame, endFrame int) error {
+	if startFrame < 0 || endFrame >= m.FrameCount() || startFrame > endFrame {
+		return fmt.Errorf("active window [%d,%d] must satisfy 0 <= start <= end < frame count (%d)",
+			startFrame, endFrame, m.FrameCount())
+	}
+	m.hasActiveWindow = true
+	m.activeStart = startFrame
+	m.activeEnd = endFrame
+	return nil
+}
+
+// ActiveWindow returns the Mode's active window frame range (inclusive). ok is false if no window has been set.
+func (m *Mode) ActiveWindow() (startFrame, endFrame int, ok bool) {
+	return m.activeStart, m.activeEnd, m.hasActiveWindow
+}
+
+// ClearActiveWindow removes the Mode's active window, if any.
+func (m *Mode) ClearActiveWindow() {
+	m.hasActiveWindow = false
+	m.activeStart = 0
+	m.activeEnd = 0
+}
+
+// FrameInActiveWindow returns whether the frame at index is within the Mode's active window. It is always false if no
+// window has been set.
+func (m *Mode) FrameInActiveWindow(index int) bool {
+	return m.hasActiveWindow && index >= m.activeStart && index <= m.activeEnd
+}
+
+// CropToContent trims the Mode's frames to the smallest rectangle containing the visible (not fully transparent) pixels
+// of every frame, replacing each frame with a cropped copy and reducing SpriteSize() accordingly. Note the Mode's
+// sprite size may then differ from that of the Entity's other Modes (see Entity.SpriteSize). It returns the crop
+// rectangle, relative to the top-left of the original frames, so that callers positioning frames by their top-left can
+// offset them by its Min to keep the visible pixels in place. The anchor (see SetAnchor) is adjusted likewise, so
+// PlaceOnAnchored is unaffected.
+// If every frame is fully transparent, the Mode is left unchanged and the zero Rectangle is returned.
+func (m *Mode) CropToContent() image.Rectangle {
+	m.loadFrames()
+	var crop image.Rectangle
+	for index := range m.frames {
+		crop = crop.Union(m.visibleBounds(index))
+	}
+	if crop.Empty() || crop.Size() == m.spriteSize.Size() {
+		return crop
+	}
+
+	for index, frame := range m.frames {
+		rgba := frame.(*image.RGBA)
+		m.frames[index] = copyFrame(rgba.SubImage(crop.Add(rgba.Rect.Min)))
+	}
+	m.spriteSize = image.Rectangle{Max: crop.Size()}
+	m.anchor = m.anchor.Sub(crop.Min)
+	m.framesChanged()
+	return crop
+}
+
+// DeduplicateFrames finds frames with identical pixel data and makes them all refer to the same (the first) frame
+// image, returning the number of frames so replaced. This saves memory when the frames are standalone images (e.g.
+// after InsertFrame), rather than views of a shared sheet image.
+// After deduplication, a frame image may appear at several indexes; as always, frames are treated as read-only, and
+// modifying the pixel data of a frame (which would now change every index sharing it) is unsupported.
+func (m *Mode) DeduplicateFrames() int {
+	m.loadFrames()
+	aliases := 0
+	seen := make(map[uint64][]*image.RGBA)
+	for i, frame := range m.frames {
+		rgba := frame.(*image.RGBA)
+		digest := pixelDigest(rgba)
+		found := false
+		for _, prev := range seen[digest] {
+			if prev == rgba {
+				found = true
+				break
+			}
+			if rgbaEqual(prev, rgba) {
+				m.frames[i] = prev
+				aliases++
+				found = true
+				break
+			}
+		}
+		if !found {
+			seen[digest] = append(seen[digest], rgba)
+		}
+	}
+	return aliases
+}
+
+// clone returns a copy of the Mode which can be modified (renamed, have its frames edited, etc.) without affecting
+// m. The frame image data itself is shared. If m's frames have not been loaded yet (see SheetDimensions.Lazy), they are
+// loaded first.
+func (m *Mode) clone() *Mode {
+	m.loadFrames()
+	c := *m
+	c.frames = make([]Sprite, len(m.frames))
+	copy(c.frames, m.frames)
+	c.meta = copyMeta(m.meta)
+	c.frameNames = shiftFrameNames(m.frameNames, 0, 0)
+	if m.frameDurations != nil {
+		c.frameDurations = make([]time.Duration, len(m.frameDurations))
+		copy(c.frameDurations, m.frameDurations)
+	}
+	c.cache = &frameCache{}
+	c.lazy = nil
+	return &c
+}
+
+// deepCopy is like clone, but each frame is copied into a newly allocated image as well (see copyFrame), so the copy
+// shares nothing with m.
+func (m *Mode) deepCopy() *Mode {
+	c := m.clone()
+	for n, frame := range c.frames {
+		c.frames[n] = copyFrame(frame)
+	}
+	return c
+}
+
+// ClearResizeCache discards all cached resized frames (see Instance.FrameResized), releasing their memory.
+func (m *Mode) ClearResizeCache() {
+	m.cache.mu.Lock()
+	defer m.cache.mu.Unlock()
+	m.cache.resized = nil
+}
+
+// resizedFrame returns the frame at index resized to w x h using filter, using the cached copy if there is one, or the
+// frame itself if it is already that size.
+// The returned Sprite is shared, and must not be modified.
+func (m *Mode) resizedFrame(index int, w, h uint, filter ResizeFilter) Sprite {
+	// No resizing (or caching) is needed if the frame is already the requested size.
+	if size := m.frameAt(index).Bounds().Size(); size.X == int(w) && size.Y == int(h) {
+		return m.frameAt(index)
 	}
-	return hashstr
+	key := resizeKey{index, w, h, filter}
+	m.cache.mu.Lock()
+	defer m.cache.mu.Unlock()
+	if frame, ok := m.cache.resized[key]; ok {
+		return frame
+	}
+	frame := resizeSprite(m.frameAt(index), w, h, filter)
+	if m.cache.resized == nil {
+		m.cache.resized = make(map[resizeKey]Sprite)
+	}
+	m.cache.resized[key] = frame
+	return frame
+}
+
+// blank returns whether every one of the Mode's frames is fully transparent.
+func (m *Mode) blank() bool {
+	m.loadFrames()
+	for index := range m.frames {
+		if !m.visibleBounds(index).Empty() {
+			return false
+		}
+	}
+	return true
+}
+
+// visibleBounds returns the smallest rectangle, relative to the frame's top-left, containing the visible (not fully
+// transparent) pixels of the frame at index, using the cached value if there is one. It is the zero Rectangle if the
+// frame is entirely transparent.
+func (m *Mode) visibleBounds(index int) image.Rectangle {
+	m.cache.mu.Lock()
+	defer m.cache.mu.Unlock()
+	if bounds, ok := m.cache.visibleBounds[index]; ok {
+		return bounds
+	}
+
+	frame := m.frameAt(index).(*image.RGBA)
+	var bounds image.Rectangle
+	for y := 0; y < frame.Rect.Dy(); y++ {
+		row := rgbaRow(frame, frame.Rect.Min.Y+y)
+		for x := 0; x < frame.Rect.Dx(); x++ {
+			if row[x*4+3] != 0 {
+				bounds = bounds.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+
+	if m.cache.visibleBounds == nil {
+		m.cache.visibleBounds = make(map[int]image.Rectangle)
+	}
+	m.cache.visibleBounds[index] = bounds
+	return bounds
+}
+
+// framesChanged must be called whenever the Mode's frames are changed. It recomputes fullyOpaque and invalidates
+// cached data derived from the frames.
+func (m *Mode) framesChanged() {
+	m.lazy = nil
+	m.updateFullyOpaque()
+	m.cache.mu.Lock()
+	m.cache.resized = nil
+	m.cache.masks = nil
+	m.cache.visible = nil
+	m.cache.visibleBounds = nil
+	m.cache.hashes = nil
+	m.cache.mu.Unlock()
+	m.cache.placeholderMu.Lock()
+	m.cache.placeholder = nil
+	m.cache.placeholderMu.Unlock()
+}
+
+// updateFullyOpaque recomputes fullyOpaque from the current frames.
+func (m *Mode) updateFullyOpaque() {
+	m.fullyOpaque = true
+	for _, frame := range m.frames {
+		if !frameOpaque(frame.(*image.RGBA)) {
+			m.fullyOpaque = false
+			return
+		}
+	}
+}
+
+// validate checks that the Mode has at least one frame, and that each is an *image.RGBA of the Mode's sprite size. See
+// Sheet.Validate.
+func (m *Mode) validate() error {
+	m.loadFrames()
+	if len(m.frames) == 0 {
+		return errors.New("mode has no frames")
+	}
+	for index, frame := range m.frames {
+		rgba, ok := frame.(*image.RGBA)
+		if !ok {
+			return fmt.Errorf("frame %d is a %T, not an *image.RGBA", index, frame)
+		}
+		if rgba.Rect.Size() != m.spriteSize.Size() {
+			return fmt.Errorf("frame %d: %w", index, &ErrFrameSizeMismatch{Got: rgba.Rect, Want: m.spriteSize})
+		}
+	}
+	if m.hasActiveWindow && (m.activeStart < 0 || m.activeEnd >= len(m.frames) || m.activeStart > m.activeEnd) {
+		return fmt.Errorf("active window [%d,%d] must satisfy 0 <= start <= end < frame count (%d)",
+			m.activeStart, m.activeEnd, len(m.frames))
+	}
+	return nil
+}
+
+// OpacityThreshold is the alpha value at or above which a pixel is considered opaque when determining whether a Mode
+// is FullyOpaque. The default of 255 only considers a Mode fully opaque if every pixel of every frame is completely
+// opaque. Lowering it allows e.g. sprites with slightly translucent anti-aliased edges to be placed using the faster
+// opaque methods, but those methods copy pixels over the canvas rather than blending them, so translucent pixels will
+// not show the canvas behind them, which may be visible at the edges of sprites. It should be set before loading
+// sprites; Modes already loaded are not updated.
+var OpacityThreshold uint8 = 255
+
+// frameOpaque returns whether every pixel of frame has an alpha of at least OpacityThreshold.
+func frameOpaque(frame *image.RGBA) bool {
+	if OpacityThreshold == 255 {
+		return frame.Opaque()
+	}
+	for y := frame.Rect.Min.Y; y < frame.Rect.Max.Y; y++ {
+		row := rgbaRow(frame, y)
+		for x := 3; x < len(row); x += 4 {
+			if row[x] < OpacityThreshold {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// toRGBA returns s as an *image.RGBA, converting (copying) it if it is not one already.
+func toRGBA(s Sprite) *image.RGBA {
+	if rgba, ok := s.(*image.RGBA); ok {
+		return rgba
+	}
+	rgba := image.NewRGBA(s.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), s, s.Bounds().Min, draw.Src)
+	return rgba
+}
+
+// copyFrame returns a newly allocated copy of s, with Bounds().Min at (0,0).
+func copyFrame(s Sprite) *image.RGBA {
+	rgba := image.NewRGBA(image.Rectangle{Max: s.Bounds().Size()})
+	draw.Draw(rgba, rgba.Rect, s, s.Bounds().Min, draw.Src)
+	return rgba
 }
@@ -0,0 +1,367 @@
+package sprites
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+// assertActiveWindow fails t if m's active window is not [start,end], or if ok is false, if m has an active window.
+func assertActiveWindow(t *testing.T, m *Mode, start, end int, ok bool) {
+	t.Helper()
+	gotStart, gotEnd, gotOK := m.ActiveWindow()
+	if gotOK != ok || (ok && (gotStart != start || gotEnd != end)) {
+		t.Fatalf("ActiveWindow() = %d, %d, %v, want %d, %d, %v", gotStart, gotEnd, gotOK, start, end, ok)
+	}
+	if err := m.validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestActiveWindow(t *testing.T) {
+	i := mustInstance(t, mustEntity(t, mustSheet(t), 0), 0)
+	if i.IsInActiveWindow() {
+		t.Fatal("in active window with none set")
+	}
+	if err := i.Mode.SetActiveWindow(2, 4); err == nil {
+		t.Fatal("window past the last frame accepted")
+	}
+	if err := i.Mode.SetActiveWindow(1, 2); err != nil {
+		t.Fatal(err)
+	}
+	i.StartAnimation()
+	for k, want := range []bool{false, true, true, false, false} {
+		if got := i.IsInActiveWindow(); got != want {
+			t.Fatalf("frame %d: IsInActiveWindow() = %v, want %v", k, got, want)
+		}
+		i.Frame()
+	}
+}
+
+func TestActiveWindowFollowsFrameEdits(t *testing.T) {
+	m := mustMode(t, mustEntity(t, mustSheet(t), 0), 0)
+	frame, err := m.GetFrame(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetActiveWindow(1, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// Inserting before the window shifts it; inserting within it grows it; inserting after it leaves it unchanged.
+	if err := m.InsertFrame(0, frame); err != nil {
+		t.Fatal(err)
+	}
+	assertActiveWindow(t, m, 2, 3, true)
+	if err := m.InsertFrame(3, frame); err != nil {
+		t.Fatal(err)
+	}
+	assertActiveWindow(t, m, 2, 4, true)
+	if err := m.InsertFrame(5, frame); err != nil {
+		t.Fatal(err)
+	}
+	assertActiveWindow(t, m, 2, 4, true)
+
+	// Removing before the window shifts it; removing within it shrinks it.
+	if err := m.RemoveFrame(0); err != nil {
+		t.Fatal(err)
+	}
+	assertActiveWindow(t, m, 1, 3, true)
+	if err := m.RemoveFrame(3); err != nil {
+		t.Fatal(err)
+	}
+	assertActiveWindow(t, m, 1, 2, true)
+
+	// Truncating clamps the window, and clears it once none of its frames remain.
+	if err := m.SetFrameCount(2); err != nil {
+		t.Fatal(err)
+	}
+	assertActiveWindow(t, m, 1, 1, true)
+	if err := m.RemoveFrame(1); err != nil {
+		t.Fatal(err)
+	}
+	assertActiveWindow(t, m, 0, 0, false)
+
+	if err := m.SetActiveWindow(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	m.activeEnd = 5
+	if err := m.validate(); err == nil {
+		t.Fatal("out of range active window passed validation")
+	}
+}
+
+func TestInsertAndRemoveFrame(t *testing.T) {
+	m := mustMode(t, mustEntity(t, mustSheet(t), 0), 0)
+	transparent := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	if err := m.InsertFrame(1, transparent); err != nil {
+		t.Fatal(err)
+	}
+	if m.FrameCount() != 5 {
+		t.Fatalf("FrameCount() = %d, want 5", m.FrameCount())
+	}
+	if frame, _ := m.GetFrame(1); frame != Sprite(transparent) {
+		t.Fatal("inserted frame not at index 1")
+	}
+	if m.FullyOpaque() {
+		t.Fatal("Mode with a transparent frame is fully opaque")
+	}
+	if err := m.InsertFrame(1, image.NewRGBA(image.Rect(0, 0, 5, 4))); err == nil {
+		t.Fatal("frame of the wrong size inserted")
+	}
+	if err := m.InsertFrame(6, transparent); err == nil {
+		t.Fatal("frame inserted past the end")
+	}
+
+	if err := m.RemoveFrame(1); err != nil {
+		t.Fatal(err)
+	}
+	if !m.FullyOpaque() {
+		t.Fatal("Mode is not fully opaque once the transparent frame is removed")
+	}
+	for m.FrameCount() > 1 {
+		if err := m.RemoveFrame(0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.RemoveFrame(0); err == nil {
+		t.Fatal("only frame removed")
+	}
+}
+
+func TestRemoveFrameMidAnimation(t *testing.T) {
+	i := mustInstance(t, mustEntity(t, mustSheet(t), 0), 0)
+	i.StartAnimation()
+	for k := 0; k < 3; k++ {
+		i.Frame()
+	}
+	// The Instance's current frame, 3, is now past the end, so it wraps around to the first frame.
+	if err := i.Mode.RemoveFrame(3); err != nil {
+		t.Fatal(err)
+	}
+	first, _ := i.Mode.GetFrame(0)
+	if i.Frame() != first {
+		t.Fatal("current frame past the end did not wrap to the first frame")
+	}
+	second, _ := i.Mode.GetFrame(1)
+	if i.Frame() != second {
+		t.Fatal("animation did not continue from the first frame")
+	}
+}
+
+func TestDeduplicateFrames(t *testing.T) {
+	m := mustMode(t, mustEntity(t, mustSheet(t), 0), 0)
+	// Append a copy of frame 0 and two identical blank frames, giving [f0 f1 f2 f3 f0' blank blank'].
+	if err := m.InsertFrame(4, filledImage(4, 4, cellColor(0, 0))); err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < 2; n++ {
+		if err := m.InsertFrame(5, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if m.frames[0] == m.frames[4] || m.frames[5] == m.frames[6] {
+		t.Fatal("frames shared before deduplication")
+	}
+
+	if n := m.DeduplicateFrames(); n != 2 {
+		t.Fatalf("DeduplicateFrames replaced %d frames, want 2", n)
+	}
+	if m.frames[0] != m.frames[4] || m.frames[5] != m.frames[6] {
+		t.Fatal("identical frames not shared after deduplication")
+	}
+	for i := 1; i < 4; i++ {
+		if m.frames[i] == m.frames[0] || m.frames[i] == m.frames[5] {
+			t.Fatalf("distinct frame %d shared after deduplication", i)
+		}
+	}
+	if n := m.DeduplicateFrames(); n != 0 {
+		t.Fatalf("second DeduplicateFrames replaced %d frames, want 0", n)
+	}
+}
+
+// assertFrameName fails t if the frame named name is not at index, or if index < 0, if there is a frame named name.
+func assertFrameName(t *testing.T, m *Mode, name string, index int) {
+	t.Helper()
+	got, err := m.FrameIndexByName(name)
+	if index < 0 {
+		if err == nil {
+			t.Fatalf("frame %q found at %d, want none", name, got)
+		}
+		return
+	}
+	if err != nil || got != index {
+		t.Fatalf("frame %q at %d (%v), want %d", name, got, err, index)
+	}
+}
+
+func TestFrameNames(t *testing.T) {
+	e := mustEntity(t, mustSheet(t), 0)
+	m := mustMode(t, e, 0)
+	for index, name := range map[int]string{1: "contact", 3: "release"} {
+		if err := m.SetFrameName(index, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.SetFrameName(2, "contact"); err == nil {
+		t.Fatal("duplicate frame name accepted")
+	}
+	if err := m.SetFrameName(4, "x"); err == nil {
+		t.Fatal("name for a nonexistent frame accepted")
+	}
+
+	// Renaming replaces the old name.
+	if err := m.SetFrameName(1, "hit"); err != nil {
+		t.Fatal(err)
+	}
+	assertFrameName(t, m, "contact", -1)
+	assertFrameName(t, m, "hit", 1)
+
+	// Names follow their frames, and are removed with them.
+	if err := m.InsertFrame(0, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	assertFrameName(t, m, "hit", 2)
+	assertFrameName(t, m, "release", 4)
+	if err := m.RemoveFrame(2); err != nil {
+		t.Fatal(err)
+	}
+	assertFrameName(t, m, "hit", -1)
+	assertFrameName(t, m, "release", 3)
+	if m.FrameName(2) != "" {
+		t.Fatalf("frame 2 named %q after removal, want none", m.FrameName(2))
+	}
+	if err := m.SetFrameCount(3); err != nil {
+		t.Fatal(err)
+	}
+	assertFrameName(t, m, "release", -1)
+
+	if err := m.SetFrameName(1, "a"); err != nil {
+		t.Fatal(err)
+	}
+	i := mustInstance(t, e, 0)
+	if err := i.SetCurrentFrameByName("a"); err != nil || i.CurrentFrameIndex() != 1 {
+		t.Fatalf("SetCurrentFrameByName(\"a\") moved to frame %d (%v), want 1", i.CurrentFrameIndex(), err)
+	}
+	if err := i.SetCurrentFrameByName("release"); err == nil {
+		t.Fatal("SetCurrentFrameByName of a removed name succeeded")
+	}
+}
+
+func TestGetFrameWrapped(t *testing.T) {
+	m := mustMode(t, mustEntity(t, mustSheet(t), 0), 0)
+	for _, c := range []struct{ index, want int }{{-1, 3}, {4, 0}, {-9, 3}, {2, 2}, {-4, 0}, {13, 1}} {
+		want, _ := m.GetFrame(c.want)
+		if m.GetFrameWrapped(c.index) != want {
+			t.Fatalf("GetFrameWrapped(%d) is not frame %d", c.index, c.want)
+		}
+	}
+	if _, err := m.GetFrame(-1); err == nil {
+		t.Fatal("GetFrame(-1) succeeded")
+	}
+}
+
+func TestCropToContent(t *testing.T) {
+	// Two 8x8 frames, with a red pixel at (3,2) in the first and a green one at (5,4) in the second.
+	red, green := color.RGBA{R: 255, A: 255}, color.RGBA{G: 255, A: 255}
+	img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	img.SetRGBA(3, 2, red)
+	img.SetRGBA(8+5, 4, green)
+	d := SheetDimensions{EntitiesPerRow: 1, EntitiesPerColumn: 1, ModesPerEntity: 1, FramesPerAnimation: 2,
+		FramesRunRows: true, SpriteWidth: 8, SpriteHeight: 8}
+	s, err := NewSheet(img, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := mustMode(t, mustEntity(t, s, 0), 0)
+	m.SetAnchor(image.Pt(4, 8))
+	if crop := m.CropToContent(); crop != image.Rect(3, 2, 6, 5) {
+		t.Fatalf("crop is %v, want (3,2)-(6,5)", crop)
+	}
+	if m.SpriteSize() != image.Rect(0, 0, 3, 3) || m.Anchor() != image.Pt(1, 6) {
+		t.Fatalf("cropped size is %v and anchor %v, want 3x3 and (1,6)", m.SpriteSize(), m.Anchor())
+	}
+	// The visible pixels are moved by the crop's Min.
+	frame0, _ := m.FrameCopy(0)
+	frame1, _ := m.FrameCopy(1)
+	if frame0.RGBAAt(0, 0) != red || frame1.RGBAAt(2, 2) != green {
+		t.Fatal("cropped frames' visible pixels are not offset by the crop")
+	}
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	// The sheet image is not modified.
+	if img.RGBAAt(3, 2) != red || img.Bounds().Dx() != 16 {
+		t.Fatal("CropToContent modified the sheet image")
+	}
+
+	// A fully transparent Mode is left unchanged.
+	m = mustMode(t, singleSpriteEntity(t, image.NewRGBA(image.Rect(0, 0, 8, 8))), 0)
+	if crop := m.CropToContent(); crop != (image.Rectangle{}) || m.SpriteSize() != image.Rect(0, 0, 8, 8) {
+		t.Fatalf("cropping a transparent Mode gave %v, size %v", crop, m.SpriteSize())
+	}
+}
+
+func TestCropToContentSizes(t *testing.T) {
+	d := basicDims()
+	img := testSheetImage(d)
+	s, err := NewSheet(img, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := mustEntity(t, s, 0)
+	// Clear the outer pixels of Mode 1's frames (views of img), so that cropping leaves their 2x2 centers.
+	m := mustMode(t, e, 1)
+	for f := 0; f < m.FrameCount(); f++ {
+		frame, _ := m.GetFrame(f)
+		r := frame.Bounds()
+		draw.Draw(img, r, image.Transparent, image.Point{}, draw.Src)
+		draw.Draw(img, r.Inset(1), image.NewUniform(color.White), image.Point{}, draw.Src)
+	}
+	if crop := m.CropToContent(); crop != image.Rect(1, 1, 3, 3) {
+		t.Fatalf("crop is %v, want (1,1)-(3,3)", crop)
+	}
+	if err := s.Validate(); err != nil {
+		t.Fatalf("Sheet with a cropped Mode is invalid: %v", err)
+	}
+	if e.SpriteSize() != image.Rect(0, 0, 4, 4) {
+		t.Fatalf("Entity SpriteSize is %v, want Mode 0's 4x4", e.SpriteSize())
+	}
+
+	// Instances are composed by the size of their current Modes.
+	cropped, uncropped := mustInstance(t, e, 1), mustInstance(t, e, 0)
+	if _, err := ComposeInstancesNoAdvance([]*Instance{uncropped, cropped}); err == nil {
+		t.Error("Instances in Modes of different sprite sizes composed")
+	}
+	composite, err := ComposeInstancesNoAdvance([]*Instance{cropped, mustInstance(t, e, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if composite.Bounds() != image.Rect(0, 0, 2, 2) {
+		t.Errorf("composed cropped Instances are %v, want 2x2", composite.Bounds())
+	}
+	if _, _, err := e.ComposeSheet(SheetDimensions{}); err == nil {
+		t.Error("Entity with Modes of different sprite sizes composed into a sheet")
+	}
+}
+
+func TestFrames(t *testing.T) {
+	m := mustMode(t, mustEntity(t, mustSheet(t), 0), 0)
+	frames := m.Frames()
+	if len(frames) != m.FrameCount() {
+		t.Fatalf("%d frames, want %d", len(frames), m.FrameCount())
+	}
+	for f, frame := range frames {
+		if want, _ := m.GetFrame(f); frame != want {
+			t.Fatalf("frame %d differs from GetFrame", f)
+		}
+	}
+	// Modifying the returned slice does not affect the Mode.
+	first := frames[0]
+	frames[0] = nil
+	frames = append(frames, first)
+	if got, _ := m.GetFrame(0); got != first || len(m.Frames()) != len(frames)-1 {
+		t.Fatal("modifying the returned slice modified the Mode")
+	}
+}
@@ -0,0 +1,71 @@
+package sprites
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// NineSlice draws a Sprite scaled to fill an arbitrary rectangle while keeping its corners unscaled, as is typical for
+// UI panels and buttons. The Sprite is divided into nine regions by the insets: the four corners are drawn at their
+// original size, the top and bottom edges are stretched horizontally, the left and right edges are stretched
+// vertically, and the center is stretched in both directions.
+type NineSlice struct {
+	sprite                   *image.RGBA
+	left, right, top, bottom int
+}
+
+// NewNineSlice creates a NineSlice from s, with the given insets (the sizes of the unscaled borders) from each edge.
+// The insets must be >= 0 and leave a center region of at least 1x1 pixels. If s is not an *image.RGBA, it is copied
+// into one.
+func NewNineSlice(s Sprite, left, right, top, bottom int) (*NineSlice, error) {
+	if s == nil {
+		return nil, errors.New("sprite for NineSlice is nil")
+	}
+	size := s.Bounds().Size()
+	if left < 0 || right < 0 || top < 0 || bottom < 0 {
+		return nil, fmt.Errorf("NineSlice insets (left %d, right %d, top %d, bottom %d) must be >= 0",
+			left, right, top, bottom)
+	}
+	if left+right >= size.X || top+bottom >= size.Y {
+		return nil, fmt.Errorf("NineSlice insets (left %d, right %d, top %d, bottom %d) must leave a center region "+
+			"of at least 1x1 in the %dx%d sprite", left, right, top, bottom, size.X, size.Y)
+	}
+	return &NineSlice{
+		sprite: toRGBA(s),
+		left:   left,
+		right:  right,
+		top:    top,
+		bottom: bottom,
+	}, nil
+}
+
+// Draw draws the NineSlice onto canvas (with draw.Over), filling bounds. The stretched regions use nearest neighbor
+// scaling. If bounds is smaller than the corners (e.g. narrower than left + right), the edges and center are omitted
+// and the corners overlap; nothing is drawn outside bounds.
+func (n *NineSlice) Draw(canvas draw.Image, bounds image.Rectangle) {
+	src := n.sprite.Rect
+	srcXs := [4]int{src.Min.X, src.Min.X + n.left, src.Max.X - n.right, src.Max.X}
+	srcYs := [4]int{src.Min.Y, src.Min.Y + n.top, src.Max.Y - n.bottom, src.Max.Y}
+	dstXs := [4]int{bounds.Min.X, bounds.Min.X + n.left, bounds.Max.X - n.right, bounds.Max.X}
+	dstYs := [4]int{bounds.Min.Y, bounds.Min.Y + n.top, bounds.Max.Y - n.bottom, bounds.Max.Y}
+
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			sr := image.Rect(srcXs[col], srcYs[row], srcXs[col+1], srcYs[row+1])
+			dr := image.Rect(dstXs[col], dstYs[row], dstXs[col+1], dstYs[row+1])
+			if sr.Empty() || dr.Empty() {
+				continue
+			}
+			if dr.Size() == sr.Size() {
+				clipped := dr.Intersect(bounds)
+				draw.Draw(canvas, clipped, n.sprite, sr.Min.Add(clipped.Min.Sub(dr.Min)), draw.Over)
+			} else {
+				xdraw.NearestNeighbor.Scale(canvas, dr, n.sprite, sr, xdraw.Over, nil)
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package sprites
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// nineSliceImage returns a 3x3 image in which each pixel has its own color: the red channel is 100 times its x, and
+// the green channel 100 times its y.
+func nineSliceImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 3))
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 3; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 100), G: uint8(y * 100), A: 255})
+		}
+	}
+	return img
+}
+
+func TestNewNineSlice(t *testing.T) {
+	img := nineSliceImage()
+	for _, insets := range [][4]int{{1, 2, 1, 1}, {1, 1, 0, 3}, {-1, 1, 1, 1}} {
+		if _, err := NewNineSlice(img, insets[0], insets[1], insets[2], insets[3]); err == nil {
+			t.Fatalf("insets %v accepted for a 3x3 sprite", insets)
+		}
+	}
+	if _, err := NewNineSlice(nil, 0, 0, 0, 0); err == nil {
+		t.Fatal("nil sprite accepted")
+	}
+}
+
+func TestNineSliceDraw(t *testing.T) {
+	// 1 pixel insets on a 3x3 sprite leave a degenerate 1x1 center.
+	n, err := NewNineSlice(nineSliceImage(), 1, 1, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	canvas := image.NewRGBA(image.Rect(0, 0, 12, 12))
+	bounds := image.Rect(1, 1, 11, 9)
+	n.Draw(canvas, bounds)
+	// region returns the index (0, 1 or 2) of the sprite row or column drawn at v, for a span from min to max.
+	region := func(v, min, max int) int {
+		switch {
+		case v == min:
+			return 0
+		case v == max-1:
+			return 2
+		default:
+			return 1
+		}
+	}
+	for y := 0; y < 12; y++ {
+		for x := 0; x < 12; x++ {
+			want := color.RGBA{}
+			if image.Pt(x, y).In(bounds) {
+				sx, sy := region(x, bounds.Min.X, bounds.Max.X), region(y, bounds.Min.Y, bounds.Max.Y)
+				want = color.RGBA{R: uint8(sx * 100), G: uint8(sy * 100), A: 255}
+			}
+			if c := canvas.RGBAAt(x, y); c != want {
+				t.Fatalf("pixel (%d,%d) is %v, want %v", x, y, c, want)
+			}
+		}
+	}
+
+	// Bounds smaller than the corners draw nothing outside them.
+	canvas = image.NewRGBA(image.Rect(0, 0, 12, 12))
+	n.Draw(canvas, image.Rect(0, 0, 1, 1))
+	for y := 0; y < 12; y++ {
+		for x := 0; x < 12; x++ {
+			if c := canvas.RGBAAt(x, y); (x > 0 || y > 0) && c.A != 0 {
+				t.Fatalf("pixel (%d,%d) outside 1x1 bounds drawn", x, y)
+			}
+		}
+	}
+}
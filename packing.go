@@ -0,0 +1,71 @@
+package sprites
+
+import (
+	"image"
+)
+
+// PackingReport describes how a Sheet's frames use their cells of the sprite grid. See Sheet.PackingReport.
+type PackingReport struct {
+	// UnusedCells is the number of frames (cells) which are entirely transparent.
+	UnusedCells int
+	// EdgeTouchingFrames lists the frames with visible (not fully transparent) pixels on the edge of their cell, which
+	// risk bleeding into (or picking up) neighboring cells when resized or filtered, in index order.
+	EdgeTouchingFrames []FrameRef
+	// UsedPixelFraction is the fraction of the frames' total area which is visible pixels, or 0 if there are no frames.
+	UsedPixelFraction float64
+}
+
+// FrameRef identifies a frame by the indexes of its Entity (in the Sheet), Mode (in the Entity) and frame (in the
+// Mode).
+type FrameRef struct {
+	Entity, Mode, Frame int
+}
+
+// PackingReport analyzes the frames of every Mode of every Entity in the Sheet, reporting the cells which are unused,
+// the frames whose content touches the edge of their cell, and the fraction of the total area which is used; e.g. to
+// decide whether a sheet needs (more) spacing or a margin, or could be packed more tightly. It does not change the
+// Sheet. Only the frames the Sheet holds are considered, not any cells of the sheet image which were not loaded.
+func (s *Sheet) PackingReport() PackingReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var report PackingReport
+	var total, used int
+	for _, entityIdx := range s.sortedIndexes() {
+		e := s.entities[entityIdx]
+		for _, modeIdx := range e.sortedModeIndexes() {
+			m := e.modes[modeIdx]
+			m.loadFrames()
+			for index, frame := range m.frames {
+				size := frame.Bounds().Size()
+				total += size.X * size.Y
+				bounds := m.visibleBounds(index)
+				if bounds.Empty() {
+					report.UnusedCells++
+					continue
+				}
+				used += countVisible(toRGBA(frame))
+				if bounds.Min.X == 0 || bounds.Min.Y == 0 || bounds.Max.X == size.X || bounds.Max.Y == size.Y {
+					report.EdgeTouchingFrames = append(report.EdgeTouchingFrames, FrameRef{Entity: entityIdx, Mode: modeIdx, Frame: index})
+				}
+			}
+		}
+	}
+	if total > 0 {
+		report.UsedPixelFraction = float64(used) / float64(total)
+	}
+	return report
+}
+
+// countVisible returns the number of pixels of img which are not fully transparent.
+func countVisible(img *image.RGBA) int {
+	count := 0
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		row := rgbaRow(img, y)
+		for x := 3; x < len(row); x += 4 {
+			if row[x] != 0 {
+				count++
+			}
+		}
+	}
+	return count
+}
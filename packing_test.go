@@ -0,0 +1,44 @@
+package sprites
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestPackingReport(t *testing.T) {
+	// One Entity of 3 Modes of 4 frames. Mode 0's frames each have a 2x2 square in their middle, Mode 1 is blank, and
+	// Mode 2's frame 0 has a single pixel on its top edge.
+	d := basicDims()
+	d.EntitiesPerRow, d.EntitiesPerColumn = 1, 1
+	img := image.NewRGBA(image.Rect(0, 0, 12, 16))
+	for f := 0; f < 4; f++ {
+		for y := 1; y < 3; y++ {
+			for x := 1; x < 3; x++ {
+				img.SetRGBA(x, f*4+y, color.RGBA{R: 255, A: 255})
+			}
+		}
+	}
+	img.SetRGBA(9, 0, color.RGBA{G: 255, A: 255})
+	s, err := NewSheet(img, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := s.PackingReport()
+	if report.UnusedCells != 4+3 {
+		t.Errorf("UnusedCells is %d, want 7", report.UnusedCells)
+	}
+	if want := []FrameRef{{Entity: 0, Mode: 2, Frame: 0}}; !reflect.DeepEqual(report.EdgeTouchingFrames, want) {
+		t.Errorf("EdgeTouchingFrames is %v, want %v", report.EdgeTouchingFrames, want)
+	}
+	if want := 17.0 / 192; report.UsedPixelFraction != want {
+		t.Errorf("UsedPixelFraction is %g, want %g", report.UsedPixelFraction, want)
+	}
+
+	// Every frame of the opaque test sheet is used, and touches its edges.
+	report = mustSheet(t).PackingReport()
+	if report.UnusedCells != 0 || len(report.EdgeTouchingFrames) != 4*3*4 || report.UsedPixelFraction != 1 {
+		t.Errorf("report of an opaque sheet is %+v", report)
+	}
+}
@@ -0,0 +1,106 @@
+package sprites
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"image"
+)
+
+// rgbaRow returns the pixel bytes of row y (in img's coordinate space) of img.
+func rgbaRow(img *image.RGBA, y int) []uint8 {
+	start := img.PixOffset(img.Rect.Min.X, y)
+	return img.Pix[start : start+img.Rect.Dx()*4]
+}
+
+// pixelDigest returns a (non-cryptographic) digest of the pixel data of img, independent of its Bounds().Min.
+func pixelDigest(img *image.RGBA) uint64 {
+	h := fnv.New64a()
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		_, _ = h.Write(rgbaRow(img, y))
+	}
+	return h.Sum64()
+}
+
+// rgbaEqual returns whether a and b are the same size and have identical pixel data (regardless of their
+// Bounds().Min).
+func rgbaEqual(a, b *image.RGBA) bool {
+	if a.Rect.Size() != b.Rect.Size() {
+		return false
+	}
+	for dy := 0; dy < a.Rect.Dy(); dy++ {
+		if !bytes.Equal(rgbaRow(a, a.Rect.Min.Y+dy), rgbaRow(b, b.Rect.Min.Y+dy)) {
+			return false
+		}
+	}
+	return true
+}
+
+// isPremultipliedConsistent returns an error identifying the first pixel (if any) of img which is not a valid
+// alpha-premultiplied color, that is, one with a color channel greater than its alpha.
+func isPremultipliedConsistent(img *image.RGBA) error {
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		row := rgbaRow(img, y)
+		for x := 0; x < len(row); x += 4 {
+			if a := row[x+3]; row[x] > a || row[x+1] > a || row[x+2] > a {
+				return fmt.Errorf("pixel (%d,%d) %v has a color channel greater than its alpha", img.Rect.Min.X+x/4, y,
+					row[x:x+4])
+			}
+		}
+	}
+	return nil
+}
+
+// clampToAlpha clamps each color channel of each pixel of img to the pixel's alpha, making it a valid
+// alpha-premultiplied color. Filters with negative lobes (e.g. bicubic) can otherwise overshoot alpha at edges.
+func clampToAlpha(img *image.RGBA) {
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		row := rgbaRow(img, y)
+		for x := 0; x < len(row); x += 4 {
+			a := row[x+3]
+			for c := x; c < x+3; c++ {
+				if row[c] > a {
+					row[c] = a
+				}
+			}
+		}
+	}
+}
+
+// SpriteDiff compares a and b pixel by pixel (regardless of their Bounds().Min, so e.g. a frame may be compared with a
+// copy of it), for tests of generated or transformed sprites and for diagnosing near misses. It returns whether they are
+// identical, the number of pixels which differ, and the largest difference of any one (premultiplied, 8-bit) color or
+// alpha channel of any pixel. a and b must be the same size.
+func SpriteDiff(a, b Sprite) (equal bool, diffPixels int, maxChannelDelta int, err error) {
+	if a == nil || b == nil {
+		return false, 0, 0, errors.New("sprite to compare is nil")
+	}
+	if a.Bounds().Size() != b.Bounds().Size() {
+		return false, 0, 0, fmt.Errorf("sprite sizes (%dx%d and %dx%d) differ", a.Bounds().Dx(), a.Bounds().Dy(),
+			b.Bounds().Dx(), b.Bounds().Dy())
+	}
+	ra, rb := toRGBA(a), toRGBA(b)
+	for dy := 0; dy < ra.Rect.Dy(); dy++ {
+		rowA, rowB := rgbaRow(ra, ra.Rect.Min.Y+dy), rgbaRow(rb, rb.Rect.Min.Y+dy)
+		for x := 0; x < len(rowA); x += 4 {
+			differs := false
+			for c := x; c < x+4; c++ {
+				delta := int(rowA[c]) - int(rowB[c])
+				if delta < 0 {
+					delta = -delta
+				}
+				if delta != 0 {
+					differs = true
+					if delta > maxChannelDelta {
+						maxChannelDelta = delta
+					}
+				}
+			}
+			if differs {
+				diffPixels++
+			}
+		}
+	}
+	return diffPixels == 0, diffPixels, maxChannelDelta, nil
+}
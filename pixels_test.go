@@ -0,0 +1,103 @@
+package sprites
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestSpriteDiff(t *testing.T) {
+	m := mustMode(t, mustEntity(t, mustSheet(t), 1), 1)
+	frame, _ := m.GetFrame(2)
+	frameCopy, _ := m.FrameCopy(2)
+	// A frame equals a copy of it, despite their different origins.
+	if equal, diffPixels, maxDelta, err := SpriteDiff(frame, frameCopy); !equal || diffPixels != 0 || maxDelta != 0 || err != nil {
+		t.Fatalf("frame vs copy: %t, %d, %d, %v; want equal", equal, diffPixels, maxDelta, err)
+	}
+
+	// cellColor(4, 2) is {40 20 7 255}, so the largest change is the red channel's 40.
+	frameCopy.SetRGBA(1, 1, color.RGBA{B: 20, A: 250})
+	if equal, diffPixels, maxDelta, err := SpriteDiff(frame, frameCopy); equal || diffPixels != 1 || maxDelta != 40 || err != nil {
+		t.Fatalf("one pixel changed: %t, %d, %d, %v; want 1 pixel differing by up to 40", equal, diffPixels, maxDelta, err)
+	}
+
+	if _, _, _, err := SpriteDiff(frame, image.NewRGBA(image.Rect(0, 0, 3, 4))); err == nil {
+		t.Error("SpriteDiff of different sizes did not fail")
+	}
+	if _, _, _, err := SpriteDiff(frame, nil); err == nil {
+		t.Error("SpriteDiff with nil did not fail")
+	}
+}
+
+func TestIsPremultipliedConsistent(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.SetRGBA(0, 0, color.RGBA{40, 50, 60, 60})
+	if err := isPremultipliedConsistent(img); err != nil {
+		t.Fatal(err)
+	}
+	img.SetRGBA(1, 1, color.RGBA{9, 0, 0, 8})
+	if err := isPremultipliedConsistent(img); err == nil {
+		t.Fatal("no error for a color channel greater than alpha")
+	}
+}
+
+// TestTransformsPremultiplied checks that the transforms, resizing and blending keep a soft-edged sprite's pixels
+// valid alpha-premultiplied colors, including when chained.
+func TestTransformsPremultiplied(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			a := uint8((x*16 + y*3) % 256)
+			if x > 4 && x < 11 && y > 4 && y < 11 {
+				a = 255
+			}
+			src.Set(x, y, color.NRGBA{250, 30, 200, a})
+		}
+	}
+	check := func(name string, s Sprite) {
+		t.Helper()
+		if err := isPremultipliedConsistent(toRGBA(s)); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	check("source", src)
+	e := singleSpriteEntity(t, src)
+	m := mustMode(t, e, 0)
+
+	for _, c := range []struct {
+		name string
+		mode *Mode
+	}{
+		{"Adjust", m.Adjust(0.3, 1.5, 0.7)},
+		{"Adjust, darkening", m.Adjust(-0.5, 0.2, 2)},
+		{"Grayscale", m.Grayscale()},
+		{"WithOutline", m.WithOutline(color.RGBA{255, 255, 0, 100}, 2)},
+		{"chained", m.Adjust(0.2, 1, 0.5).Grayscale().WithOutline(color.RGBA{10, 10, 10, 10}, 1)},
+	} {
+		frame, err := c.mode.GetFrame(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(c.name, frame)
+	}
+	for _, filter := range []ResizeFilter{ResizeNearestNeighbor, ResizeBilinear, ResizeBicubic, ResizeLanczos} {
+		check(fmt.Sprintf("shrunk with filter %d", filter), m.resizedFrame(0, 7, 7, filter))
+		check(fmt.Sprintf("enlarged with filter %d", filter), m.resizedFrame(0, 40, 40, filter))
+	}
+
+	i := mustInstance(t, e, 0)
+	for _, mode := range []BlendMode{BlendOver, BlendAdd, BlendMultiply, BlendScreen} {
+		canvas := filledImage(20, 20, color.NRGBA{10, 200, 100, 90})
+		i.PlaceOnBlend(canvas, image.Pt(2, 2), mode)
+		i.PlaceOnBlend(canvas, image.Pt(3, 1), mode)
+		check(fmt.Sprintf("blend mode %d", mode), canvas)
+	}
+	canvas := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	i.PlaceOnLinear(canvas, image.Pt(1, 1))
+	i.PlaceOnLinear(canvas, image.Pt(2, 3))
+	check("PlaceOnLinear", canvas)
+	i.PlaceOnScaled(canvas, image.Pt(0, 0), 1.7, 1.3)
+	check("PlaceOnScaled", canvas)
+	check("FrameBlended", i.FrameBlended(0.3))
+}
@@ -0,0 +1,56 @@
+package sprites
+
+import (
+	"sync"
+)
+
+// InstancePool recycles the Instances of a Sheet's Entities, to reduce allocations (and so garbage collection) when
+// many short-lived Instances are created and discarded, e.g. for projectiles or particles. It is safe for concurrent
+// use.
+type InstancePool struct {
+	sheet *Sheet
+	pool  sync.Pool
+}
+
+// NewInstancePool returns an empty InstancePool for the Entities of sheet.
+func NewInstancePool(sheet *Sheet) *InstancePool {
+	return &InstancePool{sheet: sheet}
+}
+
+// Get returns an Instance of the Entity named entityName in the Mode with index initialMode, as from
+// Entity.NewInstance, reusing one previously returned to the pool with Put if there is one.
+func (p *InstancePool) Get(entityName string, initialMode int) (*Instance, error) {
+	entity, err := p.sheet.GetEntityByName(entityName)
+	if err != nil {
+		return nil, err
+	}
+	mode, ok := entity.modes[initialMode]
+	if !ok {
+		// Let NewInstance report the problem.
+		return entity.NewInstance(initialMode)
+	}
+
+	i, ok := p.pool.Get().(*Instance)
+	if !ok {
+		return entity.NewInstance(initialMode)
+	}
+	i.Entity = entity
+	i.mu.Lock()
+	i.Mode = mode
+	i.mu.Unlock()
+	return entity.track(i), nil
+}
+
+// Put returns i to the pool, to be reused by Get. Its name, playback state and callbacks are reset, so it must not be
+// used by the caller after Put. If the Sheet is tracking i (see Sheet.TrackInstances), it stops doing so.
+func (p *InstancePool) Put(i *Instance) {
+	if i.Entity != nil && i.Entity.tracker != nil {
+		i.Entity.tracker.remove(i)
+	}
+	i.mu.Lock()
+	i.clear()
+	i.mu.Unlock()
+	i.name = ""
+	i.Entity = nil
+	p.pool.Put(i)
+}
@@ -0,0 +1,76 @@
+package sprites
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestInstancePool(t *testing.T) {
+	s := mustSheet(t)
+	names := s.EntityNames()
+	p := NewInstancePool(s)
+	i, err := p.Get(names[1], 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.SetName("used")
+	i.SetSpeedScale(2.5)
+	i.SetLoopCount(3)
+	i.SetRandomPlayback(true, rand.New(rand.NewSource(1)))
+	i.OnFrame(1, func() {})
+	i.SetOnModeChange(func(string, string) {})
+	if err := i.QueueModes(mustEntity(t, s, 1).ModeNames()[0]); err != nil {
+		t.Fatal(err)
+	}
+	i.StartAnimation()
+	i.Frame()
+	i.PauseAnimation()
+
+	// Put resets all of the Instance's state.
+	p.Put(i)
+	if i.name != "" || i.Entity != nil || !reflect.DeepEqual(i.animation, &animation{speedScale: 1}) {
+		t.Fatalf("Instance not reset by Put: %+v", i.animation)
+	}
+
+	// Whether or not it is reused, an Instance from Get is the same as a new one.
+	got, err := p.Get(names[0], 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := mustInstance(t, mustEntity(t, s, 0), 1)
+	if got.name != "" || got.Entity != want.Entity || !reflect.DeepEqual(got.animation, want.animation) {
+		t.Fatalf("Instance from Get is %+v, want %+v", got.animation, want.animation)
+	}
+
+	if _, err := p.Get(names[0], 3); err == nil {
+		t.Error("Get of a nonexistent Mode did not fail")
+	}
+	if _, err := p.Get("nonexistent", 0); err == nil {
+		t.Error("Get of a nonexistent Entity did not fail")
+	}
+}
+
+func BenchmarkInstancePool(b *testing.B) {
+	s := mustSheet(b)
+	name := s.EntityNames()[0]
+	p := NewInstancePool(s)
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		i, err := p.Get(name, 1)
+		if err != nil {
+			b.Fatal(err)
+		}
+		p.Put(i)
+	}
+}
+
+func BenchmarkNewInstance(b *testing.B) {
+	e := mustEntity(b, mustSheet(b), 0)
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		if _, err := e.NewInstance(1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
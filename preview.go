@@ -0,0 +1,183 @@
+package sprites
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// ContactSheet returns an image cataloging the Sheet: the first frame of each Entity's first Mode, arranged in index
+// order in a grid cols cells wide, with the Entity's name drawn beneath it in a strip labelHeight pixels high.
+// Every cell is the size of the largest frame (plus labelHeight); smaller frames are centered within their cell.
+// Names too long for the cell are clipped. If labelHeight is <= 0 no names are drawn, and if cols is <= 0 all
+// Entities are placed on a single row.
+func (s *Sheet) ContactSheet(cols int, labelHeight int) *image.RGBA {
+	return s.ContactSheetWith(cols, labelHeight, ExportOptions{})
+}
+
+// ContactSheetWith is like ContactSheet, but with opts; if opts.Background is set, the frames are drawn over it.
+func (s *Sheet) ContactSheetWith(cols int, labelHeight int, opts ExportOptions) *image.RGBA {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	idxs := s.sortedIndexes()
+	frames := make([]Sprite, len(idxs))
+	names := make([]string, len(idxs))
+	for n, idx := range idxs {
+		frames[n] = s.entities[idx].firstFrame()
+		names[n] = s.entities[idx].name
+	}
+	return previewGrid(frames, names, cols, labelHeight, opts.Background)
+}
+
+// PreviewImage returns an image of the first frame of the Mode with index mode of each Entity, arranged in index order
+// in a grid cols cells wide. Every cell is the size of the largest frame; smaller frames are centered within their
+// cell. Entities without the Mode are left blank (so each Entity's cell position is the same for every mode). It is an
+// error if cols is <= 0, or if no Entity has the Mode. See also ContactSheet.
+func (s *Sheet) PreviewImage(mode int, cols int) (*image.RGBA, error) {
+	return s.PreviewImageWith(mode, cols, ExportOptions{})
+}
+
+// PreviewImageWith is like PreviewImage, but with opts; if opts.Background is set, the frames are drawn over it.
+func (s *Sheet) PreviewImageWith(mode int, cols int, opts ExportOptions) (*image.RGBA, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if cols <= 0 {
+		return nil, fmt.Errorf("column count (%d) must be > 0", cols)
+	}
+	idxs := s.sortedIndexes()
+	frames := make([]Sprite, len(idxs))
+	found := false
+	for n, idx := range idxs {
+		if m, ok := s.entities[idx].modes[mode]; ok && m.FrameCount() > 0 {
+			frames[n] = m.frameAt(0)
+			found = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("mode with index %d does not exist in any Entity", mode)
+	}
+	return previewGrid(frames, nil, cols, 0, opts.Background), nil
+}
+
+// PreviewGIF writes to w a looping animated GIF of the Mode with index mode of every Entity, arranged in index order in
+// a (roughly square) grid as by PreviewImage, and animated in lockstep: GIF frame k shows frame k of each Entity's Mode,
+// with shorter Modes wrapping around, so there are as many GIF frames as the longest Mode has frames. Entities without
+// the Mode are left blank. delay is the time each frame is shown, in 100ths of a second.
+// Colors are reduced to the web-safe palette (plus full transparency), so the GIF is meant for previewing only.
+func (s *Sheet) PreviewGIF(w io.Writer, mode, delay int) error {
+	return s.PreviewGIFWith(w, mode, delay, ExportOptions{})
+}
+
+// PreviewGIFWith is like PreviewGIF, but with opts; if opts.Background is set, every GIF frame is drawn over it (and
+// reduced to the palette with the frames).
+func (s *Sheet) PreviewGIFWith(w io.Writer, mode, delay int, opts ExportOptions) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if delay < 0 {
+		return fmt.Errorf("delay (%d) must be >= 0", delay)
+	}
+	idxs := s.sortedIndexes()
+	modes := make([]*Mode, len(idxs))
+	frameCount := 0
+	for n, idx := range idxs {
+		if m, ok := s.entities[idx].modes[mode]; ok && m.FrameCount() > 0 {
+			modes[n] = m
+			if m.FrameCount() > frameCount {
+				frameCount = m.FrameCount()
+			}
+		}
+	}
+	if frameCount == 0 {
+		return fmt.Errorf("mode with index %d does not exist in any Entity", mode)
+	}
+	cols := int(math.Ceil(math.Sqrt(float64(len(idxs)))))
+
+	pal := append(color.Palette{color.RGBA{}}, palette.WebSafe...)
+	anim := &gif.GIF{}
+	frames := make([]Sprite, len(modes))
+	for k := 0; k < frameCount; k++ {
+		for n, m := range modes {
+			if m != nil {
+				frames[n] = m.frameAt(k % m.FrameCount())
+			}
+		}
+		grid := previewGrid(frames, nil, cols, 0, opts.Background)
+		paletted := image.NewPaletted(grid.Rect, pal)
+		draw.Draw(paletted, paletted.Rect, grid, grid.Rect.Min, draw.Src)
+		anim.Image = append(anim.Image, paletted)
+		anim.Delay = append(anim.Delay, delay)
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// previewGrid arranges frames in a grid cols cells wide (or on a single row, if cols <= 0), as described by
+// ContactSheet. If labelHeight > 0, names holds the label for each frame. nil frames leave their cell blank. If
+// background is not nil, the grid is filled with it before the frames are drawn.
+func previewGrid(frames []Sprite, names []string, cols int, labelHeight int, background color.Color) *image.RGBA {
+	if len(frames) == 0 {
+		return image.NewRGBA(image.Rectangle{})
+	}
+	if cols <= 0 || cols > len(frames) {
+		cols = len(frames)
+	}
+	if labelHeight < 0 {
+		labelHeight = 0
+	}
+
+	var cellW, spriteH int
+	for _, frame := range frames {
+		if frame == nil {
+			continue
+		}
+		if frame.Bounds().Dx() > cellW {
+			cellW = frame.Bounds().Dx()
+		}
+		if frame.Bounds().Dy() > spriteH {
+			spriteH = frame.Bounds().Dy()
+		}
+	}
+	cellH := spriteH + labelHeight
+	rows := (len(frames) + cols - 1) / cols
+	sheet := newCanvas(image.Rect(0, 0, cols*cellW, rows*cellH), background)
+
+	for n, frame := range frames {
+		cell := image.Rect(0, 0, cellW, cellH).Add(image.Point{X: (n % cols) * cellW, Y: (n / cols) * cellH})
+		if frame != nil {
+			at := cell.Min.Add(image.Point{X: (cellW - frame.Bounds().Dx()) / 2, Y: (spriteH - frame.Bounds().Dy()) / 2})
+			draw.Draw(sheet, frame.Bounds().Sub(frame.Bounds().Min).Add(at), frame, frame.Bounds().Min, draw.Over)
+		}
+		if labelHeight > 0 {
+			label := image.Rect(cell.Min.X, cell.Min.Y+spriteH, cell.Max.X, cell.Max.Y)
+			drawLabel(sheet.SubImage(label).(*image.RGBA), names[n])
+		}
+	}
+
+	return sheet
+}
+
+// drawLabel draws text in black, centered on dst (and clipped to its bounds), using a small fixed-size font.
+func drawLabel(dst draw.Image, text string) {
+	face := basicfont.Face7x13
+	d := &font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(color.Black),
+		Face: face,
+	}
+	b := dst.Bounds()
+	x := b.Min.X + (b.Dx()-d.MeasureString(text).Round())/2
+	if x < b.Min.X {
+		x = b.Min.X
+	}
+	y := b.Min.Y + (b.Dy()+face.Metrics().Ascent.Round()-face.Metrics().Descent.Round())/2
+	d.Dot = fixed.P(x, y)
+	d.DrawString(text)
+}
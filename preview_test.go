@@ -0,0 +1,134 @@
+package sprites
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+func TestContactSheet(t *testing.T) {
+	s := mustSheet(t)
+	d := basicDims()
+	contact := s.ContactSheet(3, 14)
+	if size := contact.Bounds().Size(); size != image.Pt(3*4, 2*(4+14)) {
+		t.Fatalf("size %dx%d, want 12x36", size.X, size.Y)
+	}
+	// Each cell shows the first frame of its Entity's first Mode, in index order.
+	for n := 0; n < 4; n++ {
+		x, y := (n%3)*4, (n/3)*(4+14)
+		want := cellColor((n%d.EntitiesPerRow)*d.ModesPerEntity, (n/d.EntitiesPerRow)*d.FramesPerAnimation)
+		assertColor(t, contact, x, y, want)
+	}
+	// The label strip beneath the first cell has the Entity's name drawn in it.
+	labelled := false
+	for y := 4; y < 4+14; y++ {
+		for x := 0; x < 4; x++ {
+			if contact.RGBAAt(x, y).A != 0 {
+				labelled = true
+			}
+		}
+	}
+	if !labelled {
+		t.Fatal("no label drawn")
+	}
+
+	if size := s.ContactSheet(0, 0).Bounds().Size(); size != image.Pt(4*4, 4) {
+		t.Fatalf("single row size %dx%d, want 16x4", size.X, size.Y)
+	}
+}
+
+func TestPreviewImage(t *testing.T) {
+	s := mustSheet(t)
+	d := basicDims()
+	// Entity 2 lacks Mode 1, so its cell is left blank.
+	if err := mustEntity(t, s, 2).SetModeCount(1); err != nil {
+		t.Fatal(err)
+	}
+	preview, err := s.PreviewImage(1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := preview.Bounds().Size(); size != image.Pt(12, 8) {
+		t.Fatalf("size %dx%d, want 12x8", size.X, size.Y)
+	}
+	for n := 0; n < 4; n++ {
+		x, y := (n%3)*4, (n/3)*4
+		want := cellColor((n%d.EntitiesPerRow)*d.ModesPerEntity+1, (n/d.EntitiesPerRow)*d.FramesPerAnimation)
+		if n == 2 {
+			want = color.RGBA{}
+		}
+		assertColor(t, preview, x, y, want)
+	}
+
+	if _, err := s.PreviewImage(7, 3); err == nil {
+		t.Fatal("preview of a Mode no Entity has succeeded")
+	}
+	if _, err := s.PreviewImage(1, 0); err == nil {
+		t.Fatal("preview 0 columns wide succeeded")
+	}
+}
+
+func TestPreviewGIF(t *testing.T) {
+	s := mustSheet(t)
+	d := basicDims()
+	// Entity 2's Mode 1 is shorter, alternating blue and red, so it wraps around.
+	blue, red := color.RGBA{B: 255, A: 255}, color.RGBA{R: 255, A: 255}
+	short := mustMode(t, mustEntity(t, s, 2), 1)
+	if err := short.InsertFrame(0, filledImage(4, 4, blue)); err != nil {
+		t.Fatal(err)
+	}
+	if err := short.InsertFrame(1, filledImage(4, 4, red)); err != nil {
+		t.Fatal(err)
+	}
+	if err := short.SetFrameCount(2); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := s.PreviewGIF(&buf, 1, 10); err != nil {
+		t.Fatal(err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Image) != 4 || anim.LoopCount != 0 {
+		t.Fatalf("%d GIF frames with loop count %d, want 4 looping forever", len(anim.Image), anim.LoopCount)
+	}
+	for k, img := range anim.Image {
+		if img.Bounds() != image.Rect(0, 0, 8, 8) {
+			t.Fatalf("GIF frame %d bounds %v, want a 2x2 grid of 4x4 cells", k, img.Bounds())
+		}
+		if anim.Delay[k] != 10 {
+			t.Fatalf("GIF frame %d delay %d, want 10", k, anim.Delay[k])
+		}
+		for _, n := range []int{0, 1, 3} {
+			want := cellColor((n%d.EntitiesPerRow)*d.ModesPerEntity+1, (n/d.EntitiesPerRow)*d.FramesPerAnimation+k)
+			assertColor(t, img, (n%2)*4, (n/2)*4, img.Palette.Convert(want))
+		}
+		if k%2 == 0 {
+			assertColor(t, img, 0, 4, blue)
+		} else {
+			assertColor(t, img, 0, 4, red)
+		}
+	}
+
+	if err := s.PreviewGIF(&buf, 9, 10); err == nil {
+		t.Fatal("preview of a Mode no Entity has succeeded")
+	}
+}
+
+func TestPreviewGridCentersSmallerFrames(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	large := filledImage(4, 4, color.RGBA{B: 255, A: 255})
+	small := filledImage(2, 2, red)
+	grid := previewGrid([]Sprite{large, small}, nil, 2, 0, nil)
+	if size := grid.Bounds().Size(); size != image.Pt(8, 4) {
+		t.Fatalf("size %dx%d, want 8x4", size.X, size.Y)
+	}
+	assertColor(t, grid, 4, 0, color.Transparent)
+	assertColor(t, grid, 5, 1, red)
+	assertColor(t, grid, 6, 2, red)
+	assertColor(t, grid, 7, 3, color.Transparent)
+}
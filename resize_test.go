@@ -0,0 +1,160 @@
+package sprites
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestFrameResizedCache(t *testing.T) {
+	e := mustEntity(t, mustSheet(t), 0)
+	a, b := mustInstance(t, e, 0), mustInstance(t, e, 0)
+	resized := a.FrameResized(8, 8)
+	if size := resized.Bounds().Size(); size.X != 8 || size.Y != 8 {
+		t.Fatalf("resized to %dx%d, want 8x8", size.X, size.Y)
+	}
+	// The Mode's cache is shared by its Instances.
+	if b.FrameResized(8, 8) != resized {
+		t.Fatal("repeated request not served from the cache")
+	}
+	if b.FrameResized(12, 12) == resized {
+		t.Fatal("request for a different size served from the cache")
+	}
+
+	a.Mode.ClearResizeCache()
+	if cleared := b.FrameResized(8, 8); cleared == resized {
+		t.Fatal("cache not cleared")
+	} else {
+		resized = cleared
+	}
+	if err := a.Mode.SetFrameCount(2); err != nil {
+		t.Fatal(err)
+	}
+	if b.FrameResized(8, 8) == resized {
+		t.Fatal("cache not invalidated when the frames changed")
+	}
+}
+
+func BenchmarkFrameResizedCached(b *testing.B) {
+	i := mustInstance(b, mustEntity(b, mustSheet(b), 0), 0)
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		i.FrameResized(32, 32)
+	}
+}
+
+// BenchmarkFrameResizedUncached resizes the same frame as BenchmarkFrameResizedCached, without the cache.
+func BenchmarkFrameResizedUncached(b *testing.B) {
+	i := mustInstance(b, mustEntity(b, mustSheet(b), 0), 0)
+	frame := i.CurrentFrame()
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		resizeSprite(frame, 32, 32, ResizeAuto)
+	}
+}
+
+func TestFrameResizedSameSize(t *testing.T) {
+	i := mustInstance(t, mustEntity(t, mustSheet(t), 0), 0)
+	if i.FrameResized(4, 4) != i.CurrentFrame() {
+		t.Fatal("resizing to the frame's own size did not return the frame")
+	}
+	if allocs := testing.AllocsPerRun(100, func() { i.FrameResized(4, 4) }); allocs != 0 {
+		t.Fatalf("resizing to the frame's own size made %g allocations, want 0", allocs)
+	}
+}
+
+// BenchmarkFrameResizedSameSize resizes frames to their own size, which should not allocate.
+func BenchmarkFrameResizedSameSize(b *testing.B) {
+	i := mustInstance(b, mustEntity(b, mustSheet(b), 0), 0)
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		i.FrameResized(4, 4)
+	}
+}
+
+func TestNearestNeighborUpscaleIsExact(t *testing.T) {
+	white, black := color.RGBA{R: 255, G: 255, B: 255, A: 255}, color.RGBA{A: 255}
+	checkerboard := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	checkerboard.Set(0, 0, white)
+	checkerboard.Set(1, 1, white)
+	checkerboard.Set(1, 0, black)
+	checkerboard.Set(0, 1, black)
+
+	for _, filter := range []ResizeFilter{ResizeNearestNeighbor, ResizeAuto} {
+		out := toRGBA(resizeSprite(checkerboard, 6, 6, filter))
+		for y := 0; y < 6; y++ {
+			for x := 0; x < 6; x++ {
+				want := checkerboard.RGBAAt(x/3, y/3)
+				if got := out.RGBAAt(out.Rect.Min.X+x, out.Rect.Min.Y+y); got != want {
+					t.Fatalf("filter %d: pixel (%d,%d) = %v, want %v", filter, x, y, got, want)
+				}
+			}
+		}
+	}
+
+	// Bilinear filtering blends neighboring pixels.
+	out := toRGBA(resizeSprite(checkerboard, 6, 6, ResizeBilinear))
+	if c := out.RGBAAt(out.Rect.Min.X+2, out.Rect.Min.Y+2); c == white || c == black {
+		t.Fatalf("bilinear pixel (2,2) = %v, want a blend", c)
+	}
+}
+
+func TestSheetDimensionsResizeFilter(t *testing.T) {
+	d := basicDims()
+	d.ResizeWidth, d.ResizeHeight, d.ResizeFilter = 12, 12, ResizeBilinear
+	s, err := NewSheet(testSheetImage(basicDims()), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := mustMode(t, mustEntity(t, s, 0), 0)
+	if m.resizeFilter != ResizeBilinear || m.SpriteSize().Dx() != 12 {
+		t.Fatalf("Mode has filter %d and width %d, want %d and 12", m.resizeFilter, m.SpriteSize().Dx(), ResizeBilinear)
+	}
+}
+
+func TestFrameResizedWith(t *testing.T) {
+	// A horizontal gradient of red 0, 80, 160, 240.
+	img := image.NewRGBA(image.Rect(0, 0, 4, 1))
+	for x := 0; x < 4; x++ {
+		img.SetRGBA(x, 0, color.RGBA{R: uint8(80 * x), A: 255})
+	}
+	e := singleSpriteEntity(t, img)
+	red := func(filter ResizeFilter, w uint) []uint8 {
+		out := toRGBA(mustInstance(t, e, 0).FrameResizedWith(w, 1, filter))
+		if out.Rect.Dx() != int(w) || out.Rect.Dy() != 1 {
+			t.Fatalf("filter %d: resized to %v, want %dx1", filter, out.Rect, w)
+		}
+		reds := make([]uint8, w)
+		for x := range reds {
+			reds[x] = out.RGBAAt(out.Rect.Min.X+x, out.Rect.Min.Y).R
+		}
+		return reds
+	}
+
+	// Nearest-neighbor doubles each pixel exactly.
+	nearest := red(ResizeNearestNeighbor, 8)
+	if want := []uint8{0, 0, 80, 80, 160, 160, 240, 240}; !reflect.DeepEqual(nearest, want) {
+		t.Errorf("nearest-neighbor reds are %v, want %v", nearest, want)
+	}
+	// Without a filter, FrameResized uses the Mode's (nearest-neighbor, by default).
+	if got := toRGBA(mustInstance(t, e, 0).FrameResized(8, 1)); got.RGBAAt(got.Rect.Min.X+1, got.Rect.Min.Y).R != 0 {
+		t.Error("FrameResized does not match nearest-neighbor")
+	}
+	// Bilinear is a smooth, non-decreasing ramp, including reds between the source pixels'.
+	bilinear := red(ResizeBilinear, 8)
+	blended := false
+	for x, r := range bilinear {
+		if x > 0 && r < bilinear[x-1] {
+			t.Fatalf("bilinear reds %v are not non-decreasing", bilinear)
+		}
+		blended = blended || r%80 != 0
+	}
+	if !blended || reflect.DeepEqual(bilinear, nearest) {
+		t.Errorf("bilinear reds %v are not blended", bilinear)
+	}
+	// Lanczos downscaling averages pairs of pixels.
+	if lanczos := red(ResizeLanczos, 2); lanczos[0] == 0 || lanczos[0] >= lanczos[1] || lanczos[1] == 240 {
+		t.Errorf("Lanczos reds are %v, want two blends increasing", lanczos)
+	}
+}
@@ -0,0 +1,132 @@
+package sprites
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// instanceState is the serialized form of an Instance's runtime state. See Instance.MarshalState.
+type instanceState struct {
+	Name           string   `json:"name,omitempty"`
+	Entity         string   `json:"entity"`
+	Mode           string   `json:"mode"`
+	CurrentFrame   int      `json:"currentFrame"`
+	Running        bool     `json:"running"`
+	Paused         bool     `json:"paused,omitempty"`
+	SpeedScale     float64  `json:"speedScale"`
+	Progress       float64  `json:"progress"`
+	LoopCount      int      `json:"loopCount"`
+	LoopsCompleted int      `json:"loopsCompleted"`
+	Finished       bool     `json:"finished"`
+	Random         bool     `json:"random"`
+	RandomSteps    float64  `json:"randomSteps"`
+	Queue          []string `json:"queue,omitempty"`
+}
+
+// MarshalState returns the Instance's runtime state - its name, the names of its Entity and current Mode, its current
+// frame, running (and paused) state, speed scale, progress toward the next frame, loop count and progress, random
+// playback setting, and the names of any queued Modes (see QueueModes) - as JSON, e.g. for a save game. See
+// Sheet.RestoreInstance.
+// Callbacks (OnFrame and SetOnModeChange) and the random playback source are not included.
+func (i *Instance) MarshalState() ([]byte, error) {
+	i.mu.Lock()
+	state := instanceState{
+		Name:           i.name,
+		Entity:         i.Entity.name,
+		Mode:           i.Mode.name,
+		CurrentFrame:   wrapIndex(i.currentFrame, i.FrameCount()),
+		Running:        i.running,
+		Paused:         i.paused,
+		SpeedScale:     i.speedScale,
+		Progress:       i.progress,
+		LoopCount:      i.loopCount,
+		LoopsCompleted: i.loopsCompleted,
+		Finished:       i.finished,
+		Random:         i.random,
+		RandomSteps:    i.randomSteps,
+	}
+	for _, mode := range i.queue {
+		state.Queue = append(state.Queue, mode.name)
+	}
+	i.mu.Unlock()
+	return json.Marshal(state)
+}
+
+// RestoreInstance creates a new Instance from state, as returned by Instance.MarshalState, of the Sheet's Entity with
+// the saved name, in the Mode with the saved name, and with the saved runtime state, so that it continues exactly where
+// the saved Instance was: the next frame it shows, and every frame after, is the same. Entities and Modes are
+// referenced by name, so state may be restored to a Sheet loaded again (e.g. in a later run), provided the Entity and
+// its Modes still exist and the current Mode has at least as many frames as the saved current frame requires. With
+// random playback, the Instance uses the math/rand default source (see SetRandomPlayback).
+// It is an error if the state is not one an Instance can be in (e.g. if it was edited or corrupted): a speed scale
+// which SetSpeedScale would ignore, progress of a whole frame or more, a negative loop progress, or a running state
+// which contradicts the paused or finished state.
+func (s *Sheet) RestoreInstance(state []byte) (*Instance, error) {
+	var saved instanceState
+	if err := json.Unmarshal(state, &saved); err != nil {
+		return nil, fmt.Errorf("invalid instance state: %w", err)
+	}
+	entity, err := s.GetEntityByName(saved.Entity)
+	if err != nil {
+		return nil, err
+	}
+	instance, err := entity.NewInstanceWithModeName(saved.Mode)
+	if err != nil {
+		return nil, err
+	}
+	if err := saved.validate(instance.FrameCount()); err != nil {
+		return nil, err
+	}
+	for _, name := range saved.Queue {
+		mode, err := entity.GetModeByName(name)
+		if err != nil {
+			return nil, err
+		}
+		instance.queue = append(instance.queue, mode)
+	}
+	instance.name = saved.Name
+	instance.currentFrame = saved.CurrentFrame
+	instance.running = saved.Running
+	instance.paused = saved.Paused
+	instance.speedScale = saved.SpeedScale
+	instance.progress = saved.Progress
+	instance.loopCount = saved.LoopCount
+	instance.loopsCompleted = saved.LoopsCompleted
+	instance.finished = saved.Finished
+	instance.random = saved.Random
+	instance.randomSteps = saved.RandomSteps
+	return instance, nil
+}
+
+// validate returns an error if the saved state is not one an animation can be in, given that its Mode has frameCount
+// frames. The checks mirror the invariants which the animation's methods (e.g. SetSpeedScale and SetLoopCount)
+// maintain.
+func (st *instanceState) validate(frameCount int) error {
+	if st.CurrentFrame < 0 || st.CurrentFrame >= frameCount {
+		return fmt.Errorf("saved frame index (%d) must be >= 0 and < the frame count (%d) of Mode %s",
+			st.CurrentFrame, frameCount, st.Mode)
+	}
+	if math.IsNaN(st.SpeedScale) || math.IsInf(st.SpeedScale, 0) {
+		return fmt.Errorf("saved speed scale (%v) must be finite", st.SpeedScale)
+	}
+	// Advancing keeps only the fractional part of the progress; it is negative when playing in reverse.
+	if math.IsNaN(st.Progress) || math.Abs(st.Progress) >= 1 {
+		return fmt.Errorf("saved progress (%v) must be > -1 and < 1", st.Progress)
+	}
+	if st.LoopsCompleted < 0 {
+		return fmt.Errorf("saved completed loop count (%d) must be >= 0", st.LoopsCompleted)
+	}
+	if st.Finished && (st.Running || st.Paused) {
+		return errors.New("saved state must not be both finished and running or paused")
+	}
+	if st.Running && st.Paused {
+		return errors.New("saved state must not be both running and paused")
+	}
+	if math.IsNaN(st.RandomSteps) || st.RandomSteps < 0 || st.RandomSteps >= float64(frameCount) {
+		return fmt.Errorf("saved random playback progress (%v) must be >= 0 and < the frame count (%d) of Mode %s",
+			st.RandomSteps, frameCount, st.Mode)
+	}
+	return nil
+}
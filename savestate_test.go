@@ -0,0 +1,131 @@
+package sprites
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// assertSamePlayback advances a and b together n times, failing t if they ever show different frames, are in
+// differently named Modes, or differ in their finished state.
+func assertSamePlayback(t *testing.T, a, b *Instance, n int) {
+	t.Helper()
+	for k := 0; k < n; k++ {
+		aIndex, aMode := a.frameIndexAndMode()
+		bIndex, bMode := b.frameIndexAndMode()
+		if aIndex != bIndex || aMode.Name() != bMode.Name() || a.Finished() != b.Finished() {
+			t.Fatalf("advance %d: frame %d of %s (finished %v), want frame %d of %s (finished %v)",
+				k, bIndex, bMode.Name(), b.Finished(), aIndex, aMode.Name(), a.Finished())
+		}
+	}
+}
+
+func TestMarshalStateRoundTrip(t *testing.T) {
+	for _, speed := range []float64{1, 0.75, -0.4, 2.5} {
+		i := mustInstance(t, mustEntity(t, mustSheet(t), 2), 1)
+		i.SetName("hero")
+		i.SetSpeedScale(speed)
+		i.SetLoopCount(3)
+		i.StartAnimation()
+		for k := 0; k < 5; k++ {
+			i.Advance()
+		}
+		if err := i.QueueModes(DefaultNameFormatter("mode", 0), DefaultNameFormatter("mode", 2)); err != nil {
+			t.Fatal(err)
+		}
+		i.Advance()
+
+		state, err := i.MarshalState()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Restore to a Sheet loaded again, as in a later run.
+		restored, err := mustSheet(t).RestoreInstance(state)
+		if err != nil {
+			t.Fatalf("speed %v: %v", speed, err)
+		}
+		if restored.Name() != "hero" || restored.SpeedScale() != speed || restored.State() != i.State() {
+			t.Fatalf("speed %v: restored %q at speed %v, %v", speed, restored.Name(), restored.SpeedScale(), restored.State())
+		}
+		assertSamePlayback(t, i, restored, 40)
+	}
+}
+
+func TestMarshalStatePaused(t *testing.T) {
+	i := mustInstance(t, mustEntity(t, mustSheet(t), 0), 0)
+	i.SetSpeedScale(0.5)
+	i.StartAnimation()
+	i.Advance()
+	i.PauseAnimation()
+	state, err := i.MarshalState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := mustSheet(t).RestoreInstance(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.State() != Paused {
+		t.Fatalf("restored State() = %v, want %v", restored.State(), Paused)
+	}
+	i.ResumeAnimation()
+	restored.ResumeAnimation()
+	assertSamePlayback(t, i, restored, 10)
+}
+
+func TestRestoreInstanceInvalid(t *testing.T) {
+	s := mustSheet(t)
+	i := mustInstance(t, mustEntity(t, s, 0), 0)
+	i.StartAnimation()
+	state, err := i.MarshalState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := func() map[string]interface{} {
+		var fields map[string]interface{}
+		if err := json.Unmarshal(state, &fields); err != nil {
+			t.Fatal(err)
+		}
+		return fields
+	}
+	tests := []struct {
+		name string
+		edit func(fields map[string]interface{})
+	}{
+		{"unknown entity", func(f map[string]interface{}) { f["entity"] = "nobody" }},
+		{"frame past the end", func(f map[string]interface{}) { f["currentFrame"] = 4 }},
+		{"negative frame", func(f map[string]interface{}) { f["currentFrame"] = -1 }},
+		{"whole frame of progress", func(f map[string]interface{}) { f["progress"] = 1 }},
+		{"whole frame of reverse progress", func(f map[string]interface{}) { f["progress"] = -1.5 }},
+		{"negative loops", func(f map[string]interface{}) { f["loopsCompleted"] = -1 }},
+		{"finished and running", func(f map[string]interface{}) {
+			f["loopCount"], f["loopsCompleted"], f["finished"] = 1, 1, true
+		}},
+		{"finished and paused", func(f map[string]interface{}) {
+			f["running"], f["paused"], f["finished"] = false, true, true
+		}},
+		{"running and paused", func(f map[string]interface{}) { f["paused"] = true }},
+		{"random progress past the end", func(f map[string]interface{}) { f["randomSteps"] = 4 }},
+	}
+	for _, test := range tests {
+		fields := valid()
+		test.edit(fields)
+		edited, err := json.Marshal(fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.RestoreInstance(edited); err == nil {
+			t.Errorf("%s: restored", test.name)
+		}
+	}
+	if _, err := s.RestoreInstance([]byte("{")); err == nil {
+		t.Error("malformed JSON restored")
+	}
+	// JSON cannot encode NaN, but a corrupted speed scale may still decode to an infinite one.
+	fields := valid()
+	delete(fields, "speedScale")
+	edited, _ := json.Marshal(fields)
+	edited = append(edited[:len(edited)-1], []byte(`,"speedScale":1e999}`)...)
+	if _, err := s.RestoreInstance(edited); err == nil {
+		t.Error("infinite speed scale restored")
+	}
+}
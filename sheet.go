@@ -1,11 +1,21 @@
 package sprites
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"image"
+	"image/color"
 	"image/draw"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 
 	ccsl_graphics "github.com/HaileyStorm/CCSL_go/graphics"
 )
@@ -54,6 +64,61 @@ type SheetDimensions struct {
 	// ResizeWidth are != SpriteHeight/SpriteWidth, each Sprite is resized and saved in the Sheet accordingly.
 	// The aspect ratios of the original and the resized Sprites must match (SpriteWidth/SpriteHeight=ResizeWidth/ResizeHeight).
 	ResizeHeight int
+	// Margin is the number of pixels (on each side, on both axes) between the edge of the sheet image and the
+	// outermost Sprites. It is OPTIONAL (default 0).
+	Margin int
+	// Spacing is the number of pixels (on both axes) between adjacent Sprites, including between Sprites belonging to
+	// different Entities. It is OPTIONAL (default 0).
+	// With Margin and Spacing, the sheet image must be 2*Margin + nCols*SpriteWidth + (nCols-1)*Spacing pixels wide
+	// (where nCols is the number of Sprite columns, EntitiesPerRow * ModesPerEntity, or EntitiesPerRow *
+	// FramesPerAnimation if FramesRunRows), and likewise for its height.
+	// When resizing, Margin and Spacing are scaled by the same ratio as the Sprites, and must scale to whole pixels.
+	Spacing int
+
+	// OriginX and OriginY offset the start of the sprite grid (including any Margin) from the upper-left of the sheet
+	// image (its Bounds().Min). They are OPTIONAL (default 0). When either is set, the sheet image need not match the
+	// grid size exactly, only contain it; the region of the image starting at the origin and the size of the grid is
+	// used, so a sheet may be a sub-region of a larger image (e.g. following a header strip).
+	OriginX int
+	OriginY int
+
+	// ColorKey is OPTIONAL. If set, every pixel in the sheet image exactly matching it (all of R, G, B and A; e.g.
+	// color.RGBA{R: 255, B: 255, A: 255} for magenta) is made fully transparent when the Sheet is created, for sheets
+	// which encode transparency with a key color rather than an alpha channel. The source image is not modified.
+	ColorKey *color.RGBA
+
+	// ResizeFilter is the interpolation algorithm used when resizing Sprites, both for ResizeWidth/ResizeHeight and
+	// later by Instance.FrameResized. It is OPTIONAL; the default (ResizeAuto) is nearest-neighbor.
+	// Note the whole sheet image is resized at once, so filters other than nearest-neighbor may blend pixels across
+	// adjacent Sprites at their edges.
+	ResizeFilter ResizeFilter
+
+	// NormalizeOrigins is OPTIONAL. By default, each frame is a SubImage view into the (shared) sheet image, so its
+	// Bounds().Min is its position on the sheet. If NormalizeOrigins is set, each frame is instead stored as its own
+	// copy, with Bounds().Min at (0,0). This uses more memory, but frames may then be handled as independent images.
+	NormalizeOrigins bool
+
+	// Lazy is OPTIONAL. By default, every frame is extracted from the sheet image (and copied, if NormalizeOrigins is
+	// set), and its pixels scanned to determine which Modes are fully opaque (see Mode.FullyOpaque), when the Sheet is
+	// created. If Lazy is set, each Mode's frames are instead extracted and scanned when they are first needed (e.g. the
+	// first time one of its frames is placed), which speeds up loading large sheets of which only a few Entities are
+	// used. Counting frames does not extract them, but operations on a whole Mode or Sheet (e.g. cloning, hashing or
+	// exporting it) do.
+	Lazy bool
+
+	// NameFormatter is OPTIONAL. It generates the names of Entities (kind "entity") and Modes (kind "mode") from their
+	// index, for the factories which are not given names for them (e.g. NewSheet, and for Modes,
+	// NewSheetWithEntityNames). If it is nil, DefaultNameFormatter is used. The names it generates for each kind must
+	// be unique; if they are not, the factory returns an error.
+	NameFormatter func(kind string, index int) string
+
+	// TrustSubImager is OPTIONAL. By default, a sheet image which is not an *image.RGBA is first copied, in full, into
+	// one. If TrustSubImager is set, the image is used as is, and each frame is taken directly from its SubImage
+	// method, e.g. for an enormous sheet whose SubImager decodes regions on demand: only the cells actually loaded are
+	// ever read. SubImage should return an *image.RGBA; any frame which is not one is copied into one (individually).
+	// SubImage is called concurrently, for each frame, while the Sheet is created. TrustSubImager cannot be combined
+	// with ColorKey or resizing (ResizeWidth and ResizeHeight), which require the whole image.
+	TrustSubImager bool
 }
 
 // EntityAndModeNames contains the name for an Entity and the names for each of its Modes. It is used in the Sheet
@@ -64,6 +129,42 @@ type EntityAndModeNames struct {
 	EntityName string
 	// ModeNames is a slice of names for each of the Entity's Modes.
 	ModeNames []string
+	// FrameCounts is OPTIONAL. If provided, it must be the same length as ModeNames, and gives the number of frames
+	// to read for each Mode (each count must be > 0 and <= SheetDimensions.FramesPerAnimation); the remaining frames
+	// in the sheet are not loaded. If nil, every Mode has FramesPerAnimation frames.
+	FrameCounts []int
+	// ModeIndices is OPTIONAL. If provided, it must be the same length as ModeNames, and gives the Mode (column or row,
+	// per SheetDimensions.FramesRunRows) of the Entity in the sheet to read for each Mode (each index must be >= 0 and
+	// < SheetDimensions.ModesPerEntity, and unique), so Modes in the sheet may be skipped or read out of order. The
+	// Modes are still indexed in the Entity by their position in ModeNames. If nil, the Modes are read in order from
+	// the first.
+	ModeIndices []int
+}
+
+// InferDimensions returns the SheetDimensions of a sheet image with no margin or spacing, given the size of its Sprites,
+// the number of Modes each Entity has and frames each Mode has, and their orientation (see SheetDimensions), by
+// deriving EntitiesPerRow and EntitiesPerColumn from the image's size. It returns an error if the image is not a whole
+// number of Entities wide and high. The other (OPTIONAL) fields of the result may be set before using it.
+func InferDimensions(img ccsl_graphics.SubImager, spriteW, spriteH, modesPerEntity, framesPerAnimation int, framesRunRows bool) (SheetDimensions, error) {
+	dimensions := SheetDimensions{
+		ModesPerEntity:     modesPerEntity,
+		FramesPerAnimation: framesPerAnimation,
+		FramesRunRows:      framesRunRows,
+		SpriteWidth:        spriteW,
+		SpriteHeight:       spriteH,
+	}
+	if spriteW <= 0 || spriteH <= 0 || modesPerEntity <= 0 || framesPerAnimation <= 0 {
+		return SheetDimensions{}, errors.New("sprite size, modes per entity and frames per animation must be > 0")
+	}
+	dimensions.init()
+	entityW, entityH := dimensions.numEntityColumns*spriteW, dimensions.numEntityRows*spriteH
+	size := img.Bounds().Size()
+	if size.X == 0 || size.X%entityW != 0 || size.Y == 0 || size.Y%entityH != 0 {
+		return SheetDimensions{}, fmt.Errorf("image size (%dx%d) is not a whole number of entities of size (%dx%d)", size.X, size.Y, entityW, entityH)
+	}
+	dimensions.EntitiesPerRow = size.X / entityW
+	dimensions.EntitiesPerColumn = size.Y / entityH
+	return dimensions, nil
 }
 
 // init takes the provided SheetDimensions and assigns the non-exported fields which are used during Sheet creation to
@@ -79,6 +180,47 @@ func (d *SheetDimensions) init() {
 	}
 }
 
+// imageSize returns the size the sheet image must be, given the dimensions. init must have been called.
+func (d *SheetDimensions) imageSize() image.Point {
+	cols := d.EntitiesPerRow * d.numEntityColumns
+	rows := d.EntitiesPerColumn * d.numEntityRows
+	return image.Point{
+		X: 2*d.Margin + cols*d.SpriteWidth + (cols-1)*d.Spacing,
+		Y: 2*d.Margin + rows*d.SpriteHeight + (rows-1)*d.Spacing,
+	}
+}
+
+// cellOrigin returns the upper-left corner, relative to the sheet image's Bounds().Min, of the Sprite at column dx
+// and row dy within the Entity at entityIndex. init must have been called.
+func (d *SheetDimensions) cellOrigin(entityIndex, dx, dy int) image.Point {
+	col := (entityIndex%d.EntitiesPerRow)*d.numEntityColumns + dx
+	row := (entityIndex/d.EntitiesPerRow)*d.numEntityRows + dy
+	return image.Point{
+		X: d.Margin + col*(d.SpriteWidth+d.Spacing),
+		Y: d.Margin + row*(d.SpriteHeight+d.Spacing),
+	}
+}
+
+// cellRect returns the rectangle, relative to the sheet image's Bounds().Min, of frame frameIndex of Mode modeIndex of
+// the Entity at entityIndex. init must have been called.
+func (d *SheetDimensions) cellRect(entityIndex, modeIndex, frameIndex int) image.Rectangle {
+	dx, dy := modeIndex, frameIndex
+	if d.FramesRunRows {
+		dx, dy = frameIndex, modeIndex
+	}
+	return image.Rect(0, 0, d.SpriteWidth, d.SpriteHeight).Add(d.cellOrigin(entityIndex, dx, dy))
+}
+
+// CellRect returns the rectangle of the sprite sheet image, relative to its Bounds().Min, holding frame frameIndex of
+// Mode modeIndex of the Entity at entityIndex, as laid out by dimensions (taking FramesRunRows, Margin, Spacing, OriginX
+// and OriginY into account) - the rectangle the Sheet factories read that frame from (before any resizing). It lets
+// sheets be sliced in other ways than into a Sheet, e.g. for a custom atlas. The indexes are not checked against
+// dimensions.
+func CellRect(dimensions SheetDimensions, entityIndex, modeIndex, frameIndex int) image.Rectangle {
+	dimensions.init()
+	return dimensions.cellRect(entityIndex, modeIndex, frameIndex).Add(image.Point{X: dimensions.OriginX, Y: dimensions.OriginY})
+}
+
 // Sheet holds the Entities of the sheets, along with an Entity name lookup map. An Entity is a unit of Sprites (such
 // as a character), and it has Modes which are different states or views (such as direction character is walking), and
 // each Mode has a slice of Sprite (image) Frames comprising its animation.
@@ -87,12 +229,26 @@ func (d *SheetDimensions) init() {
 // and an underlying animation which is used by the Instance to control what its current frame is;
 // said current frame may be requested directly, or Instance.PlaceOn may be used to place the current frame on a
 // provided image.
+// A Sheet is safe for concurrent use, in that its Entities may be looked up (e.g. by GetEntityByName, to create
+// Instances) while Entities are renamed, removed, etc. by other goroutines. Modifying an Entity or Mode itself (e.g.
+// Entity.RenameMode, Mode.InsertFrame) is not guarded, and must not be done concurrently with other use of it. An
+// Entity removed from the Sheet remains usable by those holding it (such as its Instances), but is no longer part of
+// the Sheet, so e.g. renaming it through the Sheet will fail.
 type Sheet struct {
-	// entities is a map of index->GetEntity (pointer). Index is the position on the Sheet, which starts at upper-left and
+	// mu guards entities and entityNamesToIndex. It is not held while Instances are drawn, which use their Entity
+	// directly.
+	mu sync.RWMutex
+	// entities is a map of index->Entity (pointer). Index is the position on the Sheet, which starts at upper-left and
 	// wraps back to the left at the end of a row of Entities.
 	entities map[int]*Entity
 	// entityNamesToIndex is a map of Entity.name -> index, where index is a key in entities.
 	entityNamesToIndex map[string]int
+	// dimensions is the layout of the sprite grid the Sheet was created from, after resizing, or the zero value if it
+	// was not created from a grid (e.g. by NewSheetFromAseprite or SubSheet).
+	dimensions SheetDimensions
+	// tracker records the Instances of the Sheet's Entities, or is nil if it is not tracking them. See
+	// TrackInstances.
+	tracker *instanceTracker
 }
 
 // NewSheet is a basic factory to create a new Sheet from a sprite sheet image and SheetDimensions info about how it is
@@ -109,10 +265,14 @@ func NewSheet(img ccsl_graphics.SubImager, dimensions SheetDimensions) (*Sheet,
 
 	newSheet := new(Sheet)
 
-	modeNames := generateModeNames(dimensions.ModesPerEntity)
+	modeNames := dimensions.generateNames("mode", dimensions.ModesPerEntity)
 	var names []EntityAndModeNames
-	for i := 0; i < dimensions.EntitiesPerRow*dimensions.EntitiesPerColumn; i++ {
-		names = append(names, EntityAndModeNames{"GetEntity" + strconv.Itoa(i), modeNames})
+	for _, entityName := range dimensions.generateNames("entity", dimensions.EntitiesPerRow*dimensions.EntitiesPerColumn) {
+		names = append(names, EntityAndModeNames{EntityName: entityName, ModeNames: modeNames})
+	}
+	// The names come from the caller's NameFormatter, so may not be unique.
+	if err := checkNames(dimensions, names); err != nil {
+		return nil, err
 	}
 
 	newSheet.generateEntities(spriteSheet, dimensions, names)
@@ -121,9 +281,9 @@ func NewSheet(img ccsl_graphics.SubImager, dimensions SheetDimensions) (*Sheet,
 }
 
 // note that len(names) defines the number of populated/used entities
-//describe entity index order in docstring
+// Entities are indexed, and so named, row-major from the upper-left of the sheet image: see Sheet.IndexAt.
 func NewSheetWithEntityNames(img ccsl_graphics.SubImager, dimensions SheetDimensions, entityNames []string) (*Sheet, error) {
-	modeNames := generateModeNames(dimensions.ModesPerEntity)
+	modeNames := dimensions.generateNames("mode", dimensions.ModesPerEntity)
 
 	return NewSheetWithEntityAndSharedModeNames(img, dimensions, entityNames, modeNames)
 }
@@ -141,20 +301,23 @@ func NewSheetWithEntityAndSharedModeNames(img ccsl_graphics.SubImager, dimension
 		return nil, err
 	}
 
-	newSheet := new(Sheet)
-
 	var names []EntityAndModeNames
 	for _, entityName := range entityNames {
-		names = append(names, EntityAndModeNames{entityName, modeNames})
+		names = append(names, EntityAndModeNames{EntityName: entityName, ModeNames: modeNames})
+	}
+	if err := checkNames(dimensions, names); err != nil {
+		return nil, err
 	}
 
+	newSheet := new(Sheet)
 	newSheet.generateEntities(spriteSheet, dimensions, names)
 
 	return newSheet, nil
 }
 
 //note that len(names) defines the number of populated/used Entities, and len of each key defines the number of populate/used modes for the given Entity
-//describe entity and mode index order in docstring
+// Entities are indexed, and so named, row-major from the upper-left of the sheet image (see Sheet.IndexAt), and each
+// Entity's Modes left to right (or, if FramesRunRows, top to bottom) within it.
 func NewSheetWithNames(img ccsl_graphics.SubImager, dimensions SheetDimensions, names []EntityAndModeNames) (*Sheet, error) {
 	if len(names) > dimensions.EntitiesPerRow*dimensions.EntitiesPerColumn {
 		return nil, fmt.Errorf("length of names (%d) is greater than number of Entities in Sheet, i.e. EntitiesPerRow * EntitiesPerColumn (%d)",
@@ -167,21 +330,15 @@ func NewSheetWithNames(img ccsl_graphics.SubImager, dimensions SheetDimensions,
 		return nil, err
 	}
 
-	newSheet := new(Sheet)
-
-	// In this case the panic from generateEntities is due to a value in names being the wrong length, which is an issue
-	// created by the caller, not this package. So we recover from it and pass it along as an error instead.
-	defer func() {
-		if r := recover(); r != nil {
-			err = fmt.Errorf("names has more keys (%d) than spriteSheet has Entities (%d)",
-				len(names), dimensions.EntitiesPerRow*dimensions.EntitiesPerColumn)
-		}
-	}()
-	newSheet.generateEntities(spriteSheet, dimensions, names)
-	if err != nil {
+	// The values in names are supplied by the caller, so they are checked here and any problem returned as an error,
+	// rather than letting generateEntities panic.
+	if err := checkNames(dimensions, names); err != nil {
 		return nil, err
 	}
 
+	newSheet := new(Sheet)
+	newSheet.generateEntities(spriteSheet, dimensions, names)
+
 	return newSheet, nil
 }
 
@@ -190,13 +347,33 @@ func createSpriteSheet(spriteSheet ccsl_graphics.SubImager, dimensions *SheetDim
 		dimensions.FramesPerAnimation <= 0 || dimensions.SpriteWidth <= 0 || dimensions.SpriteHeight <= 0 {
 		return nil, errors.New("all SheetDimensions fields must be > 0")
 	}
-	if spriteSheet.Bounds().Dx() != dimensions.EntitiesPerRow*dimensions.numEntityColumns*dimensions.SpriteWidth {
-		return nil, fmt.Errorf("image width (%d) is not EntitiesPerRow * #cols/GetEntity * SpriteWidth (%d)",
-			spriteSheet.Bounds().Dx(), dimensions.EntitiesPerRow*dimensions.numEntityColumns*dimensions.SpriteWidth)
+	if dimensions.Margin < 0 || dimensions.Spacing < 0 {
+		return nil, errors.New("SheetDimensions Margin and Spacing must be >= 0")
+	}
+	size := dimensions.imageSize()
+	if dimensions.OriginX != 0 || dimensions.OriginY != 0 {
+		if dimensions.OriginX < 0 || dimensions.OriginY < 0 {
+			return nil, errors.New("SheetDimensions OriginX and OriginY must be >= 0")
+		}
+		grid := image.Rectangle{Max: size}.Add(spriteSheet.Bounds().Min).Add(image.Point{X: dimensions.OriginX, Y: dimensions.OriginY})
+		if !grid.In(spriteSheet.Bounds()) {
+			return nil, fmt.Errorf("sprite grid at origin (%d,%d) (%v) does not fit within image bounds (%v)",
+				dimensions.OriginX, dimensions.OriginY, grid, spriteSheet.Bounds())
+		}
+		var ok bool
+		if spriteSheet, ok = spriteSheet.SubImage(grid).(ccsl_graphics.SubImager); !ok {
+			return nil, fmt.Errorf("image type %T does not return a SubImager from SubImage", spriteSheet)
+		}
+	}
+	if spriteSheet.Bounds().Size() != size {
+		return nil, &ErrSheetSizeMismatch{Got: spriteSheet.Bounds().Size(), Want: size}
 	}
-	if spriteSheet.Bounds().Dy() != dimensions.EntitiesPerColumn*dimensions.numEntityRows*dimensions.SpriteHeight {
-		return nil, fmt.Errorf("image height (%d) is not EntitiesPerColumn * #rows/GetEntity * SpriteHeight (%d)",
-			spriteSheet.Bounds().Dy(), dimensions.EntitiesPerColumn*dimensions.numEntityRows*dimensions.SpriteHeight)
+
+	if dimensions.TrustSubImager {
+		if dimensions.ColorKey != nil || (dimensions.ResizeWidth > 0 && dimensions.ResizeWidth != dimensions.SpriteWidth) {
+			return nil, errors.New("SheetDimensions TrustSubImager cannot be combined with ColorKey or resizing")
+		}
+		return spriteSheet, nil
 	}
 
 	// If it's not already, convert the sheet to an RGBA so generateEntities can check opacity
@@ -204,82 +381,271 @@ func createSpriteSheet(spriteSheet ccsl_graphics.SubImager, dimensions *SheetDim
 	var ok bool
 	if rgba, ok = spriteSheet.(*image.RGBA); !ok {
 		rgba = image.NewRGBA(spriteSheet.Bounds())
-		draw.Draw(rgba, spriteSheet.Bounds(), spriteSheet, image.Point{}, draw.Src)
+		draw.Draw(rgba, spriteSheet.Bounds(), spriteSheet, spriteSheet.Bounds().Min, draw.Src)
+	} else if dimensions.ColorKey != nil {
+		// Copy the image so applying the color key doesn't modify the caller's image.
+		rgba = image.NewRGBA(spriteSheet.Bounds())
+		draw.Draw(rgba, spriteSheet.Bounds(), spriteSheet, spriteSheet.Bounds().Min, draw.Src)
+	}
+
+	if dimensions.ColorKey != nil {
+		applyColorKey(rgba, *dimensions.ColorKey)
 	}
 
 	if dimensions.ResizeWidth > 0 && dimensions.ResizeWidth != dimensions.SpriteWidth {
-		if (float32(dimensions.ResizeWidth) / float32(dimensions.ResizeHeight)) != (float32(dimensions.SpriteWidth) / float32(dimensions.SpriteHeight)) {
-			return nil, errors.New("sprite resize aspect ratio () is not the same as original ratio")
+		// Compare the cross products rather than the (float) ratios so that equal ratios are never rejected due to
+		// rounding.
+		if dimensions.ResizeWidth*dimensions.SpriteHeight != dimensions.SpriteWidth*dimensions.ResizeHeight {
+			return nil, fmt.Errorf("sprite resize aspect ratio (%d/%d = %g) is not the same as original ratio (%d/%d = %g)",
+				dimensions.ResizeWidth, dimensions.ResizeHeight, float64(dimensions.ResizeWidth)/float64(dimensions.ResizeHeight),
+				dimensions.SpriteWidth, dimensions.SpriteHeight, float64(dimensions.SpriteWidth)/float64(dimensions.SpriteHeight))
+		}
+		if (dimensions.Margin*dimensions.ResizeWidth)%dimensions.SpriteWidth != 0 ||
+			(dimensions.Spacing*dimensions.ResizeWidth)%dimensions.SpriteWidth != 0 {
+			return nil, fmt.Errorf("Margin (%d) and Spacing (%d) do not scale to whole pixels with resize ratio %d/%d",
+				dimensions.Margin, dimensions.Spacing, dimensions.ResizeWidth, dimensions.SpriteWidth)
 		}
-		resizeRatio := float32(dimensions.ResizeWidth) / float32(dimensions.SpriteWidth)
-		rgba = ccsl_graphics.ResizeMaintain(rgba, uint(float32(spriteSheet.Bounds().Dx())*resizeRatio), uint(float32(spriteSheet.Bounds().Dy())*resizeRatio)).(*image.RGBA)
+		dimensions.Margin = dimensions.Margin * dimensions.ResizeWidth / dimensions.SpriteWidth
+		dimensions.Spacing = dimensions.Spacing * dimensions.ResizeWidth / dimensions.SpriteWidth
 		dimensions.SpriteWidth = dimensions.ResizeWidth
 		dimensions.SpriteHeight = dimensions.ResizeHeight
+		size = dimensions.imageSize()
+		rgba = resizeSprite(rgba, uint(size.X), uint(size.Y), dimensions.ResizeFilter).(*image.RGBA)
 	}
 
 	return rgba, nil
 }
 
-func generateModeNames(count int) []string {
-	var names []string
-	for i := 0; i < count; i++ {
-		names = append(names, "Mode"+strconv.Itoa(i))
+// applyColorKey makes every pixel in img which exactly matches key fully transparent.
+func applyColorKey(img *image.RGBA, key color.RGBA) {
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		row := rgbaRow(img, y)
+		for x := 0; x < len(row); x += 4 {
+			if row[x] == key.R && row[x+1] == key.G && row[x+2] == key.B && row[x+3] == key.A {
+				row[x], row[x+1], row[x+2], row[x+3] = 0, 0, 0, 0
+			}
+		}
+	}
+}
+
+// generateNames returns count names of the given kind ("entity" or "mode"), for the factories which do not take names
+// of that kind, using d.NameFormatter if it is set, or DefaultNameFormatter if not.
+func (d *SheetDimensions) generateNames(kind string, count int) []string {
+	formatter := d.NameFormatter
+	if formatter == nil {
+		formatter = DefaultNameFormatter
+	}
+	names := make([]string, count)
+	for i := range names {
+		names[i] = formatter(kind, i)
 	}
 	return names
 }
 
-func (s *Sheet) generateEntities(spriteSheet ccsl_graphics.SubImager, dimensions SheetDimensions, names []EntityAndModeNames) {
+// DefaultNameFormatter is the default SheetDimensions.NameFormatter. It names Entities "Entity0", "Entity1", etc. and
+// Modes "Mode0", "Mode1", etc.
+func DefaultNameFormatter(kind string, index int) string {
+	if kind == "entity" {
+		return "Entity" + strconv.Itoa(index)
+	}
+	return "Mode" + strconv.Itoa(index)
+}
+
+// checkNames returns an error if names does not fit the Sheet layout described by dimensions: if it has more entries
+// than the Sheet has Entities, or any entry has more Mode names than dimensions.ModesPerEntity or invalid FrameCounts
+// or ModeIndices. It is also an error if an Entity name is repeated, or a Mode name within an entry.
+func checkNames(dimensions SheetDimensions, names []EntityAndModeNames) error {
 	if len(names) > dimensions.EntitiesPerRow*dimensions.EntitiesPerColumn {
-		panic(fmt.Errorf("internal error: names has more keys (%d) than spriteSheet has Entities (%d)",
-			len(names), dimensions.EntitiesPerRow*dimensions.EntitiesPerColumn))
+		return fmt.Errorf("names has more entries (%d) than spriteSheet has Entities (%d)",
+			len(names), dimensions.EntitiesPerRow*dimensions.EntitiesPerColumn)
 	}
-	var x, y, dx, dy int
-	var frame image.Image
-	var opaque bool
-	spriteSize := image.Rect(0, 0, dimensions.SpriteWidth, dimensions.SpriteHeight)
-	s.entities = make(map[int]*Entity)
-	s.entityNamesToIndex = make(map[string]int)
-	for i, emNames := range names {
+	entityNames := make(map[string]bool, len(names))
+	for _, emNames := range names {
+		if entityNames[emNames.EntityName] {
+			return fmt.Errorf("entity name %s is used more than once", emNames.EntityName)
+		}
+		entityNames[emNames.EntityName] = true
+		modeNames := make(map[string]bool, len(emNames.ModeNames))
+		for _, name := range emNames.ModeNames {
+			if modeNames[name] {
+				return fmt.Errorf("mode name %s is used more than once for Entity %s", name, emNames.EntityName)
+			}
+			modeNames[name] = true
+		}
 		if len(emNames.ModeNames) > dimensions.ModesPerEntity {
-			panic(fmt.Errorf("names value, the slice of Mode names, has more entries (%d) than dimensions.ModesPerEntity (%d)",
-				len(emNames.ModeNames), dimensions.ModesPerEntity))
-		}
-		x = ((i % dimensions.EntitiesPerRow) * dimensions.numEntityColumns * dimensions.SpriteWidth) + spriteSheet.Bounds().Min.X
-		y = ((i / dimensions.EntitiesPerRow) * dimensions.numEntityRows * dimensions.SpriteHeight) + spriteSheet.Bounds().Min.Y
-		s.entities[i] = &Entity{
-			name: emNames.EntityName,
-		}
-		s.entities[i].modes = make(map[int]*Mode)
-		s.entities[i].modeNamesToIndex = make(map[string]int)
-		for j, modeName := range emNames.ModeNames {
-			s.entities[i].modes[j] = &Mode{
-				name:       modeName,
-				spriteSize: spriteSize,
+			return fmt.Errorf("mode names for Entity %s has more entries (%d) than dimensions.ModesPerEntity (%d)",
+				emNames.EntityName, len(emNames.ModeNames), dimensions.ModesPerEntity)
+		}
+		if emNames.FrameCounts != nil {
+			if len(emNames.FrameCounts) != len(emNames.ModeNames) {
+				return fmt.Errorf("FrameCounts for Entity %s has a different number of entries (%d) than ModeNames (%d)",
+					emNames.EntityName, len(emNames.FrameCounts), len(emNames.ModeNames))
 			}
-			opaque = true
-			for f := 0; f < dimensions.FramesPerAnimation; f++ {
-				if dimensions.FramesRunRows {
-					dx = f
-					dy = j
-				} else {
-					dx = j
-					dy = f
+			for _, count := range emNames.FrameCounts {
+				if count <= 0 || count > dimensions.FramesPerAnimation {
+					return fmt.Errorf("FrameCounts entry (%d) for Entity %s must be > 0 and <= dimensions.FramesPerAnimation (%d)",
+						count, emNames.EntityName, dimensions.FramesPerAnimation)
 				}
-				frame = spriteSheet.SubImage(spriteSize.Add(image.Point{X: x + dx*dimensions.SpriteWidth, Y: y + dy*dimensions.SpriteHeight}))
-				s.entities[i].modes[j].frames = append(s.entities[i].modes[j].frames, frame)
-				if !frame.(*image.RGBA).Opaque() {
-					opaque = false
+			}
+		}
+		if emNames.ModeIndices != nil {
+			if len(emNames.ModeIndices) != len(emNames.ModeNames) {
+				return fmt.Errorf("ModeIndices for Entity %s has a different number of entries (%d) than ModeNames (%d)",
+					emNames.EntityName, len(emNames.ModeIndices), len(emNames.ModeNames))
+			}
+			seen := make(map[int]bool)
+			for _, index := range emNames.ModeIndices {
+				if index < 0 || index >= dimensions.ModesPerEntity {
+					return fmt.Errorf("ModeIndices entry (%d) for Entity %s must be >= 0 and < dimensions.ModesPerEntity (%d)",
+						index, emNames.EntityName, dimensions.ModesPerEntity)
+				}
+				if seen[index] {
+					return fmt.Errorf("ModeIndices for Entity %s has duplicate entry (%d)", emNames.EntityName, index)
 				}
+				seen[index] = true
 			}
-			s.entities[i].modes[j].fullyOpaque = opaque
-			s.entities[i].modeNamesToIndex[modeName] = j
 		}
-		s.entityNamesToIndex[emNames.EntityName] = i
 	}
+	return nil
 }
 
-//describe index order in docstring
+// generateEntities populates the Sheet's Entities from spriteSheet. The Entities are independent of each other, so
+// they are generated in parallel by a pool of runtime.NumCPU() workers and then merged into the Sheet's maps.
+// names must already have been checked with checkNames; if it is invalid, generateEntities panics.
+func (s *Sheet) generateEntities(spriteSheet ccsl_graphics.SubImager, dimensions SheetDimensions, names []EntityAndModeNames) {
+	// This is checked up front, rather than by the workers, so that the panic occurs on the caller's goroutine.
+	if err := checkNames(dimensions, names); err != nil {
+		panic(fmt.Errorf("internal error: %v", err))
+	}
+
+	entities := make([]*Entity, len(names))
+	workers := runtime.NumCPU()
+	if workers > len(names) {
+		workers = len(names)
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				entities[i] = generateEntity(spriteSheet, dimensions, i, names[i])
+			}
+		}()
+	}
+	for i := range names {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	s.dimensions = dimensions
+	s.entities = make(map[int]*Entity, len(names))
+	s.entityNamesToIndex = make(map[string]int, len(names))
+	for i, entity := range entities {
+		s.entities[i] = entity
+		s.entityNamesToIndex[entity.name] = i
+	}
+}
+
+// generateEntity creates the Entity at index i of spriteSheet, with the Modes named in emNames.
+func generateEntity(spriteSheet ccsl_graphics.SubImager, dimensions SheetDimensions, i int, emNames EntityAndModeNames) *Entity {
+	spriteSize := image.Rect(0, 0, dimensions.SpriteWidth, dimensions.SpriteHeight)
+	entity := &Entity{
+		name:             emNames.EntityName,
+		modes:            make(map[int]*Mode),
+		modeNamesToIndex: make(map[string]int),
+	}
+	for j, modeName := range emNames.ModeNames {
+		mode := newMode(modeName, spriteSize)
+		mode.resizeFilter = dimensions.ResizeFilter
+		frameCount := dimensions.FramesPerAnimation
+		if emNames.FrameCounts != nil {
+			frameCount = emNames.FrameCounts[j]
+		}
+		sheetMode := j
+		if emNames.ModeIndices != nil {
+			sheetMode = emNames.ModeIndices[j]
+		}
+		rects := make([]image.Rectangle, frameCount)
+		for f := range rects {
+			rects[f] = dimensions.cellRect(i, sheetMode, f).Add(spriteSheet.Bounds().Min)
+		}
+		if dimensions.Lazy {
+			mode.lazy = &lazyFrames{img: spriteSheet, rects: rects, normalize: dimensions.NormalizeOrigins}
+		} else {
+			mode.frames = make([]Sprite, frameCount)
+			for f, rect := range rects {
+				mode.frames[f] = extractFrame(spriteSheet, rect, dimensions.NormalizeOrigins)
+			}
+			mode.updateFullyOpaque()
+		}
+		entity.modes[j] = mode
+		entity.modeNamesToIndex[modeName] = j
+	}
+	return entity
+}
+
+// extractFrame returns the frame within rect of spriteSheet, as an *image.RGBA: a view into spriteSheet, or if
+// normalize is set (see SheetDimensions.NormalizeOrigins), a copy of it.
+func extractFrame(spriteSheet ccsl_graphics.SubImager, rect image.Rectangle, normalize bool) Sprite {
+	frame := spriteSheet.SubImage(rect)
+	if normalize {
+		return copyFrame(frame)
+	}
+	if _, ok := frame.(*image.RGBA); !ok {
+		// Only possible with TrustSubImager.
+		return toRGBA(frame)
+	}
+	return frame
+}
+
+// Dimensions returns the SheetDimensions the Sheet was created with, as actually used to read its frames: if the
+// sprites were resized (see SheetDimensions.ResizeWidth), SpriteWidth, SpriteHeight, Margin and Spacing are the resized
+// values. It returns the zero SheetDimensions if the Sheet was not created from a sprite grid (e.g. by
+// NewSheetFromAseprite or SubSheet).
+func (s *Sheet) Dimensions() SheetDimensions {
+	d := s.dimensions
+	if d.ColorKey != nil {
+		key := *d.ColorKey
+		d.ColorKey = &key
+	}
+	return d
+}
+
+// IndexAt returns the index of the Entity at row and col of the sprite grid the Sheet was created from, counting
+// Entities (not sprites) from 0 at the upper-left. Indexes run row-major: across each row left to right, then on to the
+// start of the next row, so the index is row * EntitiesPerRow + col. It is an error if row or col is outside the grid,
+// or if the Sheet was not created from a grid (e.g. by NewSheetFromAseprite or SubSheet). The index is that of the
+// grid position, which after e.g. ReorderEntities or RemoveEntity may no longer hold the Entity read from there.
+func (s *Sheet) IndexAt(row, col int) (int, error) {
+	d := s.dimensions
+	if d.EntitiesPerRow == 0 {
+		return 0, errors.New("sheet was not created from a sprite grid")
+	}
+	if row < 0 || row >= d.EntitiesPerColumn || col < 0 || col >= d.EntitiesPerRow {
+		return 0, fmt.Errorf("row (%d) and col (%d) must be >= 0 and < EntitiesPerColumn (%d) and EntitiesPerRow (%d)",
+			row, col, d.EntitiesPerColumn, d.EntitiesPerRow)
+	}
+	return row*d.EntitiesPerRow + col, nil
+}
+
+// RowColOf returns the row and column of the sprite grid at which the Entity with index was read; it is the inverse of
+// IndexAt. It returns -1, -1 if index is negative or the Sheet was not created from a grid. index need not be less
+// than EntityCount().
+func (s *Sheet) RowColOf(index int) (row, col int) {
+	if s.dimensions.EntitiesPerRow == 0 || index < 0 {
+		return -1, -1
+	}
+	return index / s.dimensions.EntitiesPerRow, index % s.dimensions.EntitiesPerRow
+}
+
+// GetEntityByIndex returns the Entity with index idx. For a Sheet created from a sprite grid, indexes run row-major from
+// the upper-left of the sheet image (see IndexAt and RowColOf), unless since changed by e.g. ReorderEntities.
 func (s *Sheet) GetEntityByIndex(idx int) (*Entity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	entity, ok := s.entities[idx]
 	if ok {
 		return entity, nil
@@ -289,18 +655,50 @@ func (s *Sheet) GetEntityByIndex(idx int) (*Entity, error) {
 }
 
 func (s *Sheet) GetEntityByName(name string) (*Entity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	if idx, ok := s.entityNamesToIndex[name]; ok {
 		if entity, ok := s.entities[idx]; ok {
 			return entity, nil
 		} else {
-			panic(fmt.Errorf("internal error: GetEntity with index %d does not exist in Sheet; Sheet is corrupted", idx))
+			panic(fmt.Errorf("internal error: Entity with index %d does not exist in Sheet; Sheet is corrupted", idx))
 		}
 	} else {
 		return nil, fmt.Errorf("entity with name %s does not exist in Sheet", name)
 	}
 }
 
+// SpriteAt returns the frame at index frameIdx of the Mode at index modeIdx of the Entity at index entityIdx, without
+// the need to look up the Entity and Mode first. The error names whichever index does not exist. As with Mode.GetFrame
+// (which it calls), the returned Sprite is shared and must not be modified.
+func (s *Sheet) SpriteAt(entityIdx, modeIdx, frameIdx int) (Sprite, error) {
+	entity, err := s.GetEntityByIndex(entityIdx)
+	if err != nil {
+		return nil, err
+	}
+	mode, err := entity.GetModeByIndex(modeIdx)
+	if err != nil {
+		return nil, err
+	}
+	return mode.GetFrame(frameIdx)
+}
+
+// SpriteAtNamed is like SpriteAt, but finds the Entity and Mode by name.
+func (s *Sheet) SpriteAtNamed(entityName, modeName string, frameIdx int) (Sprite, error) {
+	entity, err := s.GetEntityByName(entityName)
+	if err != nil {
+		return nil, err
+	}
+	mode, err := entity.GetModeByName(modeName)
+	if err != nil {
+		return nil, err
+	}
+	return mode.GetFrame(frameIdx)
+}
+
 func (s *Sheet) RenameEntity(oldName, newName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	idx, ok := s.entityNamesToIndex[oldName]
 	if ok {
 		entity, ok := s.entities[idx]
@@ -309,7 +707,7 @@ func (s *Sheet) RenameEntity(oldName, newName string) error {
 			s.entityNamesToIndex[newName] = idx
 			delete(s.entityNamesToIndex, oldName)
 		} else {
-			panic(fmt.Errorf("internal error: GetEntity with index %d does not exist in Sheet; Sheet is corrupted", idx))
+			panic(fmt.Errorf("internal error: Entity with index %d does not exist in Sheet; Sheet is corrupted", idx))
 		}
 	} else {
 		return fmt.Errorf("entity with name %s does not exist in Sheet", oldName)
@@ -317,12 +715,265 @@ func (s *Sheet) RenameEntity(oldName, newName string) error {
 	return nil
 }
 
+// Clone returns a deep copy of the Sheet. The Entities and Modes of the clone may be renamed, removed, have their
+// frames edited, etc. without affecting s (and vice versa). The frame image data is shared by reference, as frames
+// are treated as read-only.
+// Instances created from s continue to refer to the Entities and Modes of s, not the clone.
+func (s *Sheet) Clone() *Sheet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c := &Sheet{
+		entities:           make(map[int]*Entity, len(s.entities)),
+		entityNamesToIndex: make(map[string]int, len(s.entityNamesToIndex)),
+		dimensions:         s.dimensions,
+	}
+	for idx, entity := range s.entities {
+		c.entities[idx] = entity.clone()
+	}
+	for name, idx := range s.entityNamesToIndex {
+		c.entityNamesToIndex[name] = idx
+	}
+	return c
+}
+
+// SubSheet returns a new Sheet containing only the named Entities, indexed 0..len(entityNames)-1 in the order given.
+// The Entities (and their Modes) are copies, as with Clone, so they may be modified without affecting s; the frame
+// image data is shared by reference.
+// It is an error if any name does not exist in s or appears more than once.
+func (s *Sheet) SubSheet(entityNames []string) (*Sheet, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sub := &Sheet{
+		entities:           make(map[int]*Entity, len(entityNames)),
+		entityNamesToIndex: make(map[string]int, len(entityNames)),
+	}
+	for i, name := range entityNames {
+		if _, ok := sub.entityNamesToIndex[name]; ok {
+			return nil, fmt.Errorf("entity with name %s requested more than once", name)
+		}
+		idx, ok := s.entityNamesToIndex[name]
+		if !ok {
+			return nil, fmt.Errorf("entity with name %s does not exist in Sheet", name)
+		}
+		sub.entities[i] = s.entities[idx].clone()
+		sub.entityNamesToIndex[name] = i
+	}
+	return sub, nil
+}
+
+// ForEachEntity calls fn for each of the Sheet's Entities, in ascending index order. If fn returns an error, iteration
+// stops and that error is returned. The Entities iterated over are those in the Sheet when ForEachEntity is called; fn
+// may modify the Sheet (e.g. RemoveEntity), but that does not affect the iteration.
+func (s *Sheet) ForEachEntity(fn func(index int, e *Entity) error) error {
+	s.mu.RLock()
+	idxs := s.sortedIndexes()
+	entities := make([]*Entity, len(idxs))
+	for n, idx := range idxs {
+		entities[n] = s.entities[idx]
+	}
+	s.mu.RUnlock()
+
+	for n, idx := range idxs {
+		if err := fn(idx, entities[n]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReorderEntities rearranges the Sheet's Entities so that the Entity previously at index newOrder[i] is at index i.
+// newOrder must be a permutation of the existing indexes (0 to EntityCount()-1); as for all the Sheet's Entities,
+// indexes run contiguously from 0. Entity names, and Instances of the Entities, are unaffected, but subsequent lookups
+// by index (e.g. GetEntityByIndex) and iteration in index order (e.g. ForEachEntity) use the new order.
+func (s *Sheet) ReorderEntities(newOrder []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := checkPermutation(newOrder, len(s.entities)); err != nil {
+		return err
+	}
+	entities := make(map[int]*Entity, len(s.entities))
+	for idx, oldIdx := range newOrder {
+		entities[idx] = s.entities[oldIdx]
+		s.entityNamesToIndex[entities[idx].name] = idx
+	}
+	s.entities = entities
+	return nil
+}
+
+// RemoveEntity removes the Entity named name from the Sheet. The Entities after it move down one index each, so
+// indexes still run contiguously from 0. Existing Instances of the removed Entity are unaffected, and continue to work.
+func (s *Sheet) RemoveEntity(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed, ok := s.entityNamesToIndex[name]
+	if !ok {
+		return fmt.Errorf("entity with name %s does not exist in Sheet", name)
+	}
+	count := len(s.entities)
+	for idx := removed; idx < count-1; idx++ {
+		s.entities[idx] = s.entities[idx+1]
+		s.entityNamesToIndex[s.entities[idx].name] = idx
+	}
+	delete(s.entities, count-1)
+	delete(s.entityNamesToIndex, name)
+	return nil
+}
+
+// RenameModeAll renames the Mode named oldName to newName in every Entity that has one (see Entity.RenameMode),
+// returning the number of Entities in which it was renamed. Entities without a Mode named oldName are skipped. It is an
+// error for an Entity to already have a different Mode named newName; that Entity is left unchanged, the others are
+// still renamed, and the returned error describes every Entity which could not be renamed.
+func (s *Sheet) RenameModeAll(oldName, newName string) (renamed int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var failed []string
+	var first error
+	for _, idx := range s.sortedIndexes() {
+		e := s.entities[idx]
+		if _, ok := e.modeNamesToIndex[oldName]; !ok {
+			continue
+		}
+		if oldName == newName {
+			renamed++
+			continue
+		}
+		if _, ok := e.modeNamesToIndex[newName]; ok {
+			err := fmt.Errorf("entity %s (index %d): mode with name %s already exists", e.name, idx, newName)
+			if first == nil {
+				first = err
+			}
+			failed = append(failed, err.Error())
+			continue
+		}
+		if err := e.RenameMode(oldName, newName); err != nil {
+			return renamed, err
+		}
+		renamed++
+	}
+	if len(failed) == 1 {
+		return renamed, first
+	} else if len(failed) > 1 {
+		return renamed, fmt.Errorf("could not rename mode %s in %d entities: %w (and: %s)", oldName, len(failed),
+			first, strings.Join(failed[1:], "; "))
+	}
+	return renamed, nil
+}
+
+// NewInstances creates one Instance of each of the Sheet's Entities, in ascending index order, each starting in the
+// Mode with index initialMode. It is an error if any Entity lacks that Mode.
+func (s *Sheet) NewInstances(initialMode int) ([]*Instance, error) {
+	var instances []*Instance
+	err := s.ForEachEntity(func(index int, e *Entity) error {
+		instance, err := e.NewInstance(initialMode)
+		if err != nil {
+			return fmt.Errorf("entity %s (index %d): %w", e.name, index, err)
+		}
+		instances = append(instances, instance)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
+
+// NewRandomInstance creates an Instance, starting in the Mode with index initialMode, of one of the Sheet's Entities
+// chosen at random according to weights, by Entity name - e.g. to spawn a varied crowd. Each Entity's chance of being
+// chosen is its weight divided by the total of all the Entities' weights. Entities not in weights have a weight of 1;
+// a weight of 0 excludes an Entity. Weights must be >= 0 (and finite), must name only existing Entities, and must not
+// exclude every Entity. The Entity is chosen using r, or if it is nil, the math/rand package's default source; a
+// seeded r gives a reproducible sequence.
+func (s *Sheet) NewRandomInstance(weights map[string]float64, initialMode int, r *rand.Rand) (*Instance, error) {
+	entity, err := s.randomEntity(weights, r)
+	if err != nil {
+		return nil, err
+	}
+	return entity.NewInstance(initialMode)
+}
+
+// randomEntity chooses an Entity for NewRandomInstance.
+func (s *Sheet) randomEntity(weights map[string]float64, r *rand.Rand) (*Entity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for name, weight := range weights {
+		if _, ok := s.entityNamesToIndex[name]; !ok {
+			return nil, fmt.Errorf("entity with name %s does not exist in Sheet", name)
+		}
+		if !(weight >= 0) || math.IsInf(weight, 0) {
+			return nil, fmt.Errorf("weight (%g) of entity %s must be >= 0 and finite", weight, name)
+		}
+	}
+	idxs := s.sortedIndexes()
+	cumulative := make([]float64, len(idxs))
+	total := 0.0
+	for n, idx := range idxs {
+		weight, ok := weights[s.entities[idx].name]
+		if !ok {
+			weight = 1
+		}
+		total += weight
+		cumulative[n] = total
+	}
+	if total == 0 {
+		return nil, errors.New("entity weights must not all be 0")
+	}
+	var pick float64
+	if r != nil {
+		pick = r.Float64() * total
+	} else {
+		pick = rand.Float64() * total
+	}
+	// The first Entity whose cumulative weight exceeds pick; as pick < total, there always is one.
+	n := sort.Search(len(cumulative), func(n int) bool { return cumulative[n] > pick })
+	return s.entities[idxs[n]], nil
+}
+
+// sortedIndexes returns the indexes of the Sheet's Entities in ascending order.
+func (s *Sheet) sortedIndexes() []int {
+	idxs := make([]int, 0, len(s.entities))
+	for idx := range s.entities {
+		idxs = append(idxs, idx)
+	}
+	sort.Ints(idxs)
+	return idxs
+}
+
+// EntityNames returns the names of the Sheet's Entities, in index order (which, for Sheets created by the factories,
+// is row-major from the upper-left of the sheet image). If there are gaps in the indexes, the names are still
+// returned contiguously, in ascending index order.
+func (s *Sheet) EntityNames() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	idxs := s.sortedIndexes()
+	names := make([]string, len(idxs))
+	for n, idx := range idxs {
+		names[n] = s.entities[idx].name
+	}
+	return names
+}
+
 func (s *Sheet) EntityCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return len(s.entities)
 }
 
+// TotalFrameCount returns the total number of frames in all the Modes of all the Sheet's Entities (see
+// Entity.TotalFrameCount), e.g. to report progress while precomputing per-frame data.
+func (s *Sheet) TotalFrameCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	total := 0
+	for _, e := range s.entities {
+		total += e.TotalFrameCount()
+	}
+	return total
+}
+
 //only decrease
 func (s *Sheet) SetEntityCount(count int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if count > 0 && count <= len(s.entities) {
 		var delList []string
 		for k, v := range s.entityNamesToIndex {
@@ -333,11 +984,202 @@ func (s *Sheet) SetEntityCount(count int) error {
 		for _, d := range delList {
 			delete(s.entityNamesToIndex, d)
 		}
-		for i := count; i < len(s.entities); i++ {
+		for i, n := count, len(s.entities); i < n; i++ {
 			delete(s.entities, i)
 		}
+		if Logger != nil {
+			sort.Strings(delList)
+			logf("sprites: SetEntityCount dropped %d Entities: %v", len(delList), delList)
+		}
 		return nil
 	} else {
-		return fmt.Errorf("new GetEntity count (%d) must be <= the current GetEntity count (%d) and > 0", count, len(s.entities))
+		return fmt.Errorf("new Entity count (%d) must be <= the current Entity count (%d) and > 0", count, len(s.entities))
+	}
+}
+
+// Validate checks the internal consistency of the Sheet, returning an error describing the first problem found, if any.
+// It checks that the Entities' indexes run contiguously from 0, that every Entity name maps to the index of the Entity
+// with that name (and vice versa), and likewise for each Entity's Modes; and that every Mode has at least one frame,
+// and that each frame is an *image.RGBA of the Mode's SpriteSize (which may differ between an Entity's Modes; see
+// Mode.CropToContent).
+func (s *Sheet) Validate() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.entityNamesToIndex) != len(s.entities) {
+		return fmt.Errorf("sheet has %d entity names but %d entities", len(s.entityNamesToIndex), len(s.entities))
+	}
+	for name, idx := range s.entityNamesToIndex {
+		e, ok := s.entities[idx]
+		if !ok {
+			return fmt.Errorf("entity name %s maps to index %d, which does not exist in Sheet", name, idx)
+		}
+		if e.name != name {
+			return fmt.Errorf("entity name %s maps to index %d, which is named %s", name, idx, e.name)
+		}
+	}
+	for idx := 0; idx < len(s.entities); idx++ {
+		e, ok := s.entities[idx]
+		if !ok {
+			return fmt.Errorf("entity indexes are not contiguous: index %d does not exist in Sheet", idx)
+		}
+		if err := e.validate(); err != nil {
+			return fmt.Errorf("entity %s (index %d): %w", e.name, idx, err)
+		}
+	}
+	return nil
+}
+
+// MemoryBytes returns the approximate number of bytes of pixel data used by the Sheet's frames. See MemoryUsage.
+func (s *Sheet) MemoryBytes() int {
+	shared, owned := s.MemoryUsage()
+	return shared + owned
+}
+
+// MemoryUsage returns the approximate number of bytes of pixel data used by the Sheet's frames, divided into shared
+// bytes - those of image buffers which frames are views into (as frames loaded from a sprite sheet image are views
+// into the one sheet buffer) - and owned bytes, of standalone frame images (such as those added by Mode.InsertFrame,
+// or created by transforms like Mode.Grayscale). Each buffer is counted once, however many frames use it, including
+// across Entities and Modes. A shared buffer is counted from the start of the first frame in it, so any part of the
+// original image before that (e.g. a margin) is not included. Cached data, such as resized frames, is not included.
+func (s *Sheet) MemoryUsage() (shared, owned int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	// Buffers are identified by the address of their last byte, which is the same for every slice of them.
+	type buffer struct {
+		size   int
+		shared bool
+	}
+	buffers := make(map[*uint8]*buffer)
+	images := make(map[*image.RGBA]bool)
+	for _, e := range s.entities {
+		for _, m := range e.modes {
+			m.loadFrames()
+			for _, frame := range m.frames {
+				rgba := frame.(*image.RGBA)
+				if images[rgba] || cap(rgba.Pix) == 0 {
+					continue
+				}
+				images[rgba] = true
+				key := &rgba.Pix[:cap(rgba.Pix)][cap(rgba.Pix)-1]
+				// A frame is a view if its pixel data does not exactly fill the buffer.
+				view := cap(rgba.Pix) != rgba.Rect.Dx()*rgba.Rect.Dy()*4
+				if b, ok := buffers[key]; ok {
+					b.shared = true
+					if cap(rgba.Pix) > b.size {
+						b.size = cap(rgba.Pix)
+					}
+				} else {
+					buffers[key] = &buffer{size: cap(rgba.Pix), shared: view}
+				}
+			}
+		}
+	}
+	for _, b := range buffers {
+		if b.shared {
+			shared += b.size
+		} else {
+			owned += b.size
+		}
+	}
+	return shared, owned
+}
+
+// BlankModes returns the indexes of the Modes of each Entity (by Entity index) in which every frame is fully
+// transparent, in ascending order, e.g. to prune unused columns of a sheet with Entity.RemoveMode. Entities without
+// any blank Modes are not included.
+func (s *Sheet) BlankModes() map[int][]int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	blank := make(map[int][]int)
+	for idx, e := range s.entities {
+		for _, modeIdx := range e.sortedModeIndexes() {
+			if e.modes[modeIdx].blank() {
+				blank[idx] = append(blank[idx], modeIdx)
+			}
+		}
+	}
+	return blank
+}
+
+// ContentHash returns a hex-encoded SHA-256 digest of the Sheet's content: its Entities in index order, and for each
+// its name and its Modes in index order, each with its name, sprite size and frames' pixel data. It is stable across
+// runs and processes, so for example may be compared in CI to detect changes to assets. Metadata (see SetMeta), frame
+// names and durations, anchors and the layout of the original sheet image are not included.
+func (s *Sheet) ContentHash() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	h := sha256.New()
+	var buf [8]byte
+	writeInt := func(n int) {
+		binary.LittleEndian.PutUint64(buf[:], uint64(n))
+		_, _ = h.Write(buf[:])
+	}
+	// Strings are length-prefixed, so that no two different sequences of names serialize the same.
+	writeString := func(str string) {
+		writeInt(len(str))
+		_, _ = h.Write([]byte(str))
+	}
+
+	idxs := s.sortedIndexes()
+	writeInt(len(idxs))
+	for _, idx := range idxs {
+		e := s.entities[idx]
+		writeString(e.name)
+		modeIdxs := e.sortedModeIndexes()
+		writeInt(len(modeIdxs))
+		for _, modeIdx := range modeIdxs {
+			m := e.modes[modeIdx]
+			m.loadFrames()
+			writeString(m.name)
+			writeInt(m.spriteSize.Dx())
+			writeInt(m.spriteSize.Dy())
+			writeInt(len(m.frames))
+			for _, frame := range m.frames {
+				rgba := frame.(*image.RGBA)
+				// Frames are always the Mode's sprite size (see Validate), so need no size of their own.
+				for y := rgba.Rect.Min.Y; y < rgba.Rect.Max.Y; y++ {
+					_, _ = h.Write(rgbaRow(rgba, y))
+				}
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// FindDuplicateEntities returns groups of the indexes of Entities which are pixel-identical: which have the same number
+// of Modes, and in each Mode (in index order) the same number of frames, with identical pixel data in the same order.
+// Names are not compared. Each group is in ascending index order, and the groups are ordered by their first index;
+// Entities without duplicates are not included.
+func (s *Sheet) FindDuplicateEntities() [][]int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	// Entities are grouped by a digest of all their frames, and then compared in full to rule out collisions.
+	candidates := make(map[uint64][][]int)
+	for _, idx := range s.sortedIndexes() {
+		digest := s.entities[idx].pixelDigest()
+		found := false
+		for n, group := range candidates[digest] {
+			if s.entities[group[0]].pixelsEqual(s.entities[idx]) {
+				candidates[digest][n] = append(group, idx)
+				found = true
+				break
+			}
+		}
+		if !found {
+			candidates[digest] = append(candidates[digest], []int{idx})
+		}
+	}
+
+	var groups [][]int
+	for _, digestGroups := range candidates {
+		for _, group := range digestGroups {
+			if len(group) > 1 {
+				groups = append(groups, group)
+			}
+		}
 	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i][0] < groups[j][0]
+	})
+	return groups
 }
@@ -0,0 +1,1318 @@
+package sprites
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	ccsl_graphics "github.com/HaileyStorm/CCSL_go/graphics"
+)
+
+func TestForEachEntity(t *testing.T) {
+	s := mustSheet(t)
+	var seen []int
+	err := s.ForEachEntity(func(index int, e *Entity) error {
+		if e.Name() != DefaultNameFormatter("entity", index) {
+			t.Fatalf("Entity %s at index %d", e.Name(), index)
+		}
+		seen = append(seen, index)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 4 {
+		t.Fatalf("saw %d Entities, want 4", len(seen))
+	}
+	for n, index := range seen {
+		if index != n {
+			t.Fatalf("indexes seen in order %v, want ascending", seen)
+		}
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = s.ForEachEntity(func(index int, e *Entity) error {
+		calls++
+		if index == 1 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || calls != 2 {
+		t.Fatalf("ForEachEntity returned %v after %d calls, want the callback's error after 2", err, calls)
+	}
+}
+
+func TestNewInstances(t *testing.T) {
+	s := mustSheet(t)
+	instances, err := s.NewInstances(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(instances) != 4 {
+		t.Fatalf("created %d Instances, want 4", len(instances))
+	}
+	for n, i := range instances {
+		if i.Entity.Name() != DefaultNameFormatter("entity", n) || i.Mode.Name() != DefaultNameFormatter("mode", 2) {
+			t.Fatalf("Instance %d is of %s/%s", n, i.Entity.Name(), i.Mode.Name())
+		}
+	}
+	if _, err := s.NewInstances(5); err == nil {
+		t.Fatal("missing Mode accepted")
+	}
+}
+
+func TestNewInstancesConcurrentRemove(t *testing.T) {
+	s := mustSheet(t)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for _, name := range s.EntityNames()[1:] {
+			if err := s.RemoveEntity(name); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	for n := 0; n < 10; n++ {
+		if _, err := s.NewInstances(0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+}
+
+// TestSheetConcurrentMutation mutates a Sheet while reading it and placing an Instance of it; run with -race.
+func TestSheetConcurrentMutation(t *testing.T) {
+	s := mustSheet(t)
+	names := s.EntityNames()
+	i := mustInstance(t, mustEntity(t, s, 0), 0)
+	i.StartAnimation()
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for n := 0; n < 200; n++ {
+			if err := s.RenameEntity(names[2], "renamed"); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := s.RenameEntity("renamed", names[2]); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := s.ReorderEntities([]int{0, 1, 3, 2}); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for n := 0; n < 200; n++ {
+			if _, err := s.GetEntityByIndex(n % 4); err != nil {
+				t.Error(err)
+				return
+			}
+			_, _ = s.GetEntityByName(names[3])
+			_ = s.EntityNames()
+			_ = s.EntityCount()
+			_ = s.ForEachEntity(func(int, *Entity) error { return nil })
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		canvas := image.NewRGBA(image.Rect(0, 0, 4, 4))
+		for n := 0; n < 200; n++ {
+			i.PlaceOn(canvas, image.Point{})
+		}
+	}()
+	wg.Wait()
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSheetCloneIsolated(t *testing.T) {
+	s := mustSheet(t)
+	c := s.Clone()
+	entity0, mode0 := DefaultNameFormatter("entity", 0), DefaultNameFormatter("mode", 0)
+	if err := c.RenameEntity(entity0, "renamed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetEntityCount(2); err != nil {
+		t.Fatal(err)
+	}
+	ce := mustEntity(t, c, 1)
+	if err := ce.RenameMode(mode0, "renamed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mustMode(t, ce, 0).SetFrameCount(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := ce.SetModeCount(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.EntityCount() != 4 {
+		t.Fatalf("original has %d Entities, want 4", s.EntityCount())
+	}
+	if _, err := s.GetEntityByName(entity0); err != nil {
+		t.Fatal(err)
+	}
+	e := mustEntity(t, s, 1)
+	if e.ModeCount() != 3 {
+		t.Fatalf("original Entity has %d Modes, want 3", e.ModeCount())
+	}
+	m, err := e.GetModeByName(mode0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.FrameCount() != 4 {
+		t.Fatalf("original Mode has %d frames, want 4", m.FrameCount())
+	}
+	// The frame image data is shared.
+	original, _ := m.GetFrame(0)
+	cloned, _ := mustMode(t, ce, 0).GetFrame(0)
+	if original != cloned {
+		t.Fatal("frame data copied")
+	}
+}
+
+func TestSubSheet(t *testing.T) {
+	s := mustSheet(t)
+	names := []string{DefaultNameFormatter("entity", 3), DefaultNameFormatter("entity", 1)}
+	sub, err := s.SubSheet(names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.EntityCount() != 2 {
+		t.Fatalf("sub-Sheet has %d Entities, want 2", sub.EntityCount())
+	}
+	for n, name := range names {
+		if e := mustEntity(t, sub, n); e.Name() != name {
+			t.Fatalf("Entity %d is %s, want %s", n, e.Name(), name)
+		}
+	}
+	// Frames are shared by reference.
+	original, _ := mustMode(t, mustEntity(t, s, 3), 0).GetFrame(0)
+	shared, _ := mustMode(t, mustEntity(t, sub, 0), 0).GetFrame(0)
+	if original != shared {
+		t.Fatal("frame data copied")
+	}
+
+	if _, err := s.SubSheet([]string{"missing"}); err == nil {
+		t.Fatal("missing Entity accepted")
+	}
+	if _, err := s.SubSheet([]string{names[0], names[0]}); err == nil {
+		t.Fatal("repeated Entity accepted")
+	}
+}
+
+func TestEntityNames(t *testing.T) {
+	s := mustSheet(t)
+	want := []string{DefaultNameFormatter("entity", 0), DefaultNameFormatter("entity", 1),
+		DefaultNameFormatter("entity", 2), DefaultNameFormatter("entity", 3)}
+	if got := s.EntityNames(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("EntityNames gave %v, want %v", got, want)
+	}
+	if err := s.SetEntityCount(3); err != nil {
+		t.Fatal(err)
+	}
+	// Leave a gap in the indexes.
+	delete(s.entities, 1)
+	want = []string{want[0], want[2]}
+	if got := s.EntityNames(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("EntityNames gave %v, want %v", got, want)
+	}
+}
+
+func TestResizeAspectRatio(t *testing.T) {
+	single := SheetDimensions{EntitiesPerRow: 1, EntitiesPerColumn: 1, ModesPerEntity: 1, FramesPerAnimation: 1}
+	for _, size := range [][4]int{{3, 7, 9, 21}, {32, 32, 48, 48}, {5, 3, 35, 21}, {10, 3, 30, 9}} {
+		d := single
+		d.SpriteWidth, d.SpriteHeight, d.ResizeWidth, d.ResizeHeight = size[0], size[1], size[2], size[3]
+		s, err := NewSheet(testSheetImage(d), d)
+		if err != nil {
+			t.Fatalf("resize %dx%d to %dx%d: %v", size[0], size[1], size[2], size[3], err)
+		}
+		frame, _ := mustMode(t, mustEntity(t, s, 0), 0).GetFrame(0)
+		if got := frame.Bounds().Size(); got != image.Pt(size[2], size[3]) {
+			t.Fatalf("resize %dx%d to %dx%d gave a %v frame", size[0], size[1], size[2], size[3], got)
+		}
+	}
+
+	d := single
+	d.SpriteWidth, d.SpriteHeight, d.ResizeWidth, d.ResizeHeight = 3, 7, 9, 20
+	_, err := NewSheet(testSheetImage(d), d)
+	if err == nil {
+		t.Fatal("resize 3x7 to 9x20 accepted")
+	}
+	if !strings.Contains(err.Error(), "9/20") || !strings.Contains(err.Error(), "3/7") {
+		t.Fatalf("error %q does not give the ratios", err)
+	}
+	d.ResizeHeight = 0
+	if _, err := NewSheet(testSheetImage(d), d); err == nil {
+		t.Fatal("resize 3x7 to 9x0 accepted")
+	}
+}
+
+// assertSamePixels fails t if got and want do not have the same number of Entities, with the same frame pixels.
+func assertSamePixels(t *testing.T, got, want *Sheet) {
+	t.Helper()
+	if got.EntityCount() != want.EntityCount() {
+		t.Fatalf("%d Entities, want %d", got.EntityCount(), want.EntityCount())
+	}
+	for i := 0; i < want.EntityCount(); i++ {
+		if !mustEntity(t, got, i).pixelsEqual(mustEntity(t, want, i)) {
+			t.Fatalf("Entity %d differs", i)
+		}
+	}
+}
+
+// padSheet returns a copy of src, a sheet image of sw x sh sprites with no margin or spacing, with margin pixels
+// around the grid and spacing pixels between its cells. The padding is filled with an opaque color.
+func padSheet(src *image.RGBA, sw, sh, margin, spacing int) *image.RGBA {
+	cols, rows := src.Bounds().Dx()/sw, src.Bounds().Dy()/sh
+	out := filledImage(2*margin+cols*sw+(cols-1)*spacing, 2*margin+rows*sh+(rows-1)*spacing, color.RGBA{1, 2, 3, 255})
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			at := image.Pt(margin+c*(sw+spacing), margin+r*(sh+spacing))
+			draw.Draw(out, image.Rect(0, 0, sw, sh).Add(at), src, image.Pt(c*sw, r*sh), draw.Src)
+		}
+	}
+	return out
+}
+
+func TestMarginAndSpacing(t *testing.T) {
+	for _, framesRunRows := range []bool{false, true} {
+		// Use non-square sprites, so that mixing up the axes is caught.
+		d := basicDims()
+		d.SpriteWidth, d.FramesRunRows = 3, framesRunRows
+		img := testSheetImage(d)
+		want, err := NewSheet(img, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		padded := padSheet(img, 3, 4, 2, 1)
+		pd := d
+		pd.Margin, pd.Spacing = 2, 1
+		got, err := NewSheet(padded, pd)
+		if err != nil {
+			t.Fatalf("FramesRunRows %v: %v", framesRunRows, err)
+		}
+		assertSamePixels(t, got, want)
+
+		if _, err := NewSheet(img, pd); err == nil {
+			t.Fatalf("FramesRunRows %v: unpadded image accepted with a margin and spacing", framesRunRows)
+		}
+		for _, scale := range []int{2, 3} {
+			rd := pd
+			rd.ResizeWidth, rd.ResizeHeight = 3*scale, 4*scale
+			if _, err := NewSheet(padded, rd); err != nil {
+				t.Fatalf("FramesRunRows %v, resize x%d: %v", framesRunRows, scale, err)
+			}
+		}
+	}
+}
+
+func TestSheetOrigin(t *testing.T) {
+	d := basicDims()
+	img := testSheetImage(d)
+	want, err := NewSheet(img, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Place the grid at (4,7) from the upper-left of a larger image whose bounds do not start at (0,0), in both an RGBA
+	// image (which is used directly) and an NRGBA one (which is converted).
+	bounds := image.Rect(-3, 5, img.Bounds().Dx()+10, img.Bounds().Dy()+20)
+	at := bounds.Min.Add(image.Pt(4, 7))
+	for _, big := range []draw.Image{image.NewRGBA(bounds), image.NewNRGBA(bounds)} {
+		draw.Draw(big, img.Bounds().Add(at), img, image.Point{}, draw.Src)
+		od := d
+		od.OriginX, od.OriginY = 4, 7
+		got, err := NewSheet(big.(ccsl_graphics.SubImager), od)
+		if err != nil {
+			t.Fatalf("%T: %v", big, err)
+		}
+		assertSamePixels(t, got, want)
+
+		od.OriginX = 20
+		if _, err := NewSheet(big.(ccsl_graphics.SubImager), od); err == nil {
+			t.Fatalf("%T: grid extending past the image accepted", big)
+		}
+	}
+}
+
+func TestFrameCounts(t *testing.T) {
+	d := basicDims()
+	img := testSheetImage(d)
+	s, err := NewSheetWithNames(img, d, []EntityAndModeNames{
+		{EntityName: "boss", ModeNames: []string{"a", "b", "c"}, FrameCounts: []int{4, 2, 1}},
+		{EntityName: "villager", ModeNames: []string{"a"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	boss := mustEntity(t, s, 0)
+	for j, want := range []int{4, 2, 1} {
+		if got := mustMode(t, boss, j).FrameCount(); got != want {
+			t.Fatalf("boss Mode %d has %d frames, want %d", j, got, want)
+		}
+	}
+	frame, _ := mustMode(t, boss, 1).GetFrame(1)
+	if got := frame.At(frame.Bounds().Min.X, frame.Bounds().Min.Y); got != cellColor(1, 1) {
+		t.Fatalf("boss Mode 1 frame 1 is %v, want %v", got, cellColor(1, 1))
+	}
+	if got := mustMode(t, mustEntity(t, s, 1), 0).FrameCount(); got != 4 {
+		t.Fatalf("villager Mode 0 has %d frames, want 4", got)
+	}
+
+	for _, counts := range [][]int{{5}, {0}, {1, 2}} {
+		_, err := NewSheetWithNames(img, d, []EntityAndModeNames{{EntityName: "boss", ModeNames: []string{"a"}, FrameCounts: counts}})
+		if err == nil {
+			t.Fatalf("FrameCounts %v accepted", counts)
+		}
+	}
+}
+
+func TestColorKey(t *testing.T) {
+	// A checkerboard of the key color and an opaque color.
+	key, other := color.RGBA{R: 255, B: 255, A: 255}, color.RGBA{R: 1, G: 2, B: 3, A: 255}
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			if (x+y)%2 == 0 {
+				img.SetRGBA(x, y, key)
+			} else {
+				img.SetRGBA(x, y, other)
+			}
+		}
+	}
+	if m := singleSpriteMode(t, img); !m.FullyOpaque() {
+		t.Fatal("Mode not fully opaque without a color key")
+	}
+
+	d := SheetDimensions{EntitiesPerRow: 1, EntitiesPerColumn: 1, ModesPerEntity: 1, FramesPerAnimation: 1,
+		SpriteWidth: 4, SpriteHeight: 4, ColorKey: &key}
+	s, err := NewSheet(img, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := mustMode(t, mustEntity(t, s, 0), 0)
+	if m.FullyOpaque() {
+		t.Fatal("Mode fully opaque with a color key")
+	}
+	frame, _ := m.GetFrame(0)
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			want := other
+			if (x+y)%2 == 0 {
+				want = color.RGBA{}
+			}
+			if got := frame.(*image.RGBA).RGBAAt(x, y); got != want {
+				t.Fatalf("pixel (%d,%d) is %v, want %v", x, y, got, want)
+			}
+		}
+	}
+	if img.RGBAAt(0, 0) != key {
+		t.Fatal("the color key modified the source image")
+	}
+}
+
+func TestRenameModeAll(t *testing.T) {
+	s := mustSheet(t)
+	old := DefaultNameFormatter("mode", 0)
+	// Entity 1 has no Mode named old, and Entity 2 already has a different Mode named "Down".
+	if err := mustEntity(t, s, 1).RenameMode(old, "Other"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mustEntity(t, s, 2).RenameMode(DefaultNameFormatter("mode", 1), "Down"); err != nil {
+		t.Fatal(err)
+	}
+	renamed, err := s.RenameModeAll(old, "Down")
+	if renamed != 2 || err == nil {
+		t.Fatalf("RenameModeAll renamed %d with error %v, want 2 and an error for Entity 2", renamed, err)
+	}
+	for _, idx := range []int{0, 3} {
+		if m, err := mustEntity(t, s, idx).GetModeByName("Down"); err != nil || m != mustMode(t, mustEntity(t, s, idx), 0) {
+			t.Fatalf("Mode 0 of Entity %d not renamed", idx)
+		}
+	}
+	if _, err := mustEntity(t, s, 2).GetModeByName(old); err != nil {
+		t.Fatal("Mode of Entity 2 renamed despite the conflict")
+	}
+
+	if renamed, err = s.RenameModeAll("nope", "X"); renamed != 0 || err != nil {
+		t.Fatalf("RenameModeAll of a missing Mode renamed %d with error %v, want 0 and no error", renamed, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s := mustSheet(t)
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetEntityCount(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := mustEntity(t, s, 0).SetModeCount(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Validate(); err != nil {
+		t.Fatalf("Validate after SetEntityCount and SetModeCount: %v", err)
+	}
+
+	for _, c := range []struct {
+		problem string
+		corrupt func(s *Sheet)
+	}{
+		{"entity name with no Entity", func(s *Sheet) { s.entityNamesToIndex["extra"] = 9 }},
+		{"gap in the entity indexes", func(s *Sheet) {
+			delete(s.entityNamesToIndex, s.entities[1].name)
+			delete(s.entities, 1)
+		}},
+		{"entity name mismatch", func(s *Sheet) { s.entities[0].name = "other" }},
+		{"mode name mismatch", func(s *Sheet) { s.entities[0].modes[0].name = "other" }},
+		{"mode with no frames", func(s *Sheet) { s.entities[0].modes[1].frames = nil }},
+		{"frame of the wrong size", func(s *Sheet) {
+			s.entities[0].modes[1].frames[0] = image.NewRGBA(image.Rect(0, 0, 3, 3))
+		}},
+		{"frame of the wrong type", func(s *Sheet) {
+			s.entities[0].modes[1].frames[0] = image.NewNRGBA(image.Rect(0, 0, 4, 4))
+		}},
+	} {
+		s := mustSheet(t)
+		c.corrupt(s)
+		if err := s.Validate(); err == nil {
+			t.Fatalf("%s not caught", c.problem)
+		}
+	}
+}
+
+func TestReorderEntities(t *testing.T) {
+	s := mustSheet(t)
+	entities := []*Entity{mustEntity(t, s, 0), mustEntity(t, s, 1), mustEntity(t, s, 2), mustEntity(t, s, 3)}
+	for _, order := range [][]int{{0, 1, 2}, {0, 1, 2, 2}, {0, 1, 2, 4}} {
+		if err := s.ReorderEntities(order); err == nil {
+			t.Fatalf("order %v accepted", order)
+		}
+	}
+
+	order := []int{3, 2, 0, 1}
+	if err := s.ReorderEntities(order); err != nil {
+		t.Fatal(err)
+	}
+	names := s.EntityNames()
+	for idx, old := range order {
+		if e := mustEntity(t, s, idx); e != entities[old] {
+			t.Fatalf("Entity %d is %s, want %s", idx, e.Name(), entities[old].Name())
+		}
+		if e, err := s.GetEntityByName(entities[old].Name()); err != nil || e != entities[old] {
+			t.Fatalf("Entity %s not found by name", entities[old].Name())
+		}
+		if names[idx] != entities[old].Name() {
+			t.Fatalf("EntityNames gave %v, not in the new order", names)
+		}
+	}
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemoryUsage(t *testing.T) {
+	s := mustSheet(t)
+	// Every frame is a view into the one 24x32 sheet buffer.
+	const sheetBytes = 24 * 32 * 4
+	shared, owned := s.MemoryUsage()
+	if shared != sheetBytes || owned != 0 {
+		t.Fatalf("fresh Sheet uses %d shared and %d owned bytes, want %d and 0", shared, owned, sheetBytes)
+	}
+
+	// Add a standalone frame, and replace Mode 1 with a grayscale copy of Mode 0 (whose 5 frames are standalone).
+	e := mustEntity(t, s, 0)
+	m := mustMode(t, e, 0)
+	if err := m.InsertFrame(0, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	e.modes[1] = m.Grayscale()
+	// A frame used twice is counted once.
+	e.modes[2].frames[1] = m.frames[0]
+	const frameBytes = 4 * 4 * 4
+	shared, owned = s.MemoryUsage()
+	if shared != sheetBytes || owned != frameBytes+5*frameBytes {
+		t.Fatalf("edited Sheet uses %d shared and %d owned bytes, want %d and %d", shared, owned, sheetBytes,
+			6*frameBytes)
+	}
+	if s.MemoryBytes() != shared+owned {
+		t.Fatalf("MemoryBytes is %d, want %d", s.MemoryBytes(), shared+owned)
+	}
+}
+
+func TestFindDuplicateEntities(t *testing.T) {
+	// A row of Entities with one Mode of 3 solid 2x2 frames each, of the given red values.
+	reds := [][3]uint8{
+		{10, 20, 30},
+		{0, 0, 0},
+		{10, 20, 30},
+		{10, 20, 31}, // differs from 0 and 2 only in the last frame
+		{30, 20, 10}, // the frames of 0 and 2, in a different order
+		{0, 0, 0},
+	}
+	d := SheetDimensions{EntitiesPerRow: len(reds), EntitiesPerColumn: 1, ModesPerEntity: 1, FramesPerAnimation: 3,
+		SpriteWidth: 2, SpriteHeight: 2}
+	img := image.NewRGBA(image.Rect(0, 0, 2*len(reds), 6))
+	for n, frames := range reds {
+		for f, r := range frames {
+			draw.Draw(img, image.Rect(2*n, 2*f, 2*n+2, 2*f+2), image.NewUniform(color.RGBA{R: r, A: 255}),
+				image.Point{}, draw.Src)
+		}
+	}
+	s, err := NewSheet(img, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]int{{0, 2}, {1, 5}}
+	for n := 0; n < 2; n++ {
+		if groups := s.FindDuplicateEntities(); !reflect.DeepEqual(groups, want) {
+			t.Fatalf("FindDuplicateEntities gave %v, want %v", groups, want)
+		}
+	}
+}
+
+func TestNewSheetWithNamesTooManyModeNames(t *testing.T) {
+	d := basicDims()
+	tooMany := []string{"a", "b", "c", "d"}
+	s, err := NewSheetWithNames(testSheetImage(d), d, []EntityAndModeNames{{EntityName: "e", ModeNames: tooMany}})
+	if s != nil || err == nil || !strings.Contains(err.Error(), "more entries (4) than dimensions.ModesPerEntity (3)") {
+		t.Fatalf("NewSheetWithNames returned %v, %v; want a too many mode names error", s, err)
+	}
+	s, err = NewSheetWithEntityAndSharedModeNames(testSheetImage(d), d, []string{"e"}, tooMany)
+	if s != nil || err == nil || !strings.Contains(err.Error(), "more entries (4) than dimensions.ModesPerEntity (3)") {
+		t.Fatalf("NewSheetWithEntityAndSharedModeNames returned %v, %v; want a too many mode names error", s, err)
+	}
+	// Exactly ModesPerEntity names is fine.
+	if _, err := NewSheetWithNames(testSheetImage(d), d, []EntityAndModeNames{{EntityName: "e", ModeNames: tooMany[:3]}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNormalizeOrigins(t *testing.T) {
+	d := basicDims()
+	img := testSheetImage(d)
+	shared, err := NewSheet(img, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.NormalizeOrigins = true
+	normalized, err := NewSheet(img, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSamePixels(t, normalized, shared)
+	for n := 0; n < normalized.EntityCount(); n++ {
+		e := mustEntity(t, normalized, n)
+		for j := 0; j < e.ModeCount(); j++ {
+			m := mustMode(t, e, j)
+			for f := 0; f < m.FrameCount(); f++ {
+				if min := m.GetFrameWrapped(f).Bounds().Min; min != (image.Point{}) {
+					t.Fatalf("normalized frame %d of Entity %d Mode %d has origin %v", f, n, j, min)
+				}
+			}
+		}
+	}
+	// Placement is the same either way.
+	canvases := make([]*image.RGBA, 2)
+	for n, s := range []*Sheet{shared, normalized} {
+		canvases[n] = image.NewRGBA(image.Rect(0, 0, 10, 10))
+		mustInstance(t, mustEntity(t, s, 3), 2).PlaceOn(canvases[n], image.Pt(3, 3))
+	}
+	if !reflect.DeepEqual(canvases[0].Pix, canvases[1].Pix) {
+		t.Error("placing a normalized frame differs from placing a shared one")
+	}
+
+	// By default frames are views of the sheet image, but normalized frames are copies.
+	black := color.RGBA{A: 255}
+	img.SetRGBA(4*4, 6*4, black)
+	if frame := mustMode(t, mustEntity(t, shared, 3), 1).GetFrameWrapped(2); frame.Bounds().Min != image.Pt(4*4, 6*4) ||
+		frame.At(4*4, 6*4) != black {
+		t.Error("shared frame is not a view of the sheet image")
+	}
+	if c := mustMode(t, mustEntity(t, normalized, 3), 1).GetFrameWrapped(2).At(0, 0); c != cellColor(4, 6) {
+		t.Errorf("normalized frame pixel changed with the sheet image to %v", c)
+	}
+}
+
+func TestLazy(t *testing.T) {
+	d := basicDims()
+	img := testSheetImage(d)
+	// Make Entity 0's Mode 1 not fully opaque.
+	img.SetRGBA(4, 0, color.RGBA{})
+	for _, normalize := range []bool{false, true} {
+		d.NormalizeOrigins, d.Lazy = normalize, false
+		eager, err := NewSheet(img, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.Lazy = true
+		lazy, err := NewSheet(img, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for n := 0; n < lazy.EntityCount(); n++ {
+			for j := 0; j < d.ModesPerEntity; j++ {
+				if m := mustMode(t, mustEntity(t, lazy, n), j); m.frames != nil || m.FrameCount() != d.FramesPerAnimation {
+					t.Fatalf("Entity %d Mode %d frames were not deferred, or it has %d frames", n, j, m.FrameCount())
+				}
+			}
+		}
+
+		// A lazily fetched frame is the one NewSheet would have extracted up front, and fetching it extracts only its
+		// own Mode's frames.
+		m, want := mustMode(t, mustEntity(t, lazy, 2), 1), mustMode(t, mustEntity(t, eager, 2), 1)
+		for f := 0; f < d.FramesPerAnimation; f++ {
+			frame, _ := m.GetFrame(f)
+			wantFrame, _ := want.GetFrame(f)
+			if frame.Bounds() != wantFrame.Bounds() || !rgbaEqual(frame.(*image.RGBA), wantFrame.(*image.RGBA)) {
+				t.Fatalf("normalize %t: lazily fetched frame %d differs from the eagerly extracted one", normalize, f)
+			}
+		}
+		if mustMode(t, mustEntity(t, lazy, 2), 0).frames != nil {
+			t.Fatalf("normalize %t: fetching a frame extracted another Mode's frames", normalize)
+		}
+
+		// Placing a frame determines the Mode's opacity.
+		i := mustInstance(t, mustEntity(t, lazy, 1), 0)
+		i.PlaceOn(image.NewRGBA(image.Rect(0, 0, 4, 4)), image.Point{})
+		if !i.Mode.fullyOpaque {
+			t.Error("placing a frame of a lazily loaded opaque Mode did not determine its opacity")
+		}
+		for n := 0; n < lazy.EntityCount(); n++ {
+			for j := 0; j < d.ModesPerEntity; j++ {
+				m, want := mustMode(t, mustEntity(t, lazy, n), j), mustMode(t, mustEntity(t, eager, n), j)
+				if m.FullyOpaque() != want.FullyOpaque() || m.FullyOpaque() != (n != 0 || j != 1) {
+					t.Fatalf("Entity %d Mode %d FullyOpaque is %t, want %t", n, j, m.FullyOpaque(), want.FullyOpaque())
+				}
+			}
+		}
+		assertSamePixels(t, lazy, eager)
+	}
+}
+
+func TestLazyConcurrent(t *testing.T) {
+	d := basicDims()
+	d.Lazy = true
+	s, err := NewSheet(testSheetImage(d), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := mustMode(t, mustEntity(t, s, 0), 0)
+	var wg sync.WaitGroup
+	for n := 0; n < 8; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if frame, err := m.GetFrame(1); err != nil || frame == nil || !m.FullyOpaque() {
+				t.Error("frame of a lazily loaded Mode not available concurrently")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRemoveEntity(t *testing.T) {
+	s := mustSheet(t)
+	names := s.EntityNames()
+	removed := mustEntity(t, s, 1)
+	i := mustInstance(t, removed, 0)
+	if err := s.RemoveEntity(names[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{names[0], names[2], names[3]}
+	if got := s.EntityNames(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Entity names are %v, want %v", got, want)
+	}
+	for idx, name := range want {
+		e := mustEntity(t, s, idx)
+		if byName, err := s.GetEntityByName(name); err != nil || byName != e || e.Name() != name {
+			t.Fatalf("Entity %d is %s, but %s is %v", idx, e.Name(), name, byName)
+		}
+	}
+	if _, err := s.GetEntityByName(names[1]); err == nil {
+		t.Error("removed Entity is still found by name")
+	}
+	if err := s.RemoveEntity(names[1]); err == nil {
+		t.Error("removing a nonexistent Entity did not fail")
+	}
+	// Instances of the removed Entity still work.
+	if i.Entity != removed || i.Frame() == nil {
+		t.Error("Instance of the removed Entity no longer works")
+	}
+
+	// Removing the last Entity needs no re-indexing.
+	if err := s.RemoveEntity(names[3]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Validate(); err != nil || s.EntityCount() != 2 {
+		t.Fatalf("%d Entities after removing the last (%v), want 2", s.EntityCount(), err)
+	}
+}
+
+func TestIndexAt(t *testing.T) {
+	// A 3 wide, 2 high grid, so that mixing up rows and columns is caught.
+	d := basicDims()
+	d.EntitiesPerRow = 3
+	s, err := NewSheet(testSheetImage(d), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for idx := 0; idx < 6; idx++ {
+		row, col := s.RowColOf(idx)
+		if row != idx/3 || col != idx%3 {
+			t.Fatalf("RowColOf(%d) is %d, %d, want %d, %d", idx, row, col, idx/3, idx%3)
+		}
+		if got, err := s.IndexAt(row, col); err != nil || got != idx {
+			t.Fatalf("IndexAt(%d, %d) is %d (%v), want %d", row, col, got, err, idx)
+		}
+		// The Entity really was read from that grid position.
+		frame, _ := mustMode(t, mustEntity(t, s, idx), 0).FrameCopy(0)
+		if c := frame.RGBAAt(0, 0); c != cellColor(col*3, row*4) {
+			t.Fatalf("Entity %d is not from row %d, col %d", idx, row, col)
+		}
+	}
+	for _, rc := range [][2]int{{2, 0}, {0, 3}, {-1, 0}, {0, -1}} {
+		if _, err := s.IndexAt(rc[0], rc[1]); err == nil {
+			t.Errorf("IndexAt(%d, %d) outside the grid did not fail", rc[0], rc[1])
+		}
+	}
+	// An index past the end of the grid wraps onto further rows.
+	if row, col := s.RowColOf(7); row != 2 || col != 1 {
+		t.Errorf("RowColOf(7) is %d, %d, want 2, 1", row, col)
+	}
+	if row, col := s.RowColOf(-1); row != -1 || col != -1 {
+		t.Errorf("RowColOf(-1) is %d, %d, want -1, -1", row, col)
+	}
+
+	// Clones keep the grid, but SubSheets have none.
+	if idx, err := s.Clone().IndexAt(1, 2); err != nil || idx != 5 {
+		t.Errorf("Clone IndexAt(1, 2) is %d (%v), want 5", idx, err)
+	}
+	sub, err := s.SubSheet(s.EntityNames()[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sub.IndexAt(0, 0); err == nil {
+		t.Error("IndexAt of a SubSheet did not fail")
+	}
+}
+
+func TestDimensions(t *testing.T) {
+	d := basicDims()
+	if got := mustSheet(t).Dimensions(); got.EntitiesPerRow != 2 || got.EntitiesPerColumn != 2 || got.ModesPerEntity != 3 ||
+		got.FramesPerAnimation != 4 || got.SpriteWidth != 4 || got.SpriteHeight != 4 {
+		t.Fatalf("Dimensions are %+v, want those of basicDims", got)
+	}
+
+	// Resized sprites, with margins and spacing, are reported at the resized size.
+	img := padSheet(testSheetImage(d), 4, 4, 1, 2)
+	d.Margin, d.Spacing = 1, 2
+	d.ResizeWidth, d.ResizeHeight = 8, 8
+	key := color.RGBA{A: 255}
+	d.ColorKey = &key
+	s, err := NewSheet(img, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := s.Dimensions()
+	if got.SpriteWidth != 8 || got.SpriteHeight != 8 || got.Margin != 2 || got.Spacing != 4 ||
+		got.EntitiesPerRow != 2 || got.FramesPerAnimation != 4 {
+		t.Fatalf("resized Dimensions are %+v, want 8x8 sprites, margin 2 and spacing 4", got)
+	}
+	if size := mustMode(t, mustEntity(t, s, 3), 2).SpriteSize(); size != image.Rect(0, 0, got.SpriteWidth, got.SpriteHeight) {
+		t.Fatalf("frames are %v, but Dimensions are %dx%d", size, got.SpriteWidth, got.SpriteHeight)
+	}
+	// The result is a copy.
+	got.ColorKey.R = 1
+	if s.Dimensions().ColorKey.R != 0 {
+		t.Fatal("modifying the returned ColorKey modified the Sheet's")
+	}
+
+	sub, err := s.SubSheet(s.EntityNames()[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sub.Dimensions(); got.EntitiesPerRow != 0 || got.SpriteWidth != 0 {
+		t.Errorf("SubSheet Dimensions are %+v, want zero", got)
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	s := mustSheet(t)
+	hash := s.ContentHash()
+	if len(hash) != 64 || strings.Trim(hash, "0123456789abcdef") != "" {
+		t.Fatalf("ContentHash %q is not a hex SHA-256 digest", hash)
+	}
+	// An identical Sheet, a Clone and repeated calls hash the same.
+	for n := 0; n < 3; n++ {
+		if h := mustSheet(t).ContentHash(); h != hash {
+			t.Fatalf("identical Sheet hashed %s, want %s", h, hash)
+		}
+	}
+	if h := s.Clone().ContentHash(); h != hash {
+		t.Fatalf("Clone hashed %s, want %s", h, hash)
+	}
+	// So does one with the same sprites in a different sheet layout.
+	d := basicDims()
+	d.Margin = 1
+	margined, err := NewSheet(padSheet(testSheetImage(d), 4, 4, 1, 0), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := margined.ContentHash(); h != hash {
+		t.Fatalf("Sheet with a margin hashed %s, want %s", h, hash)
+	}
+
+	changes := map[string]func(s *Sheet) error{
+		"renaming an Entity": func(s *Sheet) error { return s.RenameEntity(s.EntityNames()[1], "renamed") },
+		"renaming a Mode": func(s *Sheet) error {
+			e := mustEntity(t, s, 1)
+			return e.RenameMode(e.ModeNames()[0], "renamed")
+		},
+		"removing a frame":    func(s *Sheet) error { return mustMode(t, mustEntity(t, s, 1), 0).RemoveFrame(0) },
+		"reordering Entities": func(s *Sheet) error { return s.ReorderEntities([]int{1, 0, 2, 3}) },
+		"changing a pixel": func(s *Sheet) error {
+			frame := mustMode(t, mustEntity(t, s, 3), 2).frames[3].(*image.RGBA)
+			frame.SetRGBA(frame.Rect.Max.X-1, frame.Rect.Max.Y-1, color.RGBA{A: 255})
+			return nil
+		},
+	}
+	for name, change := range changes {
+		s := mustSheet(t)
+		if err := change(s); err != nil {
+			t.Fatal(err)
+		}
+		if s.ContentHash() == hash {
+			t.Errorf("%s did not change the hash", name)
+		}
+	}
+}
+
+func TestBlankModes(t *testing.T) {
+	// Two Entities of 3 Modes of 2 frames. Entity 0's Mode 1 is blank; its Modes 0 and 2 have a pixel in one frame
+	// only. All of Entity 1's Modes but Mode 0 are blank.
+	img := image.NewRGBA(image.Rect(0, 0, 24, 8))
+	for _, p := range []image.Point{{1, 1}, {9, 6}, {13, 2}} {
+		img.SetRGBA(p.X, p.Y, color.RGBA{A: 1})
+	}
+	d := SheetDimensions{EntitiesPerRow: 2, EntitiesPerColumn: 1, ModesPerEntity: 3, FramesPerAnimation: 2,
+		SpriteWidth: 4, SpriteHeight: 4}
+	s, err := NewSheet(img, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.BlankModes(), map[int][]int{0: {1}, 1: {1, 2}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("BlankModes is %v, want %v", got, want)
+	}
+	if got := mustSheet(t).BlankModes(); len(got) != 0 {
+		t.Fatalf("BlankModes of an opaque Sheet is %v, want none", got)
+	}
+}
+
+func TestNameFormatter(t *testing.T) {
+	if got := mustSheet(t).EntityNames(); !reflect.DeepEqual(got, []string{"Entity0", "Entity1", "Entity2", "Entity3"}) {
+		t.Fatalf("default Entity names are %v", got)
+	}
+	if got := mustEntity(t, mustSheet(t), 0).ModeNames(); !reflect.DeepEqual(got, []string{"Mode0", "Mode1", "Mode2"}) {
+		t.Fatalf("default Mode names are %v", got)
+	}
+
+	d := basicDims()
+	d.NameFormatter = func(kind string, index int) string { return fmt.Sprintf("%s-%d", kind, index) }
+	s, err := NewSheet(testSheetImage(d), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.EntityNames(); !reflect.DeepEqual(got, []string{"entity-0", "entity-1", "entity-2", "entity-3"}) {
+		t.Fatalf("formatted Entity names are %v", got)
+	}
+	if got := mustEntity(t, s, 3).ModeNames(); !reflect.DeepEqual(got, []string{"mode-0", "mode-1", "mode-2"}) {
+		t.Fatalf("formatted Mode names are %v", got)
+	}
+	// Given Entity names are used, with formatted Mode names.
+	s, err = NewSheetWithEntityNames(testSheetImage(d), d, []string{"a", "b", "c", "d"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mustEntity(t, s, 1).GetModeByName("mode-2"); err != nil || s.EntityNames()[1] != "b" {
+		t.Fatalf("Entity names %v, Mode lookup error %v", s.EntityNames(), err)
+	}
+
+	// A formatter whose names collide is an error, rather than leaving Entities or Modes unreachable by name.
+	d.NameFormatter = func(kind string, index int) string { return fmt.Sprintf("%s-%d", kind, index%2) }
+	if s, err := NewSheet(testSheetImage(d), d); s != nil || err == nil || !strings.Contains(err.Error(), "entity-0") {
+		t.Fatalf("NewSheet with colliding names returned %v, %v; want a duplicate name error", s, err)
+	}
+	s, err = NewSheetWithEntityNames(testSheetImage(d), d, []string{"a", "b"})
+	if s != nil || err == nil || !strings.Contains(err.Error(), "mode-0") {
+		t.Fatalf("NewSheetWithEntityNames with colliding Mode names returned %v, %v; want a duplicate name error", s, err)
+	}
+	// Names given by the caller are checked the same way.
+	s, err = NewSheetWithNames(testSheetImage(d), d, []EntityAndModeNames{{EntityName: "e"}, {EntityName: "e"}})
+	if s != nil || err == nil {
+		t.Fatalf("NewSheetWithNames with a repeated Entity name returned %v, %v; want an error", s, err)
+	}
+}
+
+func TestSpriteAt(t *testing.T) {
+	s := mustSheet(t)
+	e := mustEntity(t, s, 1)
+	want, _ := mustMode(t, e, 2).GetFrame(3)
+	if got, err := s.SpriteAt(1, 2, 3); err != nil || got != want {
+		t.Fatalf("SpriteAt(1, 2, 3) is not Entity 1 Mode 2 frame 3 (%v)", err)
+	}
+	if got, err := s.SpriteAtNamed(e.Name(), e.ModeNames()[2], 3); err != nil || got != want {
+		t.Fatalf("SpriteAtNamed is not Entity 1 Mode 2 frame 3 (%v)", err)
+	}
+	// Errors name the index which is out of range.
+	for _, c := range []struct {
+		entity, mode, frame int
+		name                string
+	}{{4, 0, 0, "entity"}, {-1, 0, 0, "entity"}, {0, 3, 0, "mode"}, {0, 0, 4, "frame"}, {0, 0, -1, "frame"}} {
+		if _, err := s.SpriteAt(c.entity, c.mode, c.frame); err == nil || !strings.HasPrefix(err.Error(), c.name) {
+			t.Errorf("SpriteAt(%d, %d, %d) error is %v, want one about the %s", c.entity, c.mode, c.frame, err, c.name)
+		}
+	}
+	if _, err := s.SpriteAtNamed("nonexistent", e.ModeNames()[0], 0); err == nil || !strings.HasPrefix(err.Error(), "entity") {
+		t.Errorf("SpriteAtNamed of a nonexistent Entity error is %v", err)
+	}
+	if _, err := s.SpriteAtNamed(e.Name(), "nonexistent", 0); err == nil || !strings.HasPrefix(err.Error(), "mode") {
+		t.Errorf("SpriteAtNamed of a nonexistent Mode error is %v", err)
+	}
+}
+
+func TestInferDimensions(t *testing.T) {
+	for _, framesRunRows := range []bool{false, true} {
+		d := basicDims()
+		d.EntitiesPerRow, d.EntitiesPerColumn = 3, 2
+		d.FramesRunRows = framesRunRows
+		img := testSheetImage(d)
+		got, err := InferDimensions(img, d.SpriteWidth, d.SpriteHeight, d.ModesPerEntity, d.FramesPerAnimation, framesRunRows)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.EntitiesPerRow != 3 || got.EntitiesPerColumn != 2 || got.ModesPerEntity != 3 || got.FramesPerAnimation != 4 ||
+			got.FramesRunRows != framesRunRows || got.SpriteWidth != 4 || got.SpriteHeight != 4 {
+			t.Fatalf("FramesRunRows %t: inferred %+v", framesRunRows, got)
+		}
+		s, err := NewSheet(img, got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := NewSheet(img, d)
+		assertSamePixels(t, s, want)
+	}
+
+	for _, c := range []struct {
+		size                   image.Point
+		spriteW, modes, frames int
+	}{{image.Pt(13, 16), 4, 3, 4}, {image.Pt(12, 15), 4, 3, 4}, {image.Pt(0, 16), 4, 3, 4}, {image.Pt(12, 16), 0, 3, 4},
+		{image.Pt(12, 16), 4, 0, 4}} {
+		if _, err := InferDimensions(image.NewRGBA(image.Rectangle{Max: c.size}), c.spriteW, 4, c.modes, c.frames, false); err == nil {
+			t.Errorf("InferDimensions of a %v image of %d wide sprites, %d Modes of %d frames did not fail", c.size, c.spriteW, c.modes, c.frames)
+		}
+	}
+}
+
+func TestCellRect(t *testing.T) {
+	if got, want := CellRect(basicDims(), 0, 1, 2), image.Rect(4, 8, 8, 12); got != want {
+		t.Fatalf("CellRect = %v, want %v", got, want)
+	}
+	// CellRect gives the rectangles the Sheet's frames are read from, in either orientation.
+	for _, framesRunRows := range []bool{false, true} {
+		d := basicDims()
+		d.FramesRunRows = framesRunRows
+		d.Margin, d.Spacing = 1, 2
+		d.OriginX, d.OriginY = 3, 5
+		d.init()
+		size := d.imageSize()
+		img := image.NewRGBA(image.Rect(10, 10, 10+size.X+d.OriginX, 10+size.Y+d.OriginY))
+		s, err := NewSheet(img, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range [][3]int{{0, 0, 0}, {3, 2, 1}, {1, 1, 3}, {2, 0, 2}} {
+			frame, err := s.SpriteAt(c[0], c[1], c[2])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := CellRect(d, c[0], c[1], c[2]).Add(img.Rect.Min); got != frame.Bounds() {
+				t.Fatalf("FramesRunRows %v: CellRect%v = %v, want %v", framesRunRows, c, got, frame.Bounds())
+			}
+		}
+	}
+}
+
+func TestNewRandomInstance(t *testing.T) {
+	s := mustSheet(t)
+	names := s.EntityNames()
+	r := rand.New(rand.NewSource(42))
+	// Entity 1 is not in weights, so has a weight of 1.
+	weights := map[string]float64{names[0]: 5, names[2]: 0, names[3]: 2}
+	want := map[string]float64{names[0]: 5.0 / 8, names[1]: 1.0 / 8, names[2]: 0, names[3]: 2.0 / 8}
+	counts := make(map[string]int)
+	const draws = 80000
+	for n := 0; n < draws; n++ {
+		i, err := s.NewRandomInstance(weights, 1, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[i.Entity.Name()]++
+	}
+	for name, p := range want {
+		if got := float64(counts[name]) / draws; math.Abs(got-p) > 0.01 {
+			t.Fatalf("%s chosen %.3f of the time, want %.3f", name, got, p)
+		}
+	}
+
+	for _, c := range []struct {
+		name        string
+		weights     map[string]float64
+		initialMode int
+	}{
+		{"unknown Entity", map[string]float64{"nope": 1}, 0},
+		{"negative weight", map[string]float64{names[0]: -1}, 0},
+		{"NaN weight", map[string]float64{names[0]: math.NaN()}, 0},
+		{"every Entity excluded", map[string]float64{names[0]: 0, names[1]: 0, names[2]: 0, names[3]: 0}, 0},
+		{"no such Mode", nil, 7},
+	} {
+		if _, err := s.NewRandomInstance(c.weights, c.initialMode, r); err == nil {
+			t.Errorf("%s: no error", c.name)
+		}
+	}
+}
+
+// streamedImage is a sheet image which, like one decoded region by region on demand, can only be read through
+// SubImage: reading it in full panics. Cell-sized SubImages are solid images of fill (of type *image.NRGBA if nrgba is
+// set), and cells counts how many were requested.
+type streamedImage struct {
+	rect  image.Rectangle
+	fill  uint8
+	nrgba bool
+	cells *int32
+}
+
+func (s streamedImage) ColorModel() color.Model     { panic("streamedImage read in full") }
+func (s streamedImage) Bounds() image.Rectangle     { return s.rect }
+func (s streamedImage) At(x, y int) color.Color     { panic("streamedImage read in full") }
+func (s streamedImage) Set(x, y int, c color.Color) { panic("streamedImage modified") }
+func (s streamedImage) PixOffset(x, y int) int      { panic("streamedImage read in full") }
+
+func (s streamedImage) SubImage(r image.Rectangle) image.Image {
+	if r.Dx() > 4 || r.Dy() > 4 {
+		s.rect = r
+		return s
+	}
+	atomic.AddInt32(s.cells, 1)
+	var pix []uint8
+	var img image.Image
+	if s.nrgba {
+		nrgba := image.NewNRGBA(r)
+		pix, img = nrgba.Pix, nrgba
+	} else {
+		rgba := image.NewRGBA(r)
+		pix, img = rgba.Pix, rgba
+	}
+	for n := range pix {
+		pix[n] = s.fill
+	}
+	return img
+}
+
+func TestTrustSubImager(t *testing.T) {
+	for _, nrgba := range []bool{false, true} {
+		var cells int32
+		d := basicDims()
+		d.TrustSubImager = true
+		d.OriginX = 2
+		layout := d
+		layout.init()
+		size := layout.imageSize()
+		img := streamedImage{rect: image.Rect(0, 0, size.X+d.OriginX, size.Y), fill: 200, nrgba: nrgba, cells: &cells}
+		// streamedImage panics if the factory copies it in full.
+		s, err := NewSheet(img, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cells != 2*2*3*4 {
+			t.Fatalf("nrgba %v: %d cells read, want one per frame", nrgba, cells)
+		}
+		frame, err := s.SpriteAt(1, 1, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := color.RGBA{200, 200, 200, 200}
+		if nrgba {
+			// 200 premultiplied by an alpha of 200.
+			want = color.RGBA{157, 157, 157, 200}
+		}
+		if rgba, ok := frame.(*image.RGBA); !ok || rgba.Rect.Size() != (image.Point{X: 4, Y: 4}) ||
+			rgba.RGBAAt(rgba.Rect.Min.X, rgba.Rect.Min.Y) != want {
+			t.Fatalf("nrgba %v: frame is not a 4x4 *image.RGBA of %v", nrgba, want)
+		}
+
+		d.ColorKey = &color.RGBA{}
+		if _, err := NewSheet(img, d); err == nil {
+			t.Fatalf("nrgba %v: TrustSubImager was combined with ColorKey", nrgba)
+		}
+	}
+}
+
+func TestModeIndices(t *testing.T) {
+	for _, framesRunRows := range []bool{false, true} {
+		d := basicDims()
+		d.FramesRunRows = framesRunRows
+		img := testSheetImage(d)
+		names := []EntityAndModeNames{{EntityName: "a", ModeNames: []string{"x", "y"}, ModeIndices: []int{2, 0}}}
+		s, err := NewSheetWithNames(img, d, names)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := mustEntity(t, s, 0)
+		if got := e.ModeNames(); !reflect.DeepEqual(got, names[0].ModeNames) {
+			t.Fatalf("FramesRunRows %v: ModeNames %q, want %q", framesRunRows, got, names[0].ModeNames)
+		}
+		// Each Mode is read from the sheet's Mode (column, or row) given by its ModeIndices entry.
+		for j, index := range names[0].ModeIndices {
+			for f := 0; f < 4; f++ {
+				want := cellColor(index, f)
+				if framesRunRows {
+					want = cellColor(f, index)
+				}
+				if c := frameColor(t, mustMode(t, e, j), f, 0, 0); c != want {
+					t.Fatalf("FramesRunRows %v: Mode %d frame %d is %v, want %v", framesRunRows, j, f, c, want)
+				}
+			}
+		}
+
+		for _, indices := range [][]int{{0, 0}, {3, 1}, {-1, 0}, {1}} {
+			names[0].ModeIndices = indices
+			if _, err := NewSheetWithNames(img, d, names); err == nil {
+				t.Errorf("FramesRunRows %v: ModeIndices %v accepted", framesRunRows, indices)
+			}
+		}
+	}
+}
+
+func TestTotalFrameCount(t *testing.T) {
+	s := mustSheet(t)
+	e := mustEntity(t, s, 0)
+	if e.TotalFrameCount() != 3*4 || s.TotalFrameCount() != 4*3*4 {
+		t.Fatalf("TotalFrameCount %d for the Entity, %d for the Sheet; want 12, 48", e.TotalFrameCount(),
+			s.TotalFrameCount())
+	}
+	if err := mustMode(t, e, 1).SetFrameCount(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := mustMode(t, mustEntity(t, s, 3), 0).RemoveFrame(0); err != nil {
+		t.Fatal(err)
+	}
+	if e.TotalFrameCount() != 10 || s.TotalFrameCount() != 45 {
+		t.Fatalf("after removing frames, TotalFrameCount %d for the Entity, %d for the Sheet; want 10, 45",
+			e.TotalFrameCount(), s.TotalFrameCount())
+	}
+}
+
+// largeDims returns the layout of a large sheet: a 16x16 grid of Entities, each with 4 Modes of 4 frames of size x
+// size pixels.
+func largeDims(size int) SheetDimensions {
+	return SheetDimensions{EntitiesPerRow: 16, EntitiesPerColumn: 16, ModesPerEntity: 4, FramesPerAnimation: 4,
+		SpriteWidth: size, SpriteHeight: size}
+}
+
+// generateEntitiesSerially returns the Entities of img, generated one at a time, as generateEntities would.
+func generateEntitiesSerially(img *image.RGBA, d SheetDimensions) []*Entity {
+	d.init()
+	entities := make([]*Entity, d.EntitiesPerRow*d.EntitiesPerColumn)
+	for i := range entities {
+		names := EntityAndModeNames{EntityName: d.generateNames("entity", len(entities))[i],
+			ModeNames: d.generateNames("mode", d.ModesPerEntity)}
+		entities[i] = generateEntity(img, d, i, names)
+	}
+	return entities
+}
+
+func TestGenerateEntitiesMatchesSerial(t *testing.T) {
+	d := largeDims(8)
+	img := testSheetImage(d)
+	s, err := NewSheet(img, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial := generateEntitiesSerially(img, d)
+	if s.EntityCount() != len(serial) {
+		t.Fatalf("%d Entities, want %d", s.EntityCount(), len(serial))
+	}
+	for i, want := range serial {
+		got := mustEntity(t, s, i)
+		if got.Name() != want.Name() || !got.pixelsEqual(want) {
+			t.Fatalf("Entity %d (%s) differs from the serially generated %s", i, got.Name(), want.Name())
+		}
+		for j, m := range want.modes {
+			if got.modes[j].Name() != m.Name() || got.modes[j].FullyOpaque() != m.FullyOpaque() {
+				t.Fatalf("Mode %d of Entity %d differs", j, i)
+			}
+		}
+	}
+}
+
+func BenchmarkNewSheet(b *testing.B) {
+	d := largeDims(64)
+	img := testSheetImage(d)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := NewSheet(img, d); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkNewSheetSerial is the serial equivalent of BenchmarkNewSheet, for comparison.
+func BenchmarkNewSheetSerial(b *testing.B) {
+	d := largeDims(64)
+	img := testSheetImage(d)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		generateEntitiesSerially(img, d)
+	}
+}
+
+// BenchmarkNewSheetLazy is BenchmarkNewSheet with SheetDimensions.Lazy set, for comparison. It then fetches one frame,
+// so includes the extraction of the one Mode that needs.
+func BenchmarkNewSheetLazy(b *testing.B) {
+	d := largeDims(64)
+	d.Lazy = true
+	img := testSheetImage(d)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		s, err := NewSheet(img, d)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := mustMode(b, mustEntity(b, s, 0), 0).GetFrame(0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package sprites
+
+import "fmt"
+
+// ModeStateMachine switches an Instance between its Modes in response to named events, according to registered
+// transitions ("from idle, on jump, go to jumping"). Create one with NewModeStateMachine.
+type ModeStateMachine struct {
+	instance *Instance
+
+	// transitions maps from Mode name -> event -> to Mode name.
+	transitions map[string]map[string]string
+	// mustFinish holds the names of Modes which must complete their current cycle before a transition away from them
+	// takes effect.
+	mustFinish map[string]bool
+}
+
+// NewModeStateMachine creates a ModeStateMachine, with no transitions, controlling the Mode of instance.
+func NewModeStateMachine(instance *Instance) *ModeStateMachine {
+	return &ModeStateMachine{
+		instance:    instance,
+		transitions: make(map[string]map[string]string),
+		mustFinish:  make(map[string]bool),
+	}
+}
+
+// AddTransition registers that when event is triggered while the Instance is in the Mode named fromMode, it switches
+// to the Mode named toMode. Registering the same fromMode and event again replaces the previous transition.
+// It is an error if either Mode does not exist in the Instance's Entity.
+func (sm *ModeStateMachine) AddTransition(fromMode, event string, toMode string) error {
+	for _, name := range []string{fromMode, toMode} {
+		if _, ok := sm.instance.modeNamesToIndex[name]; !ok {
+			return fmt.Errorf("mode with name %s does not exist in Entity", name)
+		}
+	}
+	if sm.transitions[fromMode] == nil {
+		sm.transitions[fromMode] = make(map[string]string)
+	}
+	sm.transitions[fromMode][event] = toMode
+	return nil
+}
+
+// SetMustFinish sets whether the Mode named modeName must complete its current cycle (play through to its last frame)
+// before a triggered transition away from it takes effect. By default transitions take effect immediately.
+func (sm *ModeStateMachine) SetMustFinish(modeName string, mustFinish bool) error {
+	if _, ok := sm.instance.modeNamesToIndex[modeName]; !ok {
+		return fmt.Errorf("mode with name %s does not exist in Entity", modeName)
+	}
+	if mustFinish {
+		sm.mustFinish[modeName] = true
+	} else {
+		delete(sm.mustFinish, modeName)
+	}
+	return nil
+}
+
+// Trigger fires event. If a transition is registered for event from the Instance's current Mode, the Instance
+// switches to the transition's Mode, starting from its first frame - immediately, or once the current Mode completes
+// its cycle if it must finish first and the animation is running (a later Trigger before then replaces the pending
+// transition). If the animation is not running - it is stopped, paused, or has Finished - the current cycle would
+// never complete, so the transition takes effect immediately even from a Mode which must finish. The running state
+// is unchanged, except that a Finished animation (e.g. a one-shot; see SetLoopCount) is restarted in the new Mode.
+// Trigger returns whether a transition was found; events with no transition from the current Mode are ignored.
+func (sm *ModeStateMachine) Trigger(event string) bool {
+	sm.instance.mu.Lock()
+	from := sm.instance.Mode
+	sm.instance.mu.Unlock()
+
+	toMode, ok := sm.transitions[from.name][event]
+	if !ok {
+		return false
+	}
+	to, err := sm.instance.GetModeByName(toMode)
+	if err != nil {
+		// The Mode existed at registration; it has since been removed or renamed.
+		return false
+	}
+	sm.instance.transition(to, sm.mustFinish[from.name])
+	return true
+}
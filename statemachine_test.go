@@ -0,0 +1,98 @@
+package sprites
+
+import (
+	"testing"
+)
+
+// newTestStateMachine returns a running Instance of a basicDims Entity, with a state machine whose graph is:
+// idle (Mode0) -jump-> jumping (Mode1) -land-> landing (Mode2) -rest-> idle, where landing must finish.
+func newTestStateMachine(t *testing.T) (*Instance, *ModeStateMachine) {
+	t.Helper()
+	i := mustInstance(t, mustEntity(t, mustSheet(t), 0), 0)
+	i.StartAnimation()
+	sm := NewModeStateMachine(i)
+	idle, jumping, landing := DefaultNameFormatter("mode", 0), DefaultNameFormatter("mode", 1), DefaultNameFormatter("mode", 2)
+	for _, tr := range [][3]string{{idle, "jump", jumping}, {jumping, "land", landing}, {landing, "rest", idle}} {
+		if err := sm.AddTransition(tr[0], tr[1], tr[2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sm.SetMustFinish(landing, true); err != nil {
+		t.Fatal(err)
+	}
+	return i, sm
+}
+
+// assertMode fails t if i's current Mode is not the one with index mode, or it is not on frame.
+func assertMode(t *testing.T, i *Instance, mode, frame int) {
+	t.Helper()
+	if got := i.Mode.Name(); got != DefaultNameFormatter("mode", mode) || i.CurrentFrameIndex() != frame {
+		t.Fatalf("in frame %d of %s, want frame %d of %s", i.CurrentFrameIndex(), got, frame,
+			DefaultNameFormatter("mode", mode))
+	}
+}
+
+func TestModeStateMachine(t *testing.T) {
+	i, sm := newTestStateMachine(t)
+	if err := sm.AddTransition(DefaultNameFormatter("mode", 0), "fly", "flying"); err == nil {
+		t.Fatal("transition to an unknown Mode registered")
+	}
+
+	// Events without a transition from the current Mode are ignored.
+	if sm.Trigger("land") || sm.Trigger("unknown") {
+		t.Fatal("event without a transition reported as found")
+	}
+	i.Frame()
+	assertMode(t, i, 0, 1)
+
+	// Transitions take effect immediately, from the first frame.
+	if !sm.Trigger("jump") {
+		t.Fatal("jump not found")
+	}
+	assertMode(t, i, 1, 0)
+	sm.Trigger("land")
+	assertMode(t, i, 2, 0)
+
+	// landing must finish its cycle before resting.
+	if !sm.Trigger("rest") {
+		t.Fatal("rest not found")
+	}
+	for frame := 0; frame < 4; frame++ {
+		assertMode(t, i, 2, frame)
+		i.Frame()
+	}
+	assertMode(t, i, 0, 0)
+}
+
+func TestModeStateMachineNotRunning(t *testing.T) {
+	// A paused animation never completes its cycle, so the transition is immediate, and it stays paused.
+	i, sm := newTestStateMachine(t)
+	sm.Trigger("jump")
+	sm.Trigger("land")
+	i.Frame()
+	i.PauseAnimation()
+	sm.Trigger("rest")
+	assertMode(t, i, 0, 0)
+	if i.State() != Paused {
+		t.Fatalf("State() = %v, want %v", i.State(), Paused)
+	}
+
+	// A finished one-shot has completed its cycle; it is restarted in the new Mode.
+	i, sm = newTestStateMachine(t)
+	sm.Trigger("jump")
+	sm.Trigger("land")
+	i.SetLoopCount(1)
+	for k := 0; k < 4; k++ {
+		i.Frame()
+	}
+	if !i.Finished() {
+		t.Fatal("one-shot did not finish")
+	}
+	sm.Trigger("rest")
+	assertMode(t, i, 0, 0)
+	if i.State() != Playing {
+		t.Fatalf("State() = %v, want %v", i.State(), Playing)
+	}
+	i.Frame()
+	assertMode(t, i, 0, 1)
+}
@@ -0,0 +1,160 @@
+package sprites
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	ccsl_graphics "github.com/HaileyStorm/CCSL_go/graphics"
+)
+
+// NewSheetFromTexturePacker creates a Sheet from a texture atlas image packed by TexturePacker, along with its JSON
+// data file (in either the "JSON (Hash)" or "JSON (Array)" format). Frames may be anywhere in the image, and trimmed
+// frames are restored to their original size, but rotated frames are not supported.
+// Frames are grouped into Entities and Modes by their names, after removing any file extension, as follows:
+//   - "entity/mode/n", where n is an integer (e.g. "hero/walk/0001"), is frame n of Mode "mode" of Entity "entity".
+//     Each Mode's frames are ordered by n.
+//   - "entity/mode" (e.g. "ui/button_pressed") is the only frame of Mode "mode" of Entity "entity".
+//   - "entity" (e.g. "logo") is the only frame of Mode "Mode0" of Entity "entity".
+//
+// Entity names may themselves contain "/", so for example "ui/menu/button/pressed" is the Mode "pressed" of Entity
+// "ui/menu/button". Entities and Modes are indexed in the order they first appear. All the frames of an Entity
+// must be the same size, but different Entities may have different sizes.
+func NewSheetFromTexturePacker(img ccsl_graphics.SubImager, atlasData []byte) (*Sheet, error) {
+	var data struct {
+		Frames json.RawMessage `json:"frames"`
+	}
+	if err := json.Unmarshal(atlasData, &data); err != nil {
+		return nil, fmt.Errorf("invalid TexturePacker JSON: %w", err)
+	}
+	frames, err := decodeAtlasFrames(data.Frames)
+	if err != nil {
+		return nil, err
+	}
+	if len(frames) == 0 {
+		return nil, errors.New("TexturePacker JSON has no frames")
+	}
+
+	rgba := atlasRGBA(img)
+	sheet := &Sheet{
+		entities:           make(map[int]*Entity),
+		entityNamesToIndex: make(map[string]int),
+	}
+	// frameNumbers holds the frame number of each frame of the numbered Modes, for sorting them once all are loaded.
+	frameNumbers := make(map[*Mode][]int)
+	for n, f := range frames {
+		if f.Filename == "" {
+			return nil, fmt.Errorf("TexturePacker frame %d has no name", n)
+		}
+		if f.Rotated {
+			return nil, fmt.Errorf("TexturePacker frame %s is rotated, which is not supported", f.Filename)
+		}
+		rect := f.Frame.rect().Add(rgba.Rect.Min)
+		if rect.Empty() || !rect.In(rgba.Rect) {
+			return nil, fmt.Errorf("TexturePacker frame %s (%v) must be non-empty and within the image (%v)",
+				f.Filename, rect.Sub(rgba.Rect.Min), rgba.Rect.Size())
+		}
+		var sprite *image.RGBA
+		if f.Trimmed {
+			sprite = image.NewRGBA(image.Rect(0, 0, f.SourceSize.W, f.SourceSize.H))
+			at := f.SpriteSourceSize.rect().Min
+			if !rect.Sub(rect.Min).Add(at).In(sprite.Rect) {
+				return nil, fmt.Errorf("TexturePacker frame %s trimmed region (%v at %v) is not within its source size "+
+					"(%v)", f.Filename, rect.Size(), at, sprite.Rect.Size())
+			}
+			draw.Draw(sprite, rect.Sub(rect.Min).Add(at), rgba, rect.Min, draw.Src)
+		} else {
+			sprite = rgba.SubImage(rect).(*image.RGBA)
+		}
+		size := sprite.Rect.Size()
+
+		entityName, modeName, number, numbered := texturePackerFrameName(f.Filename)
+		idx, ok := sheet.entityNamesToIndex[entityName]
+		if !ok {
+			idx = len(sheet.entities)
+			sheet.entities[idx] = &Entity{
+				name:             entityName,
+				modes:            make(map[int]*Mode),
+				modeNamesToIndex: make(map[string]int),
+			}
+			sheet.entityNamesToIndex[entityName] = idx
+		}
+		entity := sheet.entities[idx]
+		if len(entity.modes) > 0 && entity.modes[0].spriteSize.Size() != size {
+			return nil, fmt.Errorf("TexturePacker frame %s: %w (that of Entity %s)", f.Filename,
+				&ErrFrameSizeMismatch{Got: image.Rectangle{Max: size}, Want: entity.modes[0].spriteSize}, entityName)
+		}
+		modeIdx, ok := entity.modeNamesToIndex[modeName]
+		if !ok {
+			modeIdx = len(entity.modes)
+			entity.modes[modeIdx] = newMode(modeName, image.Rectangle{Max: size})
+			entity.modeNamesToIndex[modeName] = modeIdx
+		}
+		mode := entity.modes[modeIdx]
+		if len(mode.frames) > 0 && (!numbered || frameNumbers[mode] == nil) {
+			return nil, fmt.Errorf("TexturePacker frame %s duplicates Mode %s of Entity %s; only numbered frames "+
+				"(entity/mode/n) may share a Mode", f.Filename, modeName, entityName)
+		}
+		if numbered {
+			for _, other := range frameNumbers[mode] {
+				if other == number {
+					return nil, fmt.Errorf("TexturePacker frame %s duplicates frame %d of Mode %s of Entity %s",
+						f.Filename, number, modeName, entityName)
+				}
+			}
+			frameNumbers[mode] = append(frameNumbers[mode], number)
+		}
+		mode.frames = append(mode.frames, sprite)
+	}
+
+	for _, entity := range sheet.entities {
+		for _, mode := range entity.modes {
+			if numbers := frameNumbers[mode]; numbers != nil {
+				sort.Sort(&framesByNumber{frames: mode.frames, numbers: numbers})
+			}
+			mode.framesChanged()
+		}
+	}
+	return sheet, nil
+}
+
+// texturePackerFrameName splits the name of a TexturePacker frame into its Entity name, Mode name, and (if numbered
+// is true) frame number. See NewSheetFromTexturePacker.
+func texturePackerFrameName(filename string) (entityName, modeName string, number int, numbered bool) {
+	name := strings.TrimSuffix(filename, path.Ext(filename))
+	parts := strings.Split(name, "/")
+	if len(parts) >= 3 {
+		if n, err := strconv.Atoi(parts[len(parts)-1]); err == nil {
+			return strings.Join(parts[:len(parts)-2], "/"), parts[len(parts)-2], n, true
+		}
+	}
+	if len(parts) >= 2 {
+		return strings.Join(parts[:len(parts)-1], "/"), parts[len(parts)-1], 0, false
+	}
+	return name, "Mode0", 0, false
+}
+
+// framesByNumber sorts frames by their corresponding frame numbers.
+type framesByNumber struct {
+	frames  []Sprite
+	numbers []int
+}
+
+func (f *framesByNumber) Len() int {
+	return len(f.frames)
+}
+
+func (f *framesByNumber) Less(i, j int) bool {
+	return f.numbers[i] < f.numbers[j]
+}
+
+func (f *framesByNumber) Swap(i, j int) {
+	f.frames[i], f.frames[j] = f.frames[j], f.frames[i]
+	f.numbers[i], f.numbers[j] = f.numbers[j], f.numbers[i]
+}
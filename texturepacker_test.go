@@ -0,0 +1,82 @@
+package sprites
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestNewSheetFromTexturePacker(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	img.SetRGBA(9, 1, red)
+	data := `{"frames": {
+		"hero/walk/0002.png": {"frame": {"x": 4, "y": 0, "w": 4, "h": 4}, "rotated": false, "trimmed": false},
+		"hero/walk/0001.png": {"frame": {"x": 0, "y": 0, "w": 4, "h": 4}, "rotated": false, "trimmed": false},
+		"hero/idle.png": {"frame": {"x": 0, "y": 4, "w": 4, "h": 4}},
+		"ui/menu/ok.png": {"frame": {"x": 9, "y": 1, "w": 2, "h": 1}, "trimmed": true,
+			"spriteSourceSize": {"x": 1, "y": 1, "w": 2, "h": 1}, "sourceSize": {"w": 6, "h": 3}},
+		"logo.png": {"frame": {"x": 8, "y": 4, "w": 8, "h": 4}}
+	}, "meta": {}}`
+	s, err := NewSheetFromTexturePacker(img, []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if names := s.EntityNames(); !reflect.DeepEqual(names, []string{"hero", "ui/menu", "logo"}) {
+		t.Fatalf("Entities %v, want [hero ui/menu logo]", names)
+	}
+
+	hero := mustEntity(t, s, 0)
+	if names := hero.ModeNames(); !reflect.DeepEqual(names, []string{"walk", "idle"}) {
+		t.Fatalf("hero Modes %v, want [walk idle]", names)
+	}
+	// The walk frames are ordered by number, not by their order in the JSON.
+	walk := mustMode(t, hero, 0)
+	if walk.FrameCount() != 2 {
+		t.Fatalf("walk has %d frames, want 2", walk.FrameCount())
+	}
+	for n, want := range []image.Rectangle{image.Rect(0, 0, 4, 4), image.Rect(4, 0, 8, 4)} {
+		if frame, _ := walk.GetFrame(n); frame.Bounds() != want {
+			t.Fatalf("walk frame %d is %v, want %v", n, frame.Bounds(), want)
+		}
+	}
+
+	// The trimmed frame is restored to its source size, with its pixels at their original position.
+	ok, err := mustEntity(t, s, 1).GetModeByName("ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame, _ := ok.GetFrame(0)
+	if frame.Bounds() != image.Rect(0, 0, 6, 3) || frame.(*image.RGBA).RGBAAt(1, 1) != red {
+		t.Fatalf("trimmed frame is %v, with %v at (1,1); want 6x3 with red", frame.Bounds(), frame.At(1, 1))
+	}
+
+	logo := mustEntity(t, s, 2)
+	if names := logo.ModeNames(); !reflect.DeepEqual(names, []string{DefaultNameFormatter("mode", 0)}) {
+		t.Fatalf("logo Modes %v, want the default name", names)
+	}
+}
+
+func TestNewSheetFromTexturePackerInvalid(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	for _, c := range []struct{ problem, data string }{
+		{"frames of different sizes in a Mode", `{"frames": [
+			{"filename": "a/b/1", "frame": {"x": 0, "y": 0, "w": 4, "h": 4}},
+			{"filename": "a/b/2", "frame": {"x": 0, "y": 0, "w": 2, "h": 4}}]}`},
+		{"duplicate frame", `{"frames": [
+			{"filename": "a/b", "frame": {"x": 0, "y": 0, "w": 4, "h": 4}},
+			{"filename": "a/b", "frame": {"x": 0, "y": 0, "w": 4, "h": 4}}]}`},
+		{"rotated frame", `{"frames": [{"filename": "a", "frame": {"x": 0, "y": 0, "w": 4, "h": 4}, "rotated": true}]}`},
+		{"frame outside the image", `{"frames": [{"filename": "a", "frame": {"x": 14, "y": 0, "w": 4, "h": 4}}]}`},
+		{"unnamed frame", `{"frames": [{"frame": {"x": 0, "y": 0, "w": 4, "h": 4}}]}`},
+		{"no frames", `{"frames": {}}`},
+	} {
+		if _, err := NewSheetFromTexturePacker(img, []byte(c.data)); err == nil {
+			t.Fatalf("%s accepted", c.problem)
+		}
+	}
+}
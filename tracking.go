@@ -0,0 +1,111 @@
+package sprites
+
+import (
+	"sync"
+)
+
+// instanceTracker records the Instances of a Sheet's Entities, so they can be advanced together. See
+// Sheet.TrackInstances.
+type instanceTracker struct {
+	mu sync.Mutex
+	// instances holds the tracked Instances, and index maps each of them to its position in instances.
+	instances []*Instance
+	index     map[*Instance]int
+}
+
+func newInstanceTracker() *instanceTracker {
+	return &instanceTracker{index: make(map[*Instance]int)}
+}
+
+// add starts tracking i, if it is not already tracked.
+func (t *instanceTracker) add(i *Instance) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.index[i]; ok {
+		return
+	}
+	t.index[i] = len(t.instances)
+	t.instances = append(t.instances, i)
+}
+
+// remove stops tracking i, if it is tracked. The last tracked Instance takes its place.
+func (t *instanceTracker) remove(i *Instance) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	pos, ok := t.index[i]
+	if !ok {
+		return
+	}
+	last := len(t.instances) - 1
+	t.instances[pos] = t.instances[last]
+	t.index[t.instances[pos]] = pos
+	t.instances[last] = nil
+	t.instances = t.instances[:last]
+	delete(t.index, i)
+}
+
+// snapshot returns a copy of the tracked Instances.
+func (t *instanceTracker) snapshot() []*Instance {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*Instance(nil), t.instances...)
+}
+
+// track starts tracking i if the Entity's Sheet is tracking Instances (see Sheet.TrackInstances), and returns it.
+func (e *Entity) track(i *Instance) *Instance {
+	if e.tracker != nil {
+		e.tracker.add(i)
+	}
+	return i
+}
+
+// TrackInstances sets whether the Sheet keeps track of the Instances of its Entities, so that they can all be advanced
+// at once by AdvanceAll. While enabled, Instances created by Entity.NewInstance (and the functions which use it, such
+// as NewInstanceWithModeName and RestoreInstance), Instance.Clone and InstancePool.Get are tracked; those returned to
+// an InstancePool with Put, or passed to ReleaseInstance, are not. Instances created before tracking was enabled, and
+// detached Instances (see Instance.Detach), are not tracked.
+// The Sheet holds a reference to every tracked Instance, so an Instance which is no longer needed - including one which
+// has Finished, as it may still be restarted - must be released (with ReleaseInstance or InstancePool.Put) to be
+// garbage collected. Disabling tracking releases all tracked Instances.
+// TrackInstances must not be called concurrently with the creation of Instances of the Sheet's Entities.
+func (s *Sheet) TrackInstances(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if enabled == (s.tracker != nil) {
+		return
+	}
+	if enabled {
+		s.tracker = newInstanceTracker()
+	} else {
+		s.tracker = nil
+	}
+	for _, e := range s.entities {
+		e.tracker = s.tracker
+	}
+}
+
+// ReleaseInstance stops the Sheet tracking i (see TrackInstances), so that AdvanceAll no longer advances it. i itself
+// is unaffected, and may still be used. It does nothing if i is not tracked.
+func (s *Sheet) ReleaseInstance(i *Instance) {
+	s.mu.RLock()
+	tracker := s.tracker
+	s.mu.RUnlock()
+	if tracker != nil {
+		tracker.remove(i)
+	}
+}
+
+// AdvanceAll advances each Instance tracked by the Sheet (see TrackInstances) once, as by Instance.Advance, in no
+// particular order. Instances created or released during the call (e.g. by OnFrame callbacks) take effect from the
+// next call. It does nothing if the Sheet is not tracking Instances.
+func (s *Sheet) AdvanceAll() {
+	s.mu.RLock()
+	tracker := s.tracker
+	s.mu.RUnlock()
+	if tracker == nil {
+		return
+	}
+	for _, i := range tracker.snapshot() {
+		i.Advance()
+	}
+}
@@ -0,0 +1,58 @@
+package sprites
+
+import "testing"
+
+func TestTrackInstances(t *testing.T) {
+	s := mustSheet(t)
+	e := mustEntity(t, s, 0)
+	untracked := mustInstance(t, e, 0)
+	s.TrackInstances(true)
+	a := mustInstance(t, e, 0)
+	b := a.Clone()
+	pool := NewInstancePool(s)
+	c, err := pool.Get(e.Name(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []*Instance{untracked, a, b, c} {
+		i.StartAnimation()
+	}
+	s.AdvanceAll()
+	for n, i := range []*Instance{a, b, c} {
+		if i.CurrentFrameIndex() != 1 {
+			t.Fatalf("tracked Instance %d on frame %d after AdvanceAll, want 1", n, i.CurrentFrameIndex())
+		}
+	}
+	if untracked.CurrentFrameIndex() != 0 {
+		t.Fatal("AdvanceAll advanced an Instance created before tracking was enabled")
+	}
+
+	// Instances returned to the pool, or released, are no longer advanced; a Get from the pool is tracked again.
+	pool.Put(c)
+	reused, err := pool.Get(e.Name(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reused.StartAnimation()
+	s.ReleaseInstance(b)
+	s.AdvanceAll()
+	if a.CurrentFrameIndex() != 2 || b.CurrentFrameIndex() != 1 || reused.CurrentFrameIndex() != 1 {
+		t.Fatalf("after releasing one Instance, frames %d, %d and %d; want 2, 1 and 1", a.CurrentFrameIndex(),
+			b.CurrentFrameIndex(), reused.CurrentFrameIndex())
+	}
+	pool.Put(reused)
+	if n := len(s.tracker.snapshot()); n != 1 {
+		t.Fatalf("%d Instances tracked, want 1", n)
+	}
+
+	// Detached Instances are not tracked.
+	if detached := a.Detach(); len(s.tracker.snapshot()) != 1 || detached.Entity.tracker != nil {
+		t.Fatal("detached Instance is tracked")
+	}
+
+	s.TrackInstances(false)
+	s.AdvanceAll()
+	if a.CurrentFrameIndex() != 2 {
+		t.Fatal("AdvanceAll advanced an Instance after tracking was disabled")
+	}
+}
@@ -0,0 +1,206 @@
+package sprites
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// derive returns a copy of m (see clone) recorded as derived from m by the transform kind. See DerivedFrom.
+func (m *Mode) derive(kind string) *Mode {
+	c := m.clone()
+	c.derived = true
+	c.derivedFrom = m.name
+	c.derivedKind = kind
+	return c
+}
+
+// DerivedFrom reports how the Mode was created, if it was derived from another Mode by a transform: the name (at the
+// time) of the source Mode, and the kind of transform - "grayscale", "adjust", "outline" or "shadow", for Grayscale,
+// Adjust, WithOutline and ShadowMode respectively. ok is false for Modes loaded from sprite sheets etc. Clones (e.g. from Sheet.Clone) keep the
+// provenance of the original.
+func (m *Mode) DerivedFrom() (srcModeName string, kind string, ok bool) {
+	return m.derivedFrom, m.derivedKind, m.derived
+}
+
+// mapFrames returns a copy of m derived by the transform kind (see derive), whose frames are the result of calling fn
+// on each of m's frames. fn must return a new image rather than modifying its argument.
+func (m *Mode) mapFrames(kind string, fn func(frame Sprite) *image.RGBA) *Mode {
+	c := m.derive(kind)
+	for n, frame := range c.frames {
+		c.frames[n] = fn(frame)
+	}
+	c.framesChanged()
+	return c
+}
+
+// mapPixels returns a copy of src, with Bounds().Min at (0,0), in which each pixel is the result of calling fn on the
+// corresponding (premultiplied) pixel of src.
+func mapPixels(src *image.RGBA, fn func(r, g, b, a uint8) (uint8, uint8, uint8, uint8)) *image.RGBA {
+	return mapPixelsAt(src, func(_, _ int, r, g, b, a uint8) (uint8, uint8, uint8, uint8) {
+		return fn(r, g, b, a)
+	})
+}
+
+// mapPixelsAt is like mapPixels, but also passes fn the position of the pixel, relative to src's top-left.
+func mapPixelsAt(src *image.RGBA, fn func(x, y int, r, g, b, a uint8) (uint8, uint8, uint8, uint8)) *image.RGBA {
+	dst := image.NewRGBA(image.Rectangle{Max: src.Rect.Size()})
+	for y := 0; y < dst.Rect.Dy(); y++ {
+		srcRow := rgbaRow(src, src.Rect.Min.Y+y)
+		dstRow := rgbaRow(dst, y)
+		for x := 0; x < len(srcRow); x += 4 {
+			dstRow[x], dstRow[x+1], dstRow[x+2], dstRow[x+3] = fn(x/4, y, srcRow[x], srcRow[x+1], srcRow[x+2], srcRow[x+3])
+		}
+	}
+	return dst
+}
+
+// Grayscale returns a new Mode, with the same name, sprite size, anchor, etc. as m, whose frames are grayscale
+// versions of m's (see GrayscaleSprite). The new Mode does not belong to any Entity.
+func (m *Mode) Grayscale() *Mode {
+	return m.mapFrames("grayscale", GrayscaleSprite)
+}
+
+// GrayscaleSprite returns a grayscale copy of s, using the Rec. 601 luma weights (0.299 R + 0.587 G + 0.114 B).
+// Alpha is unchanged.
+func GrayscaleSprite(s Sprite) *image.RGBA {
+	return mapPixels(toRGBA(s), func(r, g, b, a uint8) (uint8, uint8, uint8, uint8) {
+		// Rec. 601 weights, scaled by 1000 and rounded to nearest. As the weights sum to 1, the premultiplied luma
+		// never exceeds alpha.
+		y := uint8((299*uint32(r) + 587*uint32(g) + 114*uint32(b) + 500) / 1000)
+		return y, y, y, a
+	})
+}
+
+// Adjust returns a new Mode, with the same name, sprite size, anchor, etc. as m, whose frames are copies of m's with
+// their brightness, contrast and alpha adjusted, in that order. For each pixel's (non-premultiplied) color channels:
+// brightness, from -1 to 1, is added as a fraction of the full range (so 1 makes every visible pixel white); then the
+// difference from mid-gray is multiplied by contrast (so 0 makes every visible pixel gray). Finally, alpha is multiplied
+// by alphaScale. All results are clamped to the valid range, and FullyOpaque is recomputed for the new frames.
+// Adjust(0, 1, 1) returns an unchanged copy of m. The new Mode does not belong to any Entity.
+func (m *Mode) Adjust(brightness, contrast, alphaScale float64) *Mode {
+	if brightness == 0 && contrast == 1 && alphaScale == 1 {
+		return m.derive("adjust")
+	}
+	offset := brightness * 255
+	return m.mapFrames("adjust", func(frame Sprite) *image.RGBA {
+		return mapPixels(toRGBA(frame), func(r, g, b, a uint8) (uint8, uint8, uint8, uint8) {
+			if a == 0 {
+				return 0, 0, 0, 0
+			}
+			newA := clampUint8(float64(a) * alphaScale)
+			adjust := func(c uint8) uint8 {
+				v := float64(c) * 255 / float64(a)
+				v = (v+offset-127.5)*contrast + 127.5
+				return clampUint8(float64(clampUint8(v)) * float64(newA) / 255)
+			}
+			return adjust(r), adjust(g), adjust(b), newA
+		})
+	})
+}
+
+// clampUint8 returns v rounded to nearest and clamped to [0,255]. NaN is treated as 0.
+func clampUint8(v float64) uint8 {
+	if !(v > 0) {
+		return 0
+	}
+	if v >= 255 {
+		return 255
+	}
+	return uint8(math.Round(v))
+}
+
+// WithOutline returns a new Mode, with the same name, sprite size, anchor, etc. as m, whose frames are copies of m's with
+// an outline of color c drawn around their silhouettes: every fully transparent pixel within thickness pixels
+// (horizontally, vertically or diagonally - that is, 8-connected) of a non-transparent pixel is set to c. Other pixels
+// are unchanged. The sprite size is not expanded, so the outline is clipped at the edges of the sprite; leave a
+// transparent border of at least thickness pixels around sprites to avoid this. If thickness <= 0, the frames are
+// unchanged. As with any color.RGBA, c is alpha-premultiplied; any color channel greater than c.A is clamped to it. The
+// new Mode does not belong to any Entity.
+func (m *Mode) WithOutline(c color.RGBA, thickness int) *Mode {
+	if thickness <= 0 {
+		return m.derive("outline")
+	}
+	c = clampColorToAlpha(c)
+	return m.mapFrames("outline", func(frame Sprite) *image.RGBA {
+		src := toRGBA(frame)
+		size := src.Rect.Size()
+
+		// Dilate the silhouette by thickness in each direction, horizontally and then vertically, giving a square
+		// (Chebyshev distance) neighborhood.
+		solid := make([]bool, size.X*size.Y)
+		for y := 0; y < size.Y; y++ {
+			row := rgbaRow(src, src.Rect.Min.Y+y)
+			for x := 0; x < size.X; x++ {
+				solid[y*size.X+x] = row[x*4+3] != 0
+			}
+		}
+		horizontal := dilate(solid, size.X, size.Y, 1, size.X, thickness)
+		near := dilate(horizontal, size.Y, size.X, size.X, 1, thickness)
+
+		return mapPixelsAt(src, func(x, y int, r, g, b, a uint8) (uint8, uint8, uint8, uint8) {
+			if a == 0 && near[y*size.X+x] {
+				return c.R, c.G, c.B, c.A
+			}
+			return r, g, b, a
+		})
+	})
+}
+
+// ShadowMode returns a new Mode, with the same name, sprite size, anchor, etc. as m, whose frames are silhouettes of m's
+// in the color shadow (typically black at around half alpha), e.g. to place offset beneath a character as a ground
+// shadow. Every fully opaque pixel becomes shadow, and partially transparent pixels (such as anti-aliased edges) become
+// shadow with its alpha scaled by theirs, so the silhouette keeps the frames' alpha shape; transparent pixels remain
+// transparent. As with any color.RGBA, shadow is alpha-premultiplied; any color channel greater than shadow.A is clamped
+// to it. The new Mode does not belong to any Entity.
+func (m *Mode) ShadowMode(shadow color.RGBA) *Mode {
+	shadow = clampColorToAlpha(shadow)
+	scale := func(c, a uint8) uint8 {
+		return uint8((uint32(c)*uint32(a) + 127) / 255)
+	}
+	return m.mapFrames("shadow", func(frame Sprite) *image.RGBA {
+		return mapPixels(toRGBA(frame), func(_, _, _, a uint8) (uint8, uint8, uint8, uint8) {
+			return scale(shadow.R, a), scale(shadow.G, a), scale(shadow.B, a), scale(shadow.A, a)
+		})
+	})
+}
+
+// clampColorToAlpha returns c with each color channel clamped to its alpha, making it a valid alpha-premultiplied color.
+func clampColorToAlpha(c color.RGBA) color.RGBA {
+	for _, channel := range []*uint8{&c.R, &c.G, &c.B} {
+		if *channel > c.A {
+			*channel = c.A
+		}
+	}
+	return c
+}
+
+// dilate returns a copy of set in which each element is true if any element within radius of it along one axis is
+// true in set. The axis is given by length (the number of elements along it), step (the distance between adjacent
+// elements along it) and, for the other axis, lines (the number of lines) and lineStep (the distance between lines).
+func dilate(set []bool, length, lines, step, lineStep, radius int) []bool {
+	out := make([]bool, len(set))
+	for line := 0; line < lines; line++ {
+		base := line * lineStep
+		// last is the position of the last true element seen, at or before the current position.
+		last := -radius - 1
+		for i := 0; i < length; i++ {
+			if set[base+i*step] {
+				last = i
+			}
+			if i-last <= radius {
+				out[base+i*step] = true
+			}
+		}
+		last = length + radius
+		for i := length - 1; i >= 0; i-- {
+			if set[base+i*step] {
+				last = i
+			}
+			if last-i <= radius {
+				out[base+i*step] = true
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,167 @@
+package sprites
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// frameColor returns the color of the pixel at (x,y), relative to the frame's top-left, of m's frame at index.
+func frameColor(t *testing.T, m *Mode, index, x, y int) color.RGBA {
+	t.Helper()
+	frame, err := m.GetFrame(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rgba := frame.(*image.RGBA)
+	return rgba.RGBAAt(rgba.Rect.Min.X+x, rgba.Rect.Min.Y+y)
+}
+
+func TestGrayscale(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 1))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	img.Set(1, 0, color.RGBA{G: 255, A: 255})
+	img.Set(2, 0, color.NRGBA{R: 255, G: 255, B: 255, A: 128})
+	gray := GrayscaleSprite(img)
+	for x, want := range []color.RGBA{{76, 76, 76, 255}, {150, 150, 150, 255}, {128, 128, 128, 128}} {
+		if c := gray.RGBAAt(x, 0); c != want {
+			t.Fatalf("pixel %d is %v, want %v", x, c, want)
+		}
+	}
+	if img.RGBAAt(0, 0) != (color.RGBA{R: 255, A: 255}) {
+		t.Fatal("GrayscaleSprite modified its source")
+	}
+
+	m := mustMode(t, mustEntity(t, mustSheet(t), 0), 1)
+	g := m.Grayscale()
+	if g.FrameCount() != m.FrameCount() || g.FullyOpaque() != m.FullyOpaque() || g.SpriteSize() != m.SpriteSize() {
+		t.Fatal("Grayscale changed the frame count, opacity or sprite size")
+	}
+	// Frame 2 of Mode 1 is cell (1,2), {10 20 7 255}.
+	if c := frameColor(t, g, 2, 0, 0); c != (color.RGBA{16, 16, 16, 255}) {
+		t.Fatalf("grayscale frame is %v, want {16 16 16 255}", c)
+	}
+	if c := frameColor(t, m, 2, 0, 0); c != cellColor(1, 2) {
+		t.Fatal("Grayscale modified the source Mode")
+	}
+}
+
+func TestAdjust(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.RGBA{R: 200, G: 100, B: 10, A: 255})
+	img.Set(1, 0, color.RGBA{R: 10, G: 10, B: 10, A: 255})
+	m := singleSpriteMode(t, img)
+
+	same := m.Adjust(0, 1, 1)
+	if frameColor(t, same, 0, 0, 0) != frameColor(t, m, 0, 0, 0) || !same.FullyOpaque() {
+		t.Fatal("identity Adjust changed the frame")
+	}
+	for _, c := range []struct {
+		name                             string
+		brightness, contrast, alphaScale float64
+		x                                int
+		want                             color.RGBA
+	}{
+		{"full brightness", 1, 1, 1, 1, color.RGBA{255, 255, 255, 255}},
+		{"no brightness", -1, 1, 1, 0, color.RGBA{0, 0, 0, 255}},
+		{"high contrast", 0, 10, 1, 0, color.RGBA{255, 0, 0, 255}},
+		{"no contrast", 0, 0, 1, 0, color.RGBA{128, 128, 128, 255}},
+		{"triple alpha", 0, 1, 3, 1, color.RGBA{10, 10, 10, 255}},
+		{"no alpha", 0, 1, 0, 1, color.RGBA{}},
+	} {
+		if got := frameColor(t, m.Adjust(c.brightness, c.contrast, c.alphaScale), 0, c.x, 0); got != c.want {
+			t.Fatalf("%s: pixel %d is %v, want %v", c.name, c.x, got, c.want)
+		}
+	}
+
+	half := m.Adjust(0, 1, 0.5)
+	if c := frameColor(t, half, 0, 1, 0); c.A != 128 || half.FullyOpaque() {
+		t.Fatalf("half alpha pixel is %v, FullyOpaque %v", c, half.FullyOpaque())
+	}
+}
+
+func TestWithOutline(t *testing.T) {
+	white, red := color.RGBA{255, 255, 255, 255}, color.RGBA{R: 255, A: 255}
+	img := image.NewRGBA(image.Rect(0, 0, 9, 7))
+	img.SetRGBA(4, 3, white)
+	m := singleSpriteMode(t, img)
+	for thickness := 0; thickness <= 3; thickness++ {
+		outlined := m.WithOutline(red, thickness)
+		// The outline is a square ring of radius thickness around the pixel, clipped to the sprite.
+		for y := 0; y < 7; y++ {
+			for x := 0; x < 9; x++ {
+				dx, dy := x-4, y-3
+				want := color.RGBA{}
+				if dx == 0 && dy == 0 {
+					want = white
+				} else if dx >= -thickness && dx <= thickness && dy >= -thickness && dy <= thickness {
+					want = red
+				}
+				if c := frameColor(t, outlined, 0, x, y); c != want {
+					t.Fatalf("thickness %d: pixel (%d,%d) is %v, want %v", thickness, x, y, c, want)
+				}
+			}
+		}
+	}
+}
+
+func TestShadowMode(t *testing.T) {
+	img := filledImage(4, 4, color.RGBA{200, 30, 90, 255})
+	img.SetRGBA(0, 0, color.RGBA{})
+	img.SetRGBA(1, 0, color.RGBA{50, 50, 50, 100})
+	m := singleSpriteMode(t, img)
+	shadow := m.ShadowMode(color.RGBA{A: 128})
+	if shadow.FullyOpaque() {
+		t.Fatal("shadow Mode reports being fully opaque")
+	}
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			// The silhouette keeps the sprite's alpha shape: partially transparent pixels scale the shadow's alpha.
+			want := color.RGBA{A: 128}
+			if x == 0 && y == 0 {
+				want = color.RGBA{}
+			} else if x == 1 && y == 0 {
+				want = color.RGBA{A: 50}
+			}
+			if c := frameColor(t, shadow, 0, x, y); c != want {
+				t.Fatalf("pixel (%d,%d) is %v, want %v", x, y, c, want)
+			}
+		}
+	}
+	// A shadow color which is not a valid premultiplied color is clamped to one.
+	if c := frameColor(t, m.ShadowMode(color.RGBA{R: 255, A: 60}), 0, 2, 2); c != (color.RGBA{R: 60, A: 60}) {
+		t.Fatalf("shadow color {255 0 0 60} gave %v, want {60 0 0 60}", c)
+	}
+}
+
+func TestDerivedFrom(t *testing.T) {
+	s := mustSheet(t)
+	m := mustMode(t, mustEntity(t, s, 0), 0)
+	if _, _, ok := m.DerivedFrom(); ok {
+		t.Fatal("Mode loaded from the sheet reports being derived")
+	}
+	for _, c := range []struct {
+		derived *Mode
+		kind    string
+	}{
+		{m.Grayscale(), "grayscale"},
+		{m.Adjust(0, 1, 1), "adjust"},
+		{m.Adjust(0.5, 1, 1), "adjust"},
+		{m.WithOutline(color.RGBA{A: 255}, 1), "outline"},
+		{m.ShadowMode(color.RGBA{A: 128}), "shadow"},
+		// A Mode derived from a derived Mode reports the latest transform.
+		{m.Grayscale().WithOutline(color.RGBA{}, 0), "outline"},
+	} {
+		if src, kind, ok := c.derived.DerivedFrom(); !ok || src != m.Name() || kind != c.kind {
+			t.Fatalf("DerivedFrom gave %q, %q, %v; want %q, %q, true", src, kind, ok, m.Name(), c.kind)
+		}
+	}
+
+	// Clones keep the provenance.
+	e := mustEntity(t, s, 1)
+	e.modes[0] = mustMode(t, e, 0).Grayscale()
+	clone := mustMode(t, mustEntity(t, s.Clone(), 1), 0)
+	if _, kind, ok := clone.DerivedFrom(); !ok || kind != "grayscale" {
+		t.Fatalf("cloned derived Mode's DerivedFrom gave %q, %v; want grayscale, true", kind, ok)
+	}
+}